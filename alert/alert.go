@@ -0,0 +1,245 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Rule struct {
+	Name       string  `json:"name"`
+	Expr       string  `json:"expr"`
+	For        string  `json:"for,omitempty"` // overrides "for" of expr
+	Severity   string  `json:"severity,omitempty"`
+	Hysteresis float64 `json:"hysteresis,omitempty"` // numeric margin to cross back before resolving
+}
+
+type Alert struct {
+	Name     string    `json:"name"`
+	Expr     string    `json:"expr"`
+	Severity string    `json:"severity"`
+	Since    time.Time `json:"since"`
+	Time     time.Time `json:"time"`
+	Hits     []Hit     `json:"hits,omitempty"`
+}
+
+// Event is sent as is to the api, alert-firing or alert-resolved
+type Event struct {
+	Event string `json:"event"`
+	Alert Alert  `json:"alert"`
+}
+
+type ruleState struct {
+	Rule
+	expr *expr
+	keys map[string]*hitState // by hit key, "" for rules without wildcards
+}
+
+// hitState tracks each element matched by a wildcard separately, a second failing disk fires on its own
+type hitState struct {
+	pending time.Time
+	firing  bool
+	since   time.Time
+	last    Hit
+}
+
+// Engine evaluates rules over the latest documents observed from the collector,
+// the core snapshot is the root, other sources are nested by name, e.g. disks.smarts
+type Engine struct {
+	mu    sync.Mutex
+	file  string
+	rules []*ruleState
+	core  map[string]any
+	other map[string]any
+}
+
+func New() *Engine {
+	e := &Engine{
+		file:  os.Getenv("ALERT_RULES"),
+		other: map[string]any{},
+	}
+	if e.file == "" {
+		return e
+	}
+	data, err := os.ReadFile(e.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("[alert] read rules err:", err)
+		}
+		return e
+	}
+	var rules []Rule
+	if err = json.Unmarshal(data, &rules); err != nil {
+		log.Println("[alert] decode rules err:", err)
+		return e
+	}
+	if err = e.SetRules(rules); err != nil {
+		log.Println("[alert] rules err:", err)
+		return e
+	}
+	log.Printf("[alert] loaded %d rules from %s", len(rules), e.file)
+	return e
+}
+
+// SetRules replaces all rules, state of rules with the same name and expression is kept
+func (e *Engine) SetRules(rules []Rule) error {
+	states := make([]*ruleState, 0, len(rules))
+	for _, r := range rules {
+		ex, err := parseExpr(r.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		if r.For != "" {
+			ex.forDur, err = time.ParseDuration(r.For)
+			if err != nil {
+				return fmt.Errorf("rule %q for: %w", r.Name, err)
+			}
+		}
+		if r.Severity == "" {
+			r.Severity = "warning"
+		}
+		states = append(states, &ruleState{Rule: r, expr: ex, keys: map[string]*hitState{}})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, st := range states {
+		for _, old := range e.rules {
+			if old.Name == st.Name && old.Expr == st.Expr {
+				st.keys = old.keys
+			}
+		}
+	}
+	e.rules = states
+	return nil
+}
+
+// Save writes rules to the file they are loaded from, if any
+func (e *Engine) Save(rules []Rule) error {
+	if e.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.file)
+}
+
+// Observe stores the document of the source and evaluates all rules, core is evaluated on every second.
+// Without rules nothing is converted, a source is picked up on its next observation once rules are set.
+func (e *Engine) Observe(source string, v any, now time.Time) []Event {
+	e.mu.Lock()
+	empty := len(e.rules) == 0
+	e.mu.Unlock()
+	if empty {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("[alert] marshal", source, "err:", err)
+		return nil
+	}
+	var doc map[string]any
+	if err = json.Unmarshal(data, &doc); err != nil {
+		log.Println("[alert] unmarshal", source, "err:", err)
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if source == "core" {
+		e.core = doc
+	} else {
+		e.other[source] = doc
+	}
+
+	merged := make(map[string]any, len(e.core)+len(e.other))
+	for k, val := range e.core {
+		merged[k] = val
+	}
+	for k, val := range e.other {
+		merged[k] = val
+	}
+
+	var events []Event
+	for _, r := range e.rules {
+		events = append(events, r.step(merged, now)...)
+	}
+	return events
+}
+
+// step fires each hit key once it holds for the duration and resolves it once gone,
+// keys are visited in order so events come out stable
+func (r *ruleState) step(doc map[string]any, now time.Time) []Event {
+	hits := r.expr.eval(doc, func(key string) float64 {
+		if h, ok := r.keys[key]; ok && h.firing {
+			return -r.Hysteresis
+		}
+		return 0
+	})
+
+	current := make(map[string]Hit, len(hits))
+	for _, h := range hits {
+		current[h.Key] = h
+	}
+	keys := make([]string, 0, len(r.keys)+len(current))
+	for k := range r.keys {
+		keys = append(keys, k)
+	}
+	for k := range current {
+		if _, ok := r.keys[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var events []Event
+	for _, k := range keys {
+		hit, ok := current[k]
+		st := r.keys[k]
+		if !ok {
+			delete(r.keys, k)
+			if st.firing {
+				events = append(events, r.event("alert-resolved", st, st.last, now))
+			}
+			continue
+		}
+		if st == nil {
+			st = &hitState{pending: now}
+			r.keys[k] = st
+		}
+		st.last = hit
+		if st.firing || now.Sub(st.pending) < r.expr.forDur {
+			continue
+		}
+		st.firing = true
+		st.since = st.pending.UTC()
+		events = append(events, r.event("alert-firing", st, hit, now))
+	}
+	return events
+}
+
+func (r *ruleState) event(name string, st *hitState, hit Hit, now time.Time) Event {
+	return Event{
+		Event: name,
+		Alert: Alert{
+			Name:     r.Name,
+			Expr:     r.Expr,
+			Severity: r.Severity,
+			Since:    st.since,
+			Time:     now.UTC(),
+			Hits:     []Hit{hit},
+		},
+	}
+}
@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpr(t *testing.T) {
+	t.Parallel()
+
+	e, err := parseExpr(`spaceStats["/"].free < 5% for 5m`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.left) != 3 || e.left[1].key != "/" || e.op != "<" || e.num != 5 || !e.percent || e.forDur != 5*time.Minute {
+		t.Fatalf("error parse expr: %+v", e)
+	}
+
+	e, err = parseExpr(`disks.raids[*].adm.state contains "degraded"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.left[2].wildcard || e.op != "contains" || e.str != "degraded" {
+		t.Fatalf("error parse expr: %+v", e)
+	}
+
+	e, err = parseExpr(`processes[*].cmd contains "run for 5m" for 30s`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.str != "run for 5m" || e.forDur != 30*time.Second {
+		t.Fatalf("error parse expr: %+v", e)
+	}
+	e, err = parseExpr(`processes[*].cmd contains "wait for 5m"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.str != "wait for 5m" || e.forDur != 0 {
+		t.Fatalf("error parse expr: %+v", e)
+	}
+
+	if _, err = parseExpr(`cpuStats.avg 90`); err == nil {
+		t.Fatal("expected error for missing operator")
+	}
+}
+
+func TestEngine(t *testing.T) {
+	t.Parallel()
+
+	e := &Engine{other: map[string]any{}}
+	err := e.SetRules([]Rule{
+		{Name: "cpu", Expr: "cpuStats.avg > 90 for 2s", Hysteresis: 10},
+		{Name: "space", Expr: `spaceStats["/"].free < 5%`, Severity: "critical"},
+		{Name: "temp", Expr: "tempStats[*].temp >= tempStats[*].crit"},
+		{Name: "smart", Expr: `disks.smarts[*].health != "OK"`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core := func(cpu float64, free int) map[string]any {
+		return map[string]any{
+			"cpuStats":   map[string]any{"avg": cpu},
+			"spaceStats": map[string]any{"/": map[string]any{"total": 1000, "free": free}},
+			"tempStats": []map[string]any{
+				{"label": "cpu", "temp": 50, "crit": 100},
+				{"label": "nvme", "temp": 85, "crit": 84},
+			},
+		}
+	}
+
+	now := time.Now()
+	evs := e.Observe("core", core(95, 40), now)
+	if len(evs) != 2 || evs[0].Alert.Name != "space" || evs[1].Alert.Name != "temp" {
+		t.Fatalf("expected space and temp firing got %+v", evs)
+	}
+	if evs[1].Alert.Hits[0].Key != "1" {
+		t.Fatalf("expected temp hit on element 1 got %+v", evs[1].Alert.Hits)
+	}
+
+	// cpu fires only after holding for 2s
+	evs = e.Observe("core", core(95, 40), now.Add(2*time.Second))
+	if len(evs) != 1 || evs[0].Event != "alert-firing" || evs[0].Alert.Name != "cpu" {
+		t.Fatalf("expected cpu firing got %+v", evs)
+	}
+
+	// within hysteresis still firing, space resolved
+	evs = e.Observe("core", core(85, 400), now.Add(3*time.Second))
+	if len(evs) != 1 || evs[0].Event != "alert-resolved" || evs[0].Alert.Name != "space" {
+		t.Fatalf("expected space resolved got %+v", evs)
+	}
+	evs = e.Observe("core", core(79, 400), now.Add(4*time.Second))
+	if len(evs) != 1 || evs[0].Event != "alert-resolved" || evs[0].Alert.Name != "cpu" {
+		t.Fatalf("expected cpu resolved got %+v", evs)
+	}
+
+	evs = e.Observe("disks", map[string]any{"smarts": map[string]any{
+		"sda": map[string]any{"health": "OK"},
+		"sdb": map[string]any{"health": "FAILED"},
+	}}, now.Add(5*time.Second))
+	if len(evs) != 1 || evs[0].Alert.Name != "smart" || evs[0].Alert.Hits[0].Key != "sdb" {
+		t.Fatalf("expected smart firing on sdb got %+v", evs)
+	}
+}
+
+func TestEngineWildcardKeys(t *testing.T) {
+	t.Parallel()
+
+	e := &Engine{other: map[string]any{}}
+	err := e.SetRules([]Rule{
+		{Name: "smart", Expr: `disks.smarts[*].health != "OK"`},
+		{Name: "temp", Expr: "tempStats[*].temp > 80", Hysteresis: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	disks := func(sda, sdb string) map[string]any {
+		return map[string]any{"smarts": map[string]any{
+			"sda": map[string]any{"health": sda},
+			"sdb": map[string]any{"health": sdb},
+		}}
+	}
+
+	now := time.Now()
+	evs := e.Observe("disks", disks("FAILED", "OK"), now)
+	if len(evs) != 1 || evs[0].Event != "alert-firing" || evs[0].Alert.Hits[0].Key != "sda" {
+		t.Fatalf("expected sda firing got %+v", evs)
+	}
+
+	// second disk fails while the first still fails
+	evs = e.Observe("disks", disks("FAILED", "FAILED"), now.Add(time.Second))
+	if len(evs) != 1 || evs[0].Event != "alert-firing" || evs[0].Alert.Hits[0].Key != "sdb" {
+		t.Fatalf("expected sdb firing got %+v", evs)
+	}
+
+	// first is replaced, second keeps firing
+	evs = e.Observe("disks", disks("OK", "FAILED"), now.Add(2*time.Second))
+	if len(evs) != 1 || evs[0].Event != "alert-resolved" || evs[0].Alert.Hits[0].Key != "sda" ||
+		!evs[0].Alert.Since.Equal(now.UTC()) {
+		t.Fatalf("expected sda resolved got %+v", evs)
+	}
+	if evs = e.Observe("disks", disks("OK", "FAILED"), now.Add(3*time.Second)); len(evs) != 0 {
+		t.Fatalf("expected no change got %+v", evs)
+	}
+
+	// hysteresis applies to the firing sensor only
+	temps := func(a, b float64) map[string]any {
+		return map[string]any{"tempStats": []map[string]any{{"temp": a}, {"temp": b}}}
+	}
+	evs = e.Observe("core", temps(85, 70), now)
+	if len(evs) != 1 || evs[0].Alert.Hits[0].Key != "0" {
+		t.Fatalf("expected temp 0 firing got %+v", evs)
+	}
+	evs = e.Observe("core", temps(78, 78), now.Add(time.Second))
+	if len(evs) != 0 {
+		t.Fatalf("expected temp 0 held by hysteresis, 1 below threshold got %+v", evs)
+	}
+}
+
+func TestEngineNoRules(t *testing.T) {
+	t.Parallel()
+
+	e := &Engine{other: map[string]any{}}
+	if evs := e.Observe("core", map[string]any{"cpuStats": map[string]any{"avg": 99}}, time.Now()); len(evs) != 0 {
+		t.Fatalf("expected no events got %+v", evs)
+	}
+	if e.core != nil || len(e.other) != 0 {
+		t.Fatalf("document stored without rules: %+v %+v", e.core, e.other)
+	}
+}
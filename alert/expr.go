@@ -0,0 +1,318 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// expr is a single comparison: <path> <op> <operand> [for <duration>]
+//
+//	cpuStats.avg > 90 for 5m
+//	spaceStats["/"].free < 5%
+//	tempStats[*].temp >= tempStats[*].crit
+//	disks.smarts[*].health != "OK"
+//	disks.raids[*].adm.state contains "degraded"
+type expr struct {
+	left    path
+	op      string
+	right   path    // operand is another path
+	str     string  // operand is a quoted string
+	num     float64 // operand is a number
+	isNum   bool
+	percent bool // num is percent of the sibling total
+	forDur  time.Duration
+}
+
+// path segment is either a field, a map key / index or a wildcard
+type segment struct {
+	key      string
+	wildcard bool
+}
+
+type path []segment
+
+var ops = []string{">=", "<=", "!=", "==", ">", "<", " contains "}
+
+func parseExpr(s string) (*expr, error) {
+	e := &expr{}
+	s = strings.TrimSpace(s)
+
+	if i := lastIndexOutsideQuotes(s, " for "); i > 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(s[i+5:]))
+		if err != nil {
+			return nil, fmt.Errorf("for duration: %w", err)
+		}
+		e.forDur = d
+		s = strings.TrimSpace(s[:i])
+	}
+
+	idx, op := -1, ""
+	for _, o := range ops {
+		if i := indexOutsideQuotes(s, o); i > 0 && (idx < 0 || i < idx) {
+			idx, op = i, o
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("no comparison operator in %q", s)
+	}
+	e.op = strings.TrimSpace(op)
+
+	var err error
+	e.left, err = parsePath(strings.TrimSpace(s[:idx]))
+	if err != nil {
+		return nil, err
+	}
+
+	operand := strings.TrimSpace(s[idx+len(op):])
+	switch {
+	case operand == "":
+		return nil, fmt.Errorf("empty operand in %q", s)
+	case operand[0] == '"':
+		e.str, err = strconv.Unquote(operand)
+		if err != nil {
+			return nil, fmt.Errorf("operand string: %w", err)
+		}
+	case operand[0] == '-' || unicode.IsDigit(rune(operand[0])):
+		if strings.HasSuffix(operand, "%") {
+			e.percent = true
+			operand = strings.TrimSuffix(operand, "%")
+		}
+		e.num, err = strconv.ParseFloat(operand, 64)
+		if err != nil {
+			return nil, fmt.Errorf("operand number: %w", err)
+		}
+		e.isNum = true
+	default:
+		e.right, err = parsePath(operand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if e.op == "contains" && e.right == nil && e.isNum {
+		return nil, fmt.Errorf("contains needs a string operand in %q", s)
+	}
+	return e, nil
+}
+
+func parsePath(s string) (path, error) {
+	var p path
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '.':
+			i++
+		case s[i] == '[':
+			end := indexOutsideQuotes(s[i:], "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", s)
+			}
+			inner := strings.TrimSpace(s[i+1 : i+end])
+			switch {
+			case inner == "*":
+				p = append(p, segment{wildcard: true})
+			case strings.HasPrefix(inner, `"`):
+				k, err := strconv.Unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("key %s: %w", inner, err)
+				}
+				p = append(p, segment{key: k})
+			default:
+				p = append(p, segment{key: inner})
+			}
+			i += end + 1
+		default:
+			j := i
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			if !isIdent(s[i:j]) {
+				return nil, fmt.Errorf("bad field %q in %q", s[i:j], s)
+			}
+			p = append(p, segment{key: s[i:j]})
+			i = j
+		}
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return p, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOutsideQuotes(s, sub string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' && (i == 0 || s[i-1] != '\\') {
+			quoted = !quoted
+			continue
+		}
+		if !quoted && strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lastIndexOutsideQuotes(s, sub string) int {
+	last := -1
+	for i := 0; ; {
+		j := indexOutsideQuotes(s[i:], sub)
+		if j < 0 {
+			return last
+		}
+		last = i + j
+		i = last + 1
+	}
+}
+
+// match is a value found by a path, keys are the map keys or indexes picked by wildcards
+type match struct {
+	keys   []string
+	field  string
+	value  any
+	parent map[string]any
+}
+
+// resolve walks a json decoded document, bound keys replace wildcards in order
+func (p path) resolve(doc any, bound []string) []match {
+	var out []match
+	var walk func(v any, i int, keys []string, parent map[string]any)
+	walk = func(v any, i int, keys []string, parent map[string]any) {
+		if i == len(p) {
+			out = append(out, match{keys: keys, field: p[len(p)-1].key, value: v, parent: parent})
+			return
+		}
+		seg := p[i]
+		if seg.wildcard && len(keys) < len(bound) {
+			seg = segment{key: bound[len(keys)]}
+			keys = append(keys[:len(keys):len(keys)], seg.key)
+		} else if seg.wildcard {
+			switch t := v.(type) {
+			case map[string]any:
+				for k, item := range t {
+					walk(item, i+1, append(keys[:len(keys):len(keys)], k), t)
+				}
+			case []any:
+				for k, item := range t {
+					walk(item, i+1, append(keys[:len(keys):len(keys)], strconv.Itoa(k)), nil)
+				}
+			}
+			return
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			if item, ok := t[seg.key]; ok {
+				walk(item, i+1, keys, t)
+			}
+		case []any:
+			if n, err := strconv.Atoi(seg.key); err == nil && n >= 0 && n < len(t) {
+				walk(t[n], i+1, keys, nil)
+			}
+		}
+	}
+	walk(doc, 0, nil, nil)
+	return out
+}
+
+// total finds the sibling the percent operand refers to: total, or memTotal for memAvailable
+func (m match) total() (float64, bool) {
+	if m.parent == nil {
+		return 0, false
+	}
+	if t, ok := m.parent["total"].(float64); ok {
+		return t, true
+	}
+	for k, v := range m.parent {
+		prefix, ok := strings.CutSuffix(k, "Total")
+		if ok && prefix != "" && strings.HasPrefix(m.field, prefix) {
+			t, ok := v.(float64)
+			return t, ok
+		}
+	}
+	return 0, false
+}
+
+// Hit is a single element which satisfies the expression
+type Hit struct {
+	Key   string `json:"key,omitempty"`
+	Value any    `json:"value"`
+}
+
+// eval returns elements satisfying the expression, shift moves the numeric threshold of a hit key for hysteresis
+func (e *expr) eval(doc any, shift func(key string) float64) []Hit {
+	var hits []Hit
+	for _, m := range e.left.resolve(doc, nil) {
+		left := m.value
+		var right any
+		switch {
+		case e.right != nil:
+			rs := e.right.resolve(doc, m.keys)
+			if len(rs) == 0 {
+				continue
+			}
+			right = rs[0].value
+		case e.isNum:
+			right = e.num
+			if e.percent {
+				l, ok := left.(float64)
+				total, tok := m.total()
+				if !ok || !tok || total == 0 {
+					continue
+				}
+				left = l * 100 / total
+			}
+		default:
+			right = e.str
+		}
+		key := strings.Join(m.keys, "/")
+		if compare(left, e.op, right, shift(key)) {
+			hits = append(hits, Hit{Key: key, Value: left})
+		}
+	}
+	return hits
+}
+
+func compare(left any, op string, right any, shift float64) bool {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if lok && rok {
+		switch op {
+		case ">":
+			return l > r+shift
+		case ">=":
+			return l >= r+shift
+		case "<":
+			return l < r-shift
+		case "<=":
+			return l <= r-shift
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		}
+		return false
+	}
+	ls, rs := fmt.Sprint(left), fmt.Sprint(right)
+	switch op {
+	case "==":
+		return ls == rs
+	case "!=":
+		return ls != rs
+	case "contains":
+		return strings.Contains(strings.ToLower(ls), strings.ToLower(rs))
+	}
+	return false
+}
@@ -9,23 +9,47 @@ import (
 type CollectCore struct {
 	Time     time.Time
 	LoadAvg  []string
+	PSIStats *PSIStats `json:"psiStats,omitempty"`
 	CPUStats struct {
-		Cores []int   `json:"cores"`
-		Avg   float32 `json:"avg"`
+		Cores   []int     `json:"cores"`
+		Avg     float32   `json:"avg"`
+		Total   CPUTimes  `json:"total"`
+		Details []CPUCore `json:"details"`
+		Ctxt    uint64    `json:"ctxt"`  // context switches per second
+		Intr    uint64    `json:"intr"`  // interrupts per second
+		Forks   uint64    `json:"forks"` // per second
 	} `json:"cpuStats"`
 	MemStats struct {
-		MemTotal  int `json:"memTotal"`
-		MemFree   int `json:"memFree"`
-		Buffers   int `json:"buffers"`
-		Cached    int `json:"cached"`
-		Slab      int `json:"slab"`
-		SwapTotal int `json:"swapTotal"`
-		SwapFree  int `json:"swapFree"`
+		MemTotal       int `json:"memTotal"`
+		MemFree        int `json:"memFree"`
+		MemAvailable   int `json:"memAvailable"`
+		Buffers        int `json:"buffers"`
+		Cached         int `json:"cached"`
+		Shmem          int `json:"shmem"`
+		Slab           int `json:"slab"`
+		SReclaimable   int `json:"sReclaimable"`
+		SUnreclaim     int `json:"sUnreclaim"`
+		Dirty          int `json:"dirty"`
+		Writeback      int `json:"writeback"`
+		AnonHugePages  int `json:"anonHugePages"`
+		HugePagesTotal int `json:"hugePagesTotal"` // pages
+		HugePagesFree  int `json:"hugePagesFree"`  // pages
+		HugePagesRsvd  int `json:"hugePagesRsvd"`  // pages
+		HugePagesSurp  int `json:"hugePagesSurp"`  // pages
+		HugePageSize   int `json:"hugePageSize"`
+		ZfsArc         int `json:"zfsArc"` // counted in used by kernel, yet reclaimable
+		SwapTotal      int `json:"swapTotal"`
+		SwapFree       int `json:"swapFree"`
+		SwapIn         int `json:"swapIn"`    // pages per second
+		SwapOut        int `json:"swapOut"`   // pages per second
+		MajFaults      int `json:"majFaults"` // per second
 	} `json:"memStats"`
 	IOStats    map[string]IOStat      `json:"ioStats"`
+	NetStats   map[string]NetStat     `json:"netStats"`
 	SpaceStats map[string]SpaceStatFS `json:"spaceStats"`
 	GPUStats   GPUStats               `json:"gpuStats"`
-	TempStats  []TempStats            `json:"tempStats"`
+	TempStats  []TempStats            `json:"tempStats"` // hwmon temperatures, kept for older consumers
+	Sensors    []Sensor               `json:"sensors"`
 }
 
 type IOStat struct {
@@ -39,6 +63,8 @@ type IOStat struct {
 	AwaitWriteMs   int `json:"awaitWriteMs"`
 	AwaitDiscardMs int `json:"awaitDiscardMs"`
 	Utils          int `json:"utils"`
+
+	Label string `json:"label,omitempty"` // vg0/root for dm-3, from topology
 }
 
 type WhoLogged struct {
@@ -50,12 +76,24 @@ type WhoLogged struct {
 }
 
 type Proc struct {
-	PID     int    `json:"pid"`
-	PPID    int    `json:"ppid"`
-	Name    string `json:"name"`
-	State   string `json:"state"`
-	Threads int    `json:"threads"`
-	FDs     int    `json:"fds"`
+	PID        int       `json:"pid"`
+	PPID       int       `json:"ppid"`
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	Threads    int       `json:"threads"`
+	FDs        int       `json:"fds"`
+	CPU        float64   `json:"cpu"`        // percent of one core between scans
+	RSS        uint64    `json:"rss"`        // bytes
+	PSS        uint64    `json:"pss"`        // bytes
+	ReadBytes  uint64    `json:"readBytes"`  // total from storage
+	WriteBytes uint64    `json:"writeBytes"` // total to storage
+	ReadRate   uint64    `json:"readRate"`   // bytes per second between scans
+	WriteRate  uint64    `json:"writeRate"`  // bytes per second between scans
+	StartTime  time.Time `json:"startTime"`
+	UID        int       `json:"uid"`
+	User       string    `json:"user"`    // from HOST_PROC/1/root/etc/passwd, empty without HOST_PROC
+	Cmdline    string    `json:"cmdline"` // whole, arguments joined by spaces
+	Cgroup     string    `json:"cgroup"`
 }
 
 type SpaceStatFS struct {
@@ -63,6 +101,19 @@ type SpaceStatFS struct {
 	BlockSize      uint64 `json:"blockSize"`
 	Total          uint64 `json:"total"`
 	Free           uint64 `json:"free"`
+	Files          uint64 `json:"files"` // inodes
+	FilesFree      uint64 `json:"filesFree"`
+	ReadOnly       bool   `json:"readOnly"`
+	Device         string `json:"device,omitempty"` // kernel name, empty for zfs and network filesystems
+	Label          string `json:"label,omitempty"`
+
+	GrowthBytesPerHour float64 `json:"growthBytesPerHour"` // linear fit over the forecast window
+	TimeToFull         int64   `json:"timeToFull"`         // seconds, -1 not growing
+
+	// mountinfo discovery only
+	FsType  string `json:"fsType,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Options string `json:"options,omitempty"`
 }
 
 type SmartDisk struct {
@@ -75,14 +126,37 @@ type SmartDisk struct {
 	Temperature string `json:"temperature"`
 	Full        string `json:"full"`
 	Error       string `json:"error"`
+
+	// typed values from smartctl json, counters are -1 when the drive does not report them
+	Device        string      `json:"device,omitempty"`
+	Protocol      string      `json:"protocol,omitempty"`
+	Wwn           string      `json:"wwn,omitempty"`
+	ExitStatus    int         `json:"exitStatus"`
+	CapacityBytes uint64      `json:"capacityBytes"`
+	TemperatureC  int         `json:"temperatureC"`
+	PowerOnHours  int64       `json:"powerOnHours"`
+	PowerCycles   int64       `json:"powerCycles"`
+	PercentUsed   int         `json:"percentUsed"`
+	Reallocated   int64       `json:"reallocated"`
+	Pending       int64       `json:"pending"`
+	Uncorrectable int64       `json:"uncorrectable"`
+	CRCErrors     int64       `json:"crcErrors"`
+	Nvme          *SmartNvme  `json:"nvme,omitempty"`
+	Attributes    []SmartAttr `json:"attributes,omitempty"`
+	Trend         *SmartTrend `json:"trend,omitempty"`
+
+	SelfTestRunning   bool            `json:"selfTestRunning"`
+	SelfTestRemaining int             `json:"selfTestRemaining"`     // percent
+	SelfTestLog       []SmartSelfTest `json:"selfTestLog,omitempty"` // newest first
 }
 
 type RaidMD struct {
-	Disks   []string  `json:"disks"`
-	Proc    RaidProc  `json:"proc"`
-	ProcOut string    `json:"procOut"`
-	Adm     RaidMDAdm `json:"adm"`
-	AdmOut  string    `json:"admOut"`
+	Disks   []string   `json:"disks"`
+	Proc    RaidProc   `json:"proc"`
+	ProcOut string     `json:"procOut"`
+	Adm     RaidMDAdm  `json:"adm"`
+	AdmOut  string     `json:"admOut"`
+	Sys     *RaidMDSys `json:"sys,omitempty"`
 }
 
 type RaidProc struct {
@@ -110,6 +184,7 @@ type RaidZFS struct {
 	PoolState string        `json:"poolState"`
 	Name      string        `json:"name"`
 	Type      string        `json:"type"`
+	Class     string        `json:"class"` // normal, special, dedup, log, cache, spare
 	State     string        `json:"state"`
 	Capacity  int           `json:"capacity"`
 	Devs      []RaidZFSDevs `json:"devs"`
@@ -124,14 +199,25 @@ type RaidZFSDevs struct {
 type DisksInfo struct {
 	Version int                   `json:"version"`
 	Time    time.Time             `json:"time"`
+	Disks   map[string]*BlockDisk `json:"disks"`
 	Smarts  map[string]*SmartDisk `json:"smarts"`
 	Raids   map[string]*RaidMD    `json:"raids"`
 	Zfs     []RaidZFS             `json:"zfs"`
+
+	Topology    *BlockTopology  `json:"topology,omitempty"`
+	ZfsPools    []ZfsPoolStatus `json:"zfsPools,omitempty"`
+	ZfsDatasets []ZfsDataset    `json:"zfsDatasets,omitempty"`
+	ZfsArc      *ZfsArcStats    `json:"zfsArc,omitempty"`
+
+	SelfTests map[string]*SelfTestRun `json:"selfTests,omitempty"` // started by the agent
 }
 
 type GPUStats struct {
 	Nvidia []SmiNvidia `json:"nvidia,omitempty"`
 	Amd    []GpuAmd    `json:"amd,omitempty"`
+	Intel  []GpuIntel  `json:"intel,omitempty"`
+
+	Processes []GpuClient `json:"processes,omitempty"` // from drm fdinfo, any vendor but nvidia
 }
 
 type SmiNvidia struct {
@@ -157,6 +243,69 @@ type SmiNvidia struct {
 	ClockSm    string `json:"clockSm"`
 	ClockMem   string `json:"clockMem"`
 	ClockVideo string `json:"clockVideo"`
+
+	// typed values of the same xml, counters are -1 when the gpu does not report them
+	UUID             string       `json:"uuid,omitempty"`
+	Serial           string       `json:"serial,omitempty"`
+	PState           string       `json:"pstate,omitempty"`
+	FanPct           float64      `json:"fanPct"`
+	MemTotalBytes    uint64       `json:"memTotalBytes"`
+	MemUsedBytes     uint64       `json:"memUsedBytes"`
+	MemFreeBytes     uint64       `json:"memFreeBytes"`
+	UtilGpuPct       float64      `json:"utilGpuPct"`
+	UtilMemPct       float64      `json:"utilMemPct"`
+	UtilEncPct       float64      `json:"utilEncPct"`
+	UtilDecPct       float64      `json:"utilDecPct"`
+	TempC            float64      `json:"tempC"`
+	TempMemC         float64      `json:"tempMemC"`
+	TempSlowC        float64      `json:"tempSlowC"` // clocks slow down above
+	TempMaxC         float64      `json:"tempMaxC"`  // gpu shuts down above
+	PowerW           float64      `json:"powerW"`
+	PowerLimitW      float64      `json:"powerLimitW"`
+	ClockGraMHz      int          `json:"clockGraMHz"`
+	ClockSmMHz       int          `json:"clockSmMHz"`
+	ClockMemMHz      int          `json:"clockMemMHz"`
+	ClockVideoMHz    int          `json:"clockVideoMHz"`
+	PciTxBytes       uint64       `json:"pciTxBytes"` // per second
+	PciRxBytes       uint64       `json:"pciRxBytes"` // per second
+	Throttle         []string     `json:"throttle"`   // active reasons, e.g. gpu_idle, sw_power_cap, hw_thermal_slowdown
+	EccEnabled       bool         `json:"eccEnabled"`
+	EccVolatile      NvidiaEcc    `json:"eccVolatile"` // since driver load
+	EccAggregate     NvidiaEcc    `json:"eccAggregate"`
+	RetiredSingle    int64        `json:"retiredSingle"` // pages, pre Ampere
+	RetiredDouble    int64        `json:"retiredDouble"`
+	RetiredPending   bool         `json:"retiredPending"`
+	RemappedCorr     int64        `json:"remappedCorr"` // rows, since Ampere
+	RemappedUnc      int64        `json:"remappedUnc"`
+	RemappedPending  bool         `json:"remappedPending"`
+	RemappedFailure  bool         `json:"remappedFailure"`
+	ResetRequired    bool         `json:"resetRequired"`
+	DrainRecommended bool         `json:"drainRecommended"`
+	MigMode          string       `json:"migMode,omitempty"`
+	Mig              []NvidiaMig  `json:"mig,omitempty"`
+	Processes        []GpuProcess `json:"processes"`
+}
+
+type NvidiaEcc struct {
+	Correctable   int64 `json:"correctable"`
+	Uncorrectable int64 `json:"uncorrectable"`
+}
+
+type NvidiaMig struct {
+	Index           int    `json:"index"`
+	GpuInstance     int    `json:"gpuInstance"`
+	ComputeInstance int    `json:"computeInstance"`
+	MemTotalBytes   uint64 `json:"memTotalBytes"`
+	MemUsedBytes    uint64 `json:"memUsedBytes"`
+}
+
+type GpuProcess struct {
+	PID             int    `json:"pid"`
+	Type            string `json:"type"`            // C compute, G graphics, C+G
+	GpuInstance     int    `json:"gpuInstance"`     // -1 without mig
+	ComputeInstance int    `json:"computeInstance"` // -1 without mig
+	MemUsedBytes    uint64 `json:"memUsedBytes"`
+	Proc            *Proc  `json:"proc,omitempty"` // from the last process scan
 }
 
 type GpuAmd struct {
@@ -172,19 +321,46 @@ type GpuAmd struct {
 	TmpGpu     string `json:"tmpGpu"`
 	Power      string `json:"power"`
 	ClockSoc   int    `json:"clockSoc"`
+
+	// typed values decoded per table revision, temperatures in C, -1 when the table does not carry them
+	TempEdge       float64  `json:"tempEdge"` // gfx temperature on APUs
+	TempHotspot    float64  `json:"tempHotspot"`
+	TempMem        float64  `json:"tempMem"`
+	FanRPM         int      `json:"fanRpm"`
+	PowerW         float64  `json:"powerW"` // socket
+	GfxActivity    float64  `json:"gfxActivity"`
+	MemActivity    float64  `json:"memActivity"`
+	MediaActivity  float64  `json:"mediaActivity"`
+	ClockGfx       int      `json:"clockGfx"`       // MHz
+	ClockVram      int      `json:"clockVram"`      // MHz
+	ThrottleStatus uint32   `json:"throttleStatus"` // asic dependent bits
+	Throttle       []string `json:"throttle"`       // asic independent reasons, v1.3 and v2.2 onwards
 }
 
 type Collector struct {
-	mu       sync.RWMutex
-	data     CollectCore
-	ChanCore chan *CollectCore
+	mu          sync.RWMutex
+	data        CollectCore
+	blockLabels map[string]string
+	ipmiSensors []Sensor
+	procs       map[int]Proc // last process scan before top n, joined with gpu processes
+	ChanCore    chan *CollectCore
 
 	ChanWhoLogged chan *WhoLogged
 	whoSessionMu  sync.Mutex
 	whoSessions   map[string]*WhoLogged
 
-	ChanProcesses chan *Processes
-	ChanDisksInfo chan *DisksInfo
+	ChanProcesses  chan *Processes
+	ChanContainers chan *Containers
+	ChanDisksInfo  chan *DisksInfo
+	ChanDiskHealth chan *DiskHealthChange
+	ChanRaidState  chan *RaidStateChange
+	disksMu        sync.Mutex
+	disks          []string
+	blockDisks     map[string]*BlockDisk
+	selfTests      map[string]*SelfTestRun
+
+	ChanSpaceForecast chan *SpaceForecast
+	ChanGpuState      chan *SubprocessState
 }
 
 func New() *Collector {
@@ -195,21 +371,35 @@ func New() *Collector {
 
 		ChanWhoLogged: make(chan *WhoLogged, 128),
 
-		ChanProcesses: make(chan *Processes, 1),
-		ChanDisksInfo: make(chan *DisksInfo, 1),
+		ChanProcesses:  make(chan *Processes, 1),
+		ChanContainers: make(chan *Containers, 1),
+		ChanDisksInfo:  make(chan *DisksInfo, 1),
+		ChanDiskHealth: make(chan *DiskHealthChange, 16),
+		ChanRaidState:  make(chan *RaidStateChange, 16),
+
+		ChanSpaceForecast: make(chan *SpaceForecast, 16),
+		ChanGpuState:      make(chan *SubprocessState, 16),
+		selfTests:         map[string]*SelfTestRun{},
 	}
 
 	go c.senderCore()
 	go c.collectLoadAvg()
+	go c.collectPSI()
 	go c.collectCPU()
 	go c.collectMem()
+	go c.collectTopology()
 	go c.collectIO()
+	go c.collectNet()
 	go c.collectWho()
 	go c.collectProc()
+	go c.collectContainers()
 	go c.collectSpace()
 	go c.collectDisks()
+	go c.collectRaidMD()
 	go c.collectGpuNvidia()
 	go c.collectGpuAmd()
+	go c.collectGpuIntel()
+	go c.collectDrmClients()
 	go c.collectTemperature()
 
 	return c
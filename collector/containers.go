@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Containers []Container
+
+type Container struct {
+	Cgroup        string  `json:"cgroup"`
+	Runtime       string  `json:"runtime"` // docker, containerd, cri-o, podman, lxc, systemd
+	ID            string  `json:"id"`
+	Label         string  `json:"label"`
+	CPU           float64 `json:"cpu"`           // percent of one core between scans
+	Throttled     uint64  `json:"throttled"`     // periods throttled between scans
+	ThrottledUsec uint64  `json:"throttledUsec"` // between scans
+	MemCurrent    uint64  `json:"memCurrent"`
+	MemMax        uint64  `json:"memMax"` // 0 unlimited
+	OOM           uint64  `json:"oom"`
+	OOMKill       uint64  `json:"oomKill"`
+	ReadRate      uint64  `json:"readRate"`  // bytes per second between scans
+	WriteRate     uint64  `json:"writeRate"` // bytes per second between scans
+	ReadIOPS      uint64  `json:"readIOPS"`
+	WriteIOPS     uint64  `json:"writeIOPS"`
+	Pids          uint64  `json:"pids"`
+	PidsMax       uint64  `json:"pidsMax"` // 0 unlimited
+}
+
+type cgroupSample struct {
+	usageUsec, nrThrottled, throttledUsec uint64
+	rBytes, wBytes, rIOs, wIOs            uint64
+	time                                  time.Time
+}
+
+var (
+	cgroupPrev = map[string]cgroupSample{}
+
+	reCgroupScope = regexp.MustCompile(`^(docker|cri-containerd|crio|libpod)-([0-9a-f]{12,64})\.scope$`)
+	reCgroupID    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	cgroupRuntime = map[string]string{
+		"docker":         "docker",
+		"cri-containerd": "containerd",
+		"crio":           "cri-o",
+		"libpod":         "podman",
+	}
+)
+
+func (c *Collector) collectContainers() {
+	root := os.Getenv("CGROUP_ROOT")
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	v2 := true
+	if _, err := os.Stat(root + "/cgroup.controllers"); err != nil {
+		v2 = false
+	}
+
+	for range time.Tick(30 * time.Second) {
+		var cs Containers
+		if v2 {
+			cs = cgroupWalk(root, root, readCgroupV2)
+		} else {
+			acct := root + "/cpu,cpuacct"
+			if _, err := os.Stat(acct); err != nil {
+				acct = root + "/cpuacct"
+			}
+			cs = cgroupWalk(acct, root, readCgroupV1)
+		}
+		c.ChanContainers <- &cs
+	}
+}
+
+// cgroupWalk finds container and service cgroups below base, inner cgroups of a container are not descended
+func cgroupWalk(base, root string, read func(root, rel string) (Container, cgroupSample)) Containers {
+	now := time.Now()
+	seen := map[string]cgroupSample{}
+	cs := Containers{}
+	_ = filepath.WalkDir(base, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == base {
+			return nil
+		}
+		runtime, id, ok := cgroupLabel(path)
+		if !ok {
+			return nil
+		}
+		rel := strings.TrimPrefix(path, base)
+		ct, cur := read(root, rel)
+		ct.Cgroup = rel
+		ct.Runtime = runtime
+		ct.ID = id
+		ct.Label = containerName(runtime, id)
+		cur.time = now
+		if prev, ok := cgroupPrev[rel]; ok {
+			ct.delta(prev, cur)
+		}
+		seen[rel] = cur
+		cs = append(cs, ct)
+		return filepath.SkipDir
+	})
+	cgroupPrev = seen
+	return cs
+}
+
+// cgroupLabel recognizes runtime scopes by directory name, e.g.
+// system.slice/docker-<id>.scope, docker/<id>, kubepods/.../cri-containerd-<id>.scope, system.slice/nginx.service
+func cgroupLabel(path string) (runtime, id string, ok bool) {
+	name := filepath.Base(path)
+	if m := reCgroupScope.FindStringSubmatch(name); m != nil {
+		return cgroupRuntime[m[1]], m[2], true
+	}
+	if reCgroupID.MatchString(name) {
+		parent := filepath.Base(filepath.Dir(path))
+		switch {
+		case parent == "docker":
+			return "docker", name, true
+		case strings.HasPrefix(parent, "pod") || strings.Contains(parent, "containerd"):
+			return "containerd", name, true
+		case parent == "libpod_parent" || strings.HasPrefix(parent, "machine.slice"):
+			return "podman", name, true
+		}
+	}
+	if id, found := strings.CutPrefix(name, "lxc.payload."); found {
+		return "lxc", id, true
+	}
+	if strings.HasSuffix(name, ".service") && filepath.Base(filepath.Dir(path)) == "system.slice" {
+		return "systemd", strings.TrimSuffix(name, ".service"), true
+	}
+	return "", "", false
+}
+
+// containerName gives docker containers their name when the docker root is readable
+func containerName(runtime, id string) string {
+	if runtime != "docker" {
+		if len(id) > 12 && reCgroupID.MatchString(id) {
+			return runtime + ":" + id[:12]
+		}
+		return runtime + ":" + id
+	}
+	dockerRoot := os.Getenv("DOCKER_ROOT")
+	if dockerRoot == "" {
+		dockerRoot = "/var/lib/docker"
+	}
+	data, err := os.ReadFile(dockerRoot + "/containers/" + id + "/config.v2.json")
+	if err == nil {
+		var cfg struct {
+			Name string `json:"Name"`
+		}
+		if json.Unmarshal(data, &cfg) == nil && cfg.Name != "" {
+			return "docker:" + strings.TrimPrefix(cfg.Name, "/")
+		}
+	}
+	return "docker:" + id[:min(12, len(id))]
+}
+
+func readCgroupV2(root, rel string) (Container, cgroupSample) {
+	dir := root + rel
+	ct := Container{}
+	cur := cgroupSample{}
+
+	cpu := parseCgroupKV(readSysString(dir + "/cpu.stat"))
+	cur.usageUsec = cpu["usage_usec"]
+	cur.nrThrottled = cpu["nr_throttled"]
+	cur.throttledUsec = cpu["throttled_usec"]
+
+	ct.MemCurrent, _ = strconv.ParseUint(readSysString(dir+"/memory.current"), 10, 64)
+	ct.MemMax, _ = strconv.ParseUint(readSysString(dir+"/memory.max"), 10, 64)
+	events := parseCgroupKV(readSysString(dir + "/memory.events"))
+	ct.OOM = events["oom"]
+	ct.OOMKill = events["oom_kill"]
+
+	cur.rBytes, cur.wBytes, cur.rIOs, cur.wIOs = parseCgroupIOStat(readSysString(dir + "/io.stat"))
+
+	ct.Pids, _ = strconv.ParseUint(readSysString(dir+"/pids.current"), 10, 64)
+	ct.PidsMax, _ = strconv.ParseUint(readSysString(dir+"/pids.max"), 10, 64)
+	return ct, cur
+}
+
+func readCgroupV1(root, rel string) (Container, cgroupSample) {
+	ctl := func(names ...string) string {
+		for _, n := range names {
+			if _, err := os.Stat(root + "/" + n + rel); err == nil {
+				return root + "/" + n + rel
+			}
+		}
+		return root + "/" + names[0] + rel
+	}
+	ct := Container{}
+	cur := cgroupSample{}
+
+	acct := ctl("cpuacct", "cpu,cpuacct")
+	usage, _ := strconv.ParseUint(readSysString(acct+"/cpuacct.usage"), 10, 64)
+	cur.usageUsec = usage / 1000
+	cpu := parseCgroupKV(readSysString(ctl("cpu", "cpu,cpuacct") + "/cpu.stat"))
+	cur.nrThrottled = cpu["nr_throttled"]
+	cur.throttledUsec = cpu["throttled_time"] / 1000
+
+	mem := ctl("memory")
+	ct.MemCurrent, _ = strconv.ParseUint(readSysString(mem+"/memory.usage_in_bytes"), 10, 64)
+	ct.MemMax, _ = strconv.ParseUint(readSysString(mem+"/memory.limit_in_bytes"), 10, 64)
+	// v1 reports unlimited as a page aligned max int64
+	if ct.MemMax >= 1<<62 {
+		ct.MemMax = 0
+	}
+	ct.OOMKill = parseCgroupKV(readSysString(mem + "/memory.oom_control"))["oom_kill"]
+
+	blkio := ctl("blkio")
+	cur.rBytes, cur.wBytes = parseBlkioTotals(readSysString(blkio + "/blkio.throttle.io_service_bytes"))
+	cur.rIOs, cur.wIOs = parseBlkioTotals(readSysString(blkio + "/blkio.throttle.io_serviced"))
+
+	pids := ctl("pids")
+	ct.Pids, _ = strconv.ParseUint(readSysString(pids+"/pids.current"), 10, 64)
+	ct.PidsMax, _ = strconv.ParseUint(readSysString(pids+"/pids.max"), 10, 64)
+	return ct, cur
+}
+
+func (ct *Container) delta(prev, cur cgroupSample) {
+	sec := cur.time.Sub(prev.time).Seconds()
+	if sec <= 0 {
+		return
+	}
+	rate := func(c, p uint64) uint64 {
+		if c < p {
+			return 0
+		}
+		return uint64(float64(c-p) / sec)
+	}
+	if cur.usageUsec >= prev.usageUsec {
+		ct.CPU = float64(cur.usageUsec-prev.usageUsec) / 1e6 / sec * 100
+	}
+	if cur.nrThrottled >= prev.nrThrottled {
+		ct.Throttled = cur.nrThrottled - prev.nrThrottled
+	}
+	if cur.throttledUsec >= prev.throttledUsec {
+		ct.ThrottledUsec = cur.throttledUsec - prev.throttledUsec
+	}
+	ct.ReadRate = rate(cur.rBytes, prev.rBytes)
+	ct.WriteRate = rate(cur.wBytes, prev.wBytes)
+	ct.ReadIOPS = rate(cur.rIOs, prev.rIOs)
+	ct.WriteIOPS = rate(cur.wIOs, prev.wIOs)
+}
+
+// parseCgroupKV reads flat keyed files like cpu.stat and memory.events
+func parseCgroupKV(data string) map[string]uint64 {
+	res := map[string]uint64{}
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(f[1], 10, 64); err == nil {
+			res[f[0]] = v
+		}
+	}
+	return res
+}
+
+// parseCgroupIOStat sums "MAJ:MIN rbytes=.. wbytes=.. rios=.. wios=.." over all devices
+func parseCgroupIOStat(data string) (rBytes, wBytes, rIOs, wIOs uint64) {
+	for _, line := range strings.Split(data, "\n") {
+		for _, kv := range strings.Fields(line) {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			n, _ := strconv.ParseUint(v, 10, 64)
+			switch k {
+			case "rbytes":
+				rBytes += n
+			case "wbytes":
+				wBytes += n
+			case "rios":
+				rIOs += n
+			case "wios":
+				wIOs += n
+			}
+		}
+	}
+	return
+}
+
+// parseBlkioTotals sums "MAJ:MIN Read N" and "MAJ:MIN Write N" lines of v1 blkio files
+func parseBlkioTotals(data string) (read, write uint64) {
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		n, _ := strconv.ParseUint(f[2], 10, 64)
+		switch f[1] {
+		case "Read":
+			read += n
+		case "Write":
+			write += n
+		}
+	}
+	return
+}
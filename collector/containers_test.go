@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestCgroupLabel(t *testing.T) {
+	t.Parallel()
+
+	id := "3f2a7c1d9e8b4a5f6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b"
+	cases := []struct {
+		path, runtime, id string
+		ok                bool
+	}{
+		{"/sys/fs/cgroup/system.slice/docker-" + id + ".scope", "docker", id, true},
+		{"/sys/fs/cgroup/cpuacct/docker/" + id, "docker", id, true},
+		{"/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-pod1.slice/cri-containerd-" + id + ".scope",
+			"containerd", id, true},
+		{"/sys/fs/cgroup/machine.slice/libpod-" + id + ".scope", "podman", id, true},
+		{"/sys/fs/cgroup/system.slice/nginx.service", "systemd", "nginx", true},
+		{"/sys/fs/cgroup/user.slice/user-1000.slice", "", "", false},
+	}
+	for _, tc := range cases {
+		runtime, cid, ok := cgroupLabel(tc.path)
+		if runtime != tc.runtime || cid != tc.id || ok != tc.ok {
+			t.Fatalf("error label %s: got %q %q %v", tc.path, runtime, cid, ok)
+		}
+	}
+}
+
+func TestParseCgroupStats(t *testing.T) {
+	t.Parallel()
+
+	cpu := parseCgroupKV(`usage_usec 8123456
+user_usec 6000000
+system_usec 2123456
+nr_periods 120
+nr_throttled 7
+throttled_usec 35000
+`)
+	if cpu["usage_usec"] != 8123456 || cpu["nr_throttled"] != 7 || cpu["throttled_usec"] != 35000 {
+		t.Fatalf("error parse cpu.stat: %v", cpu)
+	}
+
+	rb, wb, ri, wi := parseCgroupIOStat(`8:0 rbytes=1000 wbytes=2000 rios=10 wios=20 dbytes=0 dios=0
+8:16 rbytes=500 wbytes=0 rios=5 wios=0 dbytes=0 dios=0
+`)
+	if rb != 1500 || wb != 2000 || ri != 15 || wi != 20 {
+		t.Fatalf("error parse io.stat: %d %d %d %d", rb, wb, ri, wi)
+	}
+
+	r, w := parseBlkioTotals(`8:0 Read 4096
+8:0 Write 8192
+8:0 Sync 0
+8:0 Total 12288
+Total 12288
+`)
+	if r != 4096 || w != 8192 {
+		t.Fatalf("error parse blkio: %d %d", r, w)
+	}
+}
@@ -1,18 +1,56 @@
 package collector
 
 import (
+	"bufio"
 	"os"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var cpuPrev = map[int]*cpuCore{}
+// cpu times order in /proc/stat
+const (
+	cpuUser = iota
+	cpuNice
+	cpuSystem
+	cpuIdle
+	cpuIOWait
+	cpuIRQ
+	cpuSoftIRQ
+	cpuSteal
+	cpuGuest
+	cpuGuestNice
+	cpuFields
+)
+
+// cpuTotalKey keys the aggregate "cpu" line in cpuPrev
+const cpuTotalKey = -1
+
+var (
+	cpuPrev  = map[int]*cpuCore{}
+	statPrev = map[string]uint64{}
+)
 
 type cpuCore struct {
-	idle  int
-	total int
+	times [cpuFields]uint64
+}
+
+type CPUCore struct {
+	ID int `json:"id"` // N of cpuN, stays the same when other cores go offline
+	CPUTimes
+}
+
+type CPUTimes struct {
+	Busy    float32 `json:"busy"`
+	User    float32 `json:"user"`
+	Nice    float32 `json:"nice"`
+	System  float32 `json:"system"`
+	IOWait  float32 `json:"iowait"`
+	IRQ     float32 `json:"irq"`
+	SoftIRQ float32 `json:"softirq"`
+	Steal   float32 `json:"steal"`
+	Guest   float32 `json:"guest"`
 }
 
 func (c *Collector) collectLoadAvg() {
@@ -34,37 +72,70 @@ func (c *Collector) collectCPU() {
 }
 
 func (c *Collector) procStatHandler(file string) {
-	stat, err := os.ReadFile(file)
+	stat, err := os.Open(file)
 	if err != nil {
 		return
 	}
-
-	var cores []int
-	total := 0
-	re := regexp.MustCompile(`(cpu\d+).+`)
-	for i, match := range re.FindAllString(string(stat), -1) {
-		num := i + 1
-
-		it := cpuIdleTotal(match)
-
-		if _, ok := cpuPrev[num]; !ok {
-			cpuPrev[num] = &cpuCore{0, 0}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(stat)
+
+	var (
+		details []CPUCore
+		total   CPUTimes
+		rates   = map[string]uint64{}
+		seen    = map[int]bool{}
+	)
+
+	s := bufio.NewScanner(stat)
+	s.Buffer(make([]byte, 64*1024), 1024*1024) // intr line is long on many-irq hosts
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 {
+			continue
 		}
 
-		diffIdle := it.idle - cpuPrev[num].idle
-		diffTotal := it.total - cpuPrev[num].total
-
-		if diffTotal <= 0 {
-			diffIdle = it.idle
-			diffTotal = it.total
+		switch {
+		case f[0] == "cpu":
+			total = cpuTimesDelta(cpuTotalKey, f[1:])
+		case strings.HasPrefix(f[0], "cpu"):
+			id, err := strconv.Atoi(f[0][3:])
+			if err != nil {
+				continue
+			}
+			seen[id] = true
+			details = append(details, CPUCore{
+				ID:       id,
+				CPUTimes: cpuTimesDelta(id, f[1:]),
+			})
+		case f[0] == "ctxt" || f[0] == "intr" || f[0] == "processes":
+			val, err := strconv.ParseUint(f[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			if prev, ok := statPrev[f[0]]; ok && val >= prev {
+				rates[f[0]] = val - prev
+			}
+			statPrev[f[0]] = val
 		}
+	}
 
-		cpuPrev[num].idle = it.idle
-		cpuPrev[num].total = it.total
+	// forget hot-unplugged cores, a core coming back starts from scratch
+	for id := range cpuPrev {
+		if id != cpuTotalKey && !seen[id] {
+			delete(cpuPrev, id)
+		}
+	}
 
-		core := (1000*(diffTotal-diffIdle)/diffTotal + 5) / 10
-		total = total + core
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].ID < details[j].ID
+	})
 
+	cores := make([]int, 0, len(details))
+	sum := 0
+	for _, d := range details {
+		core := int(d.Busy + 0.5)
+		sum += core
 		cores = append(cores, core)
 	}
 
@@ -73,21 +144,66 @@ func (c *Collector) procStatHandler(file string) {
 
 	c.data.Time = time.Now().UTC()
 	c.data.CPUStats.Cores = cores
+	c.data.CPUStats.Details = details
+	c.data.CPUStats.Total = total
+	c.data.CPUStats.Ctxt = rates["ctxt"]
+	c.data.CPUStats.Intr = rates["intr"]
+	c.data.CPUStats.Forks = rates["processes"]
 	numCores := len(cores)
 	if numCores > 0 {
-		c.data.CPUStats.Avg = float32(total) / float32(numCores)
+		c.data.CPUStats.Avg = float32(sum) / float32(numCores)
 	}
 }
 
-func cpuIdleTotal(match string) cpuCore {
-	line := strings.Split(match, " ")
-	idle, _ := strconv.Atoi(line[4])
-	total := 0
-	for _, l := range line {
-		i, err := strconv.Atoi(l)
-		if err == nil {
-			total += i
+// cpuTimesDelta turns jiffies of a cpu line into percentages since the previous tick,
+// on the first tick it is since boot
+func cpuTimesDelta(key int, fields []string) CPUTimes {
+	var cur cpuCore
+	for i := 0; i < cpuFields && i < len(fields); i++ {
+		cur.times[i], _ = strconv.ParseUint(fields[i], 10, 64)
+	}
+
+	prev, ok := cpuPrev[key]
+	if !ok {
+		prev = &cpuCore{}
+		cpuPrev[key] = prev
+	}
+
+	var diff cpuCore
+	var all uint64
+	for i := range cur.times {
+		if cur.times[i] >= prev.times[i] {
+			diff.times[i] = cur.times[i] - prev.times[i]
+		}
+		// guest time is already accounted in user and nice
+		if i != cpuGuest && i != cpuGuestNice {
+			all += diff.times[i]
 		}
 	}
-	return cpuCore{idle, total}
+	if all == 0 {
+		diff = cur
+		all = 0
+		for i := cpuUser; i < cpuGuest; i++ {
+			all += cur.times[i]
+		}
+	}
+	prev.times = cur.times
+
+	if all == 0 {
+		return CPUTimes{}
+	}
+	pct := func(v uint64) float32 {
+		return float32(v) * 100 / float32(all)
+	}
+	return CPUTimes{
+		Busy:    pct(all - diff.times[cpuIdle]),
+		User:    pct(diff.times[cpuUser] - min(diff.times[cpuGuest], diff.times[cpuUser])),
+		Nice:    pct(diff.times[cpuNice] - min(diff.times[cpuGuestNice], diff.times[cpuNice])),
+		System:  pct(diff.times[cpuSystem]),
+		IOWait:  pct(diff.times[cpuIOWait]),
+		IRQ:     pct(diff.times[cpuIRQ]),
+		SoftIRQ: pct(diff.times[cpuSoftIRQ]),
+		Steal:   pct(diff.times[cpuSteal]),
+		Guest:   pct(diff.times[cpuGuest] + diff.times[cpuGuestNice]),
+	}
 }
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcStatHandler(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stat")
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &Collector{}
+	write(`cpu  200 0 200 1400 0 0 0 0 0 0
+cpu0 100 0 100 700 0 0 0 0 0 0
+cpu2 100 0 100 700 0 0 0 0 0 0
+intr 1000 0 0
+ctxt 5000
+processes 70
+`)
+	c.procStatHandler(file)
+
+	// cpu1 is offline, cpu2 must stay cpu2
+	write(`cpu  300 0 250 1450 100 0 0 100 0 0
+cpu0 150 0 100 750 0 0 0 0 0 0
+cpu2 120 0 120 700 40 0 0 20 0 0
+intr 1500 0 0
+ctxt 9000
+processes 75
+`)
+	c.procStatHandler(file)
+
+	cs := c.data.CPUStats
+	if len(cs.Details) != 2 || cs.Details[0].ID != 0 || cs.Details[1].ID != 2 {
+		t.Fatalf("error cores keyed by index: %+v", cs.Details)
+	}
+	cpu2 := cs.Details[1]
+	if cpu2.User != 20 || cpu2.System != 20 || cpu2.IOWait != 40 || cpu2.Steal != 20 || cpu2.Busy != 100 {
+		t.Fatalf("error cpu2 breakdown: %+v", cpu2)
+	}
+	if cs.Cores[0] != 50 || cs.Cores[1] != 100 || cs.Avg != 75 {
+		t.Fatalf("error cores busy: %v avg %v", cs.Cores, cs.Avg)
+	}
+	if cs.Ctxt != 4000 || cs.Intr != 500 || cs.Forks != 5 {
+		t.Fatalf("error rates: %d %d %d", cs.Ctxt, cs.Intr, cs.Forks)
+	}
+}
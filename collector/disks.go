@@ -13,30 +13,64 @@ import (
 )
 
 func (c *Collector) collectDisks() {
-	var disks []string
-	output, err := exec.Command("sh", "-c", "lsblk -d -n -o NAME,RO | awk '/0$/ {print $1}'").Output()
-	if err == nil {
-		for _, d := range strings.Split(strings.TrimSpace(string(output)), "\n") {
-			disks = append(disks, d)
+	c.refreshDisks()
+	go c.scheduleSelfTests()
+
+	hotplug := make(chan struct{}, 1)
+	go watchBlockUevents(hotplug)
+
+	history := newSmartHistory()
+	ticker := time.NewTicker(15 * time.Minute)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-hotplug:
+			// a shelf powers up many disks at once, take them in one cycle
+			time.Sleep(5 * time.Second)
+			select {
+			case <-hotplug:
+			default:
+			}
+		}
+
+		blockDisks := c.refreshDisks()
+		disks := make([]string, 0, len(blockDisks))
+		for _, d := range blockDisks {
+			disks = append(disks, d.Name)
 		}
-	}
 
-	for range time.Tick(15 * time.Minute) {
 		di := &DisksInfo{
 			Version: 2,
 			Time:    time.Now().UTC(),
+			Disks:   blockDisks,
 			Smarts:  map[string]*SmartDisk{},
 			Raids:   map[string]*RaidMD{},
 			Zfs:     []RaidZFS{},
+
+			SelfTests: c.selfTestRuns(),
 		}
 
 		// smarts disks
 		for _, dev := range disks {
-			info, err := exec.Command("sh", "-c", "smartctl --all /dev/"+dev).CombinedOutput()
-			if err != nil {
-				di.Smarts[dev] = &SmartDisk{Error: "err: " + err.Error() + " | out: " + string(info)}
-			} else {
-				di.Smarts[dev] = c.parseSmart(string(info))
+			di.Smarts[dev] = c.smartDisk(dev)
+			if ch := history.observe(dev, di.Smarts[dev], di.Time); ch != nil {
+				c.ChanDiskHealth <- ch
+			}
+		}
+		history.prune(di.Time)
+		history.save()
+
+		// lvm, crypt and cache layers
+		di.Topology = readTopology("/sys")
+		if lvs, err := exec.Command("sh", "-c", "lvs --reportformat json --units b --nosuffix -a -o vg_name,lv_name,segtype,lv_size,pool_lv,data_percent,metadata_percent,lv_kernel_minor").Output(); err == nil {
+			if err = di.Topology.mergeLvs(lvs); err != nil {
+				log.Println("[collector] lvs parse err:", err)
+			}
+		}
+		if vgs, err := exec.Command("sh", "-c", "vgs --reportformat json --units b --nosuffix -o vg_name,vg_size,vg_free,pv_count,lv_count").Output(); err == nil {
+			if err = di.Topology.mergeVgs(vgs); err != nil {
+				log.Println("[collector] vgs parse err:", err)
 			}
 		}
 
@@ -52,6 +86,9 @@ func (c *Collector) collectDisks() {
 				adm.Adm = c.parseMdAdm(string(mdAdm))
 				adm.AdmOut = string(mdAdm)
 			}
+			if dir := mdSysDir(md); dir != "" {
+				adm.Sys = readMdSys(dir)
+			}
 		}
 
 		// raids zfs
@@ -63,10 +100,80 @@ func (c *Collector) collectDisks() {
 			}
 		}
 
+		zfsStatus, err := exec.Command("sh", "-c", "zpool status -jp").Output()
+		if err == nil {
+			di.ZfsPools, err = c.parseZpoolStatus(zfsStatus)
+			if err != nil {
+				log.Println("[collector] zpool status parse err:", err)
+			}
+		}
+
+		zfsList, err := exec.Command("sh", "-c", "zfs list -jp -o name,type,used,available,referenced,quota,compressratio,mountpoint").Output()
+		if err == nil {
+			di.ZfsDatasets, err = c.parseZfsList(zfsList)
+			if err != nil {
+				log.Println("[collector] zfs list parse err:", err)
+			}
+		}
+
+		if arc, err := os.ReadFile(pathArcStats); err == nil {
+			cur := parseArcStats(string(arc))
+			di.ZfsArc = zfsArcStats(cur, arcPrev)
+			arcPrev = cur
+		}
+
 		c.ChanDisksInfo <- di
 	}
 }
 
+// refreshDisks rediscovers disks, a different drive in the same slot is logged as replaced
+func (c *Collector) refreshDisks() map[string]*BlockDisk {
+	found := discoverDisks(pathBlock)
+
+	c.disksMu.Lock()
+	defer c.disksMu.Unlock()
+	blockDisks := make(map[string]*BlockDisk, len(found))
+	c.disks = c.disks[:0]
+	for _, d := range found {
+		if prev, ok := c.blockDisks[d.Name]; !ok {
+			if c.blockDisks != nil {
+				log.Printf("[collector] disk %s added: %s %s", d.Name, d.Model, d.ID())
+			}
+		} else if prev.ID() != d.ID() {
+			log.Printf("[collector] disk %s replaced: %s -> %s", d.Name, prev.ID(), d.ID())
+		}
+		blockDisks[d.Name] = d
+		c.disks = append(c.disks, d.Name)
+	}
+	for name, prev := range c.blockDisks {
+		if _, ok := blockDisks[name]; !ok {
+			log.Printf("[collector] disk %s removed: %s", name, prev.ID())
+		}
+	}
+	c.blockDisks = blockDisks
+	return blockDisks
+}
+
+// smartDisk prefers json output, smartctl before 7.0 knows text only
+func (c *Collector) smartDisk(dev string) *SmartDisk {
+	// non-zero exit bits are warnings about the drive, json is printed anyway
+	js, _ := exec.Command("sh", "-c", "smartctl --json=co --all /dev/"+dev).Output()
+	if sd, err := c.parseSmartJson(js); err == nil {
+		// api renders full as text, a build without the output array needs a second run
+		if strings.TrimSpace(sd.Full) == "" {
+			text, _ := exec.Command("sh", "-c", "smartctl --all /dev/"+dev).CombinedOutput()
+			sd.Full = string(text)
+		}
+		return sd
+	}
+
+	info, err := exec.Command("sh", "-c", "smartctl --all /dev/"+dev).CombinedOutput()
+	if err != nil {
+		return &SmartDisk{Error: "err: " + err.Error() + " | out: " + string(info)}
+	}
+	return c.parseSmart(string(info))
+}
+
 var reSmartModel = regexp.MustCompile(`Model Family:(.*?)\nDevice Model:(.*?)\n`)
 var reSmartModel2 = regexp.MustCompile(`(?m)Model Number:(.*?)$`)
 var reSmartModel3 = regexp.MustCompile(`(?m)Device Model:(.*?)$`)
@@ -93,7 +200,12 @@ var reSmartTemperature4 = regexp.MustCompile(`Temperature.*?:.*?(.*)Celsius`)
 
 func (c *Collector) parseSmart(data string) *SmartDisk {
 	sd := &SmartDisk{
-		Full: data,
+		Full:          data,
+		Reallocated:   -1,
+		Pending:       -1,
+		Uncorrectable: -1,
+		CRCErrors:     -1,
+		PercentUsed:   -1,
 	}
 
 	sModel := reSmartModel.FindStringSubmatch(data)
@@ -382,60 +494,12 @@ type ZfsPool struct {
 		} `json:"altroot"`
 	} `json:"properties"`
 
-	Vdevs map[string]struct {
-		Name       string `json:"name"`
-		VdevType   string `json:"vdev_type"`
-		Guid       string `json:"guid"`
-		Class      string `json:"class"`
-		State      string `json:"state"`
-		Properties struct {
-			Size struct {
-				Value  string `json:"value"`
-				Source struct {
-					Type string `json:"type"`
-					Data string `json:"data"`
-				} `json:"source"`
-			} `json:"size"`
-			Allocated struct {
-				Value  string `json:"value"`
-				Source struct {
-					Type string `json:"type"`
-					Data string `json:"data"`
-				} `json:"source"`
-			} `json:"allocated"`
-			Free struct {
-				Value  string `json:"value"`
-				Source struct {
-					Type string `json:"type"`
-					Data string `json:"data"`
-				} `json:"source"`
-			} `json:"free"`
-			Health struct {
-				Value  string `json:"value"`
-				Source struct {
-					Type string `json:"type"`
-					Data string `json:"data"`
-				} `json:"source"`
-			} `json:"health"`
-		} `json:"properties"`
-		Vdevs map[string]struct {
-			Name       string `json:"name"`
-			VdevType   string `json:"vdev_type"`
-			Guid       string `json:"guid"`
-			Path       string `json:"path"`
-			Class      string `json:"class"`
-			State      string `json:"state"`
-			Properties struct {
-				Health struct {
-					Value  string `json:"value"`
-					Source struct {
-						Type string `json:"type"`
-						Data string `json:"data"`
-					} `json:"source"`
-				} `json:"health"`
-			} `json:"properties"`
-		} `json:"vdevs"`
-	} `json:"vdevs"`
+	Vdevs   map[string]*ZfsVdev `json:"vdevs"`
+	Special map[string]*ZfsVdev `json:"special"`
+	Dedup   map[string]*ZfsVdev `json:"dedup"`
+	Logs    map[string]*ZfsVdev `json:"logs"`
+	L2cache map[string]*ZfsVdev `json:"l2cache"`
+	Spares  map[string]*ZfsVdev `json:"spares"`
 }
 
 func (c *Collector) parseZfs(data []byte) ([]RaidZFS, error) {
@@ -454,9 +518,9 @@ func (c *Collector) parseZfs(data []byte) ([]RaidZFS, error) {
 			return nil, fmt.Errorf("zfs json unmarshal err: %v", err)
 		}
 
-		for _, dev := range zp.Vdevs {
+		for _, dev := range zp.topVdevs() {
 			devs := make([]RaidZFSDevs, 0)
-			for _, d := range dev.Vdevs {
+			for _, d := range dev.leaves() {
 				if d.VdevType != "disk" {
 					continue
 				}
@@ -473,6 +537,7 @@ func (c *Collector) parseZfs(data []byte) ([]RaidZFS, error) {
 				PoolState: strings.TrimSpace(strings.ToLower(zp.State)),
 				Name:      dev.Name,
 				Type:      dev.VdevType,
+				Class:     dev.Class,
 				State:     strings.TrimSpace(strings.ToLower(dev.State)),
 				Capacity:  size,
 				Devs:      devs,
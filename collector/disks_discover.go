@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const pathBlock = "/sys/block"
+
+type BlockDisk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	Vendor     string `json:"vendor,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Wwn        string `json:"wwn,omitempty"`
+	Transport  string `json:"transport"` // nvme, sata, sas, usb, virtio, mmc, scsi
+	Size       uint64 `json:"size"`      // bytes
+	Rotational bool   `json:"rotational"`
+	Removable  bool   `json:"removable"`
+}
+
+// ID identifies the drive itself rather than the slot it is in
+func (d *BlockDisk) ID() string {
+	if d.Wwn != "" {
+		return d.Wwn
+	}
+	if d.Serial != "" {
+		return d.Serial
+	}
+	return d.Model + "/" + d.Name
+}
+
+// virtual and stacked devices have no SMART, md and dm are reported by raids and topology
+var blockSkipPrefix = []string{"loop", "ram", "zram", "dm-", "md", "nbd", "sr", "fd", "zd"}
+
+// discoverDisks lists writable whole disks below root, normally /sys/block
+func discoverDisks(root string) []*BlockDisk {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		log.Println("[collector] block discovery err:", err)
+		return nil
+	}
+	var disks []*BlockDisk
+	for _, e := range entries {
+		name := e.Name()
+		if skipBlock(name) {
+			continue
+		}
+		dir := root + "/" + name
+		if readSysString(dir+"/ro") == "1" {
+			continue
+		}
+		d := &BlockDisk{
+			Name:       name,
+			Model:      readSysString(dir + "/device/model"),
+			Vendor:     readSysString(dir + "/device/vendor"),
+			Serial:     readSysString(dir + "/device/serial"),
+			Rotational: readSysString(dir+"/queue/rotational") == "1",
+			Removable:  readSysString(dir+"/removable") == "1",
+		}
+		sectors, _ := strconv.ParseUint(readSysString(dir+"/size"), 10, 64)
+		if sectors == 0 {
+			// card readers and empty trays
+			continue
+		}
+		d.Size = sectors * 512
+		d.Wwn = blockWwn(dir)
+		devPath, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			devPath = dir
+		}
+		d.Transport = blockTransport(name, devPath)
+		disks = append(disks, d)
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Name < disks[j].Name })
+	return disks
+}
+
+func skipBlock(name string) bool {
+	return hasAnyPrefix(name, blockSkipPrefix)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockWwn prefers the naa/eui identifier, scsi disks keep it in device/wwid, nvme namespaces in wwid
+func blockWwn(dir string) string {
+	for _, f := range []string{"/wwid", "/device/wwid"} {
+		w := readSysString(dir + f)
+		if w == "" {
+			continue
+		}
+		w = strings.TrimPrefix(w, "naa.")
+		w = strings.TrimPrefix(w, "eui.")
+		w = strings.TrimPrefix(w, "0x")
+		// t10 ids are vendor, model and serial padded by spaces
+		return strings.Join(strings.Fields(w), " ")
+	}
+	return readSysString(dir + "/device/wwn")
+}
+
+// blockTransport guesses the bus from the resolved sysfs path of the disk
+func blockTransport(name, devPath string) string {
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return "nvme"
+	case strings.HasPrefix(name, "mmcblk"):
+		return "mmc"
+	case strings.HasPrefix(name, "vd") || strings.Contains(devPath, "/virtio"):
+		return "virtio"
+	case strings.Contains(devPath, "/usb"):
+		return "usb"
+	case strings.Contains(devPath, "/ata"):
+		return "sata"
+	case strings.Contains(devPath, "/end_device-") || strings.Contains(devPath, "/port-"):
+		return "sas"
+	}
+	return "scsi"
+}
+
+// watchBlockUevents signals hotplug of whole disks, netlink may be unavailable without host network
+func watchBlockUevents(hotplug chan<- struct{}) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		log.Println("[collector] uevent socket err:", err)
+		return
+	}
+	defer syscall.Close(fd)
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		log.Println("[collector] uevent bind err:", err)
+		return
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR || err == syscall.ENOBUFS {
+				continue
+			}
+			log.Println("[collector] uevent read err:", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		ev := parseUevent(buf[:n])
+		if ev["SUBSYSTEM"] != "block" || ev["DEVTYPE"] != "disk" || skipBlock(ev["DEVNAME"]) {
+			continue
+		}
+		switch ev["ACTION"] {
+		case "add", "remove":
+			select {
+			case hotplug <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// parseUevent reads kernel messages "action@devpath\0KEY=value\0..."
+func parseUevent(b []byte) map[string]string {
+	ev := map[string]string{}
+	for _, f := range bytes.Split(b, []byte{0}) {
+		k, v, ok := strings.Cut(string(f), "=")
+		if ok && k != "" && strings.ToUpper(k) == k {
+			ev[k] = v
+		}
+	}
+	if ev["DEVNAME"] != "" {
+		ev["DEVNAME"] = filepath.Base(ev["DEVNAME"])
+	}
+	return ev
+}
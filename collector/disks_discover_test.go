@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverDisks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"sda/size":              "19532873728",
+		"sda/ro":                "0",
+		"sda/queue/rotational":  "1",
+		"sda/device/model":      "ST10000NM0016-1TT101",
+		"sda/device/vendor":     "ATA     ",
+		"sda/device/wwid":       "naa.5000c500b1a84875",
+		"nvme0n1/size":          "1953525168",
+		"nvme0n1/ro":            "0",
+		"nvme0n1/wwid":          "eui.0025388b91b3c2a1",
+		"nvme0n1/device/model":  "Samsung SSD 970 EVO Plus 1TB",
+		"nvme0n1/device/serial": "S4EWNF0M123456",
+		"sdb/size":              "0",
+		"sdc/size":              "1000",
+		"sdc/ro":                "1",
+		"loop0/size":            "1000",
+		"dm-0/size":             "1000",
+		"zram0/size":            "1000",
+		"md127/size":            "1000",
+	}
+	for p, data := range files {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	disks := discoverDisks(root)
+	if len(disks) != 2 {
+		t.Fatalf("disks: %+v", disks)
+	}
+	nv, sda := disks[0], disks[1]
+	if nv.Name != "nvme0n1" || nv.Transport != "nvme" || nv.ID() != "0025388b91b3c2a1" || nv.Serial != "S4EWNF0M123456" || nv.Rotational {
+		t.Fatalf("nvme: %+v", nv)
+	}
+	if sda.Name != "sda" || sda.Size != 19532873728*512 || !sda.Rotational || sda.Vendor != "ATA" || sda.ID() != "5000c500b1a84875" {
+		t.Fatalf("sda: %+v", sda)
+	}
+}
+
+func TestBlockTransport(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct{ name, path, want string }{
+		{"sda", "/sys/devices/pci0000:00/0000:00:17.0/ata3/host2/target2:0:0/2:0:0:0/block/sda", "sata"},
+		{"sdb", "/sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdb", "usb"},
+		{"sdc", "/sys/devices/pci0000:00/0000:02:00.0/host0/port-0:0/expander-0:0/port-0:0:4/end_device-0:0:4/target0:0:4/0:0:4:0/block/sdc", "sas"},
+		{"vda", "/sys/devices/pci0000:00/0000:00:05.0/virtio2/block/vda", "virtio"},
+		{"sdd", "/sys/devices/pci0000:00/0000:00:04.0/virtio1/host0/target0:0:1/0:0:1:0/block/sdd", "virtio"},
+		{"nvme0n1", "/sys/devices/pci0000:00/0000:00:1d.0/0000:3d:00.0/nvme/nvme0/nvme0n1", "nvme"},
+		{"mmcblk0", "/sys/devices/platform/soc/mmc0/mmc0:aaaa/block/mmcblk0", "mmc"},
+		{"sde", "/sys/devices/pci0000:00/0000:00:10.0/host32/target32:0:0/32:0:0:0/block/sde", "scsi"},
+	}
+	for _, c := range cases {
+		if got := blockTransport(c.name, c.path); got != c.want {
+			t.Fatalf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	t.Parallel()
+
+	msg := "add@/devices/pci0000:00/0000:00:17.0/ata3/host2/target2:0:0/2:0:0:0/block/sdb\x00" +
+		"ACTION=add\x00DEVPATH=/devices/pci0000:00/0000:00:17.0/ata3/host2/target2:0:0/2:0:0:0/block/sdb\x00" +
+		"SUBSYSTEM=block\x00MAJOR=8\x00MINOR=16\x00DEVNAME=sdb\x00DEVTYPE=disk\x00SEQNUM=4021\x00"
+
+	ev := parseUevent([]byte(msg))
+	if ev["ACTION"] != "add" || ev["SUBSYSTEM"] != "block" || ev["DEVTYPE"] != "disk" || ev["DEVNAME"] != "sdb" {
+		t.Fatalf("uevent: %v", ev)
+	}
+}
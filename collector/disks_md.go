@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RaidMDSys is the array as the kernel sees it in /sys/block/mdX/md
+type RaidMDSys struct {
+	Level        string         `json:"level"`
+	ArrayState   string         `json:"arrayState"` // clean, active, active-idle, readonly, inactive
+	RaidDisks    int            `json:"raidDisks"`
+	Degraded     int            `json:"degraded"`     // missing members
+	SyncAction   string         `json:"syncAction"`   // idle, resync, recover, check, repair, reshape, frozen
+	SyncProgress float64        `json:"syncProgress"` // percent
+	SyncSpeed    int            `json:"syncSpeed"`    // KiB/s
+	SyncEta      int            `json:"syncEta"`      // seconds
+	MismatchCnt  uint64         `json:"mismatchCnt"`
+	Members      []RaidMDMember `json:"members"`
+}
+
+type RaidMDMember struct {
+	Name   string `json:"name"`
+	Slot   int    `json:"slot"` // -1 not in the array
+	Role   string `json:"role"` // active, write-mostly, rebuilding, spare, faulty
+	State  string `json:"state"`
+	Errors uint64 `json:"errors"` // corrected read errors
+}
+
+type RaidStateChange struct {
+	Time    time.Time  `json:"time"`
+	Array   string     `json:"array"`
+	Changes []string   `json:"changes"`
+	Prev    *RaidMDSys `json:"prev"`
+	Cur     *RaidMDSys `json:"cur"`
+}
+
+// collectRaidMD polls md sysfs every few seconds, mdadm -D stays in the slow disks cycle
+func (c *Collector) collectRaidMD() {
+	prev := map[string]*RaidMDSys{}
+	for range time.Tick(5 * time.Second) {
+		arrays, _ := filepath.Glob(pathBlock + "/md*/md")
+		cur := make(map[string]*RaidMDSys, len(arrays))
+		for _, dir := range arrays {
+			md := filepath.Base(filepath.Dir(dir))
+			sys := readMdSys(dir)
+			cur[md] = sys
+			p, ok := prev[md]
+			var changes []string
+			if ok {
+				changes = mdChanges(p, sys)
+			} else {
+				// first sight after start or assembly, an array already in trouble is reported once
+				changes = mdInitialChanges(sys)
+			}
+			if len(changes) > 0 {
+				c.ChanRaidState <- &RaidStateChange{Time: time.Now().UTC(), Array: md, Changes: changes, Prev: p, Cur: sys}
+			}
+		}
+		for md, p := range prev {
+			if _, ok := cur[md]; !ok {
+				c.ChanRaidState <- &RaidStateChange{Time: time.Now().UTC(), Array: md, Changes: []string{"array stopped"}, Prev: p}
+			}
+		}
+		prev = cur
+	}
+}
+
+func readMdSys(dir string) *RaidMDSys {
+	s := &RaidMDSys{
+		Level:      readSysString(dir + "/level"),
+		ArrayState: readSysString(dir + "/array_state"),
+		SyncAction: readSysString(dir + "/sync_action"),
+	}
+	s.RaidDisks, _ = strconv.Atoi(readSysString(dir + "/raid_disks"))
+	s.Degraded, _ = strconv.Atoi(readSysString(dir + "/degraded"))
+	s.MismatchCnt, _ = strconv.ParseUint(readSysString(dir+"/mismatch_cnt"), 10, 64)
+
+	// sync_completed is "done / total" in sectors or "none"
+	if done, total, ok := strings.Cut(readSysString(dir+"/sync_completed"), "/"); ok {
+		d, _ := strconv.ParseFloat(strings.TrimSpace(done), 64)
+		t, _ := strconv.ParseFloat(strings.TrimSpace(total), 64)
+		if t > 0 {
+			s.SyncProgress = d * 100 / t
+			s.SyncSpeed, _ = strconv.Atoi(readSysString(dir + "/sync_speed"))
+			if s.SyncSpeed > 0 {
+				s.SyncEta = int((t - d) / 2 / float64(s.SyncSpeed))
+			}
+		}
+	}
+
+	members, _ := filepath.Glob(dir + "/dev-*")
+	for _, m := range members {
+		mm := RaidMDMember{
+			Name:  strings.TrimPrefix(filepath.Base(m), "dev-"),
+			Slot:  -1,
+			State: readSysString(m + "/state"),
+		}
+		if slot, err := strconv.Atoi(readSysString(m + "/slot")); err == nil {
+			mm.Slot = slot
+		}
+		mm.Errors, _ = strconv.ParseUint(readSysString(m+"/errors"), 10, 64)
+		mm.Role = mdMemberRole(mm.State, mm.Slot)
+		s.Members = append(s.Members, mm)
+	}
+	sort.Slice(s.Members, func(i, j int) bool { return s.Members[i].Name < s.Members[j].Name })
+	return s
+}
+
+// mdMemberRole reduces the comma separated state flags of md/dev-*/state
+func mdMemberRole(state string, slot int) string {
+	flags := map[string]bool{}
+	for _, f := range strings.Split(state, ",") {
+		flags[f] = true
+	}
+	switch {
+	case flags["faulty"]:
+		return "faulty"
+	case flags["in_sync"] && flags["write_mostly"]:
+		return "write-mostly"
+	case flags["in_sync"]:
+		return "active"
+	case slot >= 0:
+		return "rebuilding"
+	}
+	return "spare"
+}
+
+// mdChanges lists what is worth an immediate event: degradation, sync start and end, member roles
+func mdChanges(prev, cur *RaidMDSys) []string {
+	var changes []string
+	if cur.Degraded != prev.Degraded {
+		if cur.Degraded > prev.Degraded {
+			changes = append(changes, fmt.Sprintf("degraded: %d of %d members missing", cur.Degraded, cur.RaidDisks))
+		} else {
+			changes = append(changes, fmt.Sprintf("restored: %d of %d members missing", cur.Degraded, cur.RaidDisks))
+		}
+	}
+	if cur.ArrayState != prev.ArrayState && (cur.ArrayState == "inactive" || cur.ArrayState == "readonly" || prev.ArrayState == "inactive") {
+		changes = append(changes, "array state: "+prev.ArrayState+" -> "+cur.ArrayState)
+	}
+	if cur.SyncAction != prev.SyncAction {
+		switch {
+		case prev.SyncAction == "idle":
+			changes = append(changes, cur.SyncAction+" started")
+		case cur.SyncAction == "idle" && (prev.SyncAction == "check" || prev.SyncAction == "repair"):
+			changes = append(changes, fmt.Sprintf("%s finished, mismatch count %d", prev.SyncAction, cur.MismatchCnt))
+		case cur.SyncAction == "idle":
+			changes = append(changes, prev.SyncAction+" finished")
+		default:
+			changes = append(changes, "sync action: "+prev.SyncAction+" -> "+cur.SyncAction)
+		}
+	}
+
+	roles := map[string]string{}
+	for _, m := range prev.Members {
+		roles[m.Name] = m.Role
+	}
+	for _, m := range cur.Members {
+		was, ok := roles[m.Name]
+		switch {
+		case !ok:
+			changes = append(changes, "member "+m.Name+" added as "+m.Role)
+		case was != m.Role:
+			changes = append(changes, "member "+m.Name+": "+was+" -> "+m.Role)
+		}
+		delete(roles, m.Name)
+	}
+	removed := make([]string, 0, len(roles))
+	for name := range roles {
+		removed = append(removed, "member "+name+" removed")
+	}
+	sort.Strings(removed)
+	return append(changes, removed...)
+}
+
+// mdInitialChanges lists what mdChanges would have reported had the array been seen healthy and idle before
+func mdInitialChanges(cur *RaidMDSys) []string {
+	var changes []string
+	if cur.Degraded > 0 {
+		changes = append(changes, fmt.Sprintf("degraded: %d of %d members missing", cur.Degraded, cur.RaidDisks))
+	}
+	if cur.ArrayState == "inactive" || cur.ArrayState == "readonly" {
+		changes = append(changes, "array state: "+cur.ArrayState)
+	}
+	if cur.SyncAction != "" && cur.SyncAction != "idle" {
+		changes = append(changes, cur.SyncAction+" running")
+	}
+	for _, m := range cur.Members {
+		if m.Role == "faulty" || m.Role == "rebuilding" {
+			changes = append(changes, "member "+m.Name+": "+m.Role)
+		}
+	}
+	return changes
+}
+
+func mdSysDir(md string) string {
+	dir := pathBlock + "/" + md + "/md"
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
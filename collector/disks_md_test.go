@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadMdSys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"level":           "raid1",
+		"array_state":     "clean",
+		"raid_disks":      "2",
+		"degraded":        "1",
+		"sync_action":     "recover",
+		"sync_completed":  "976773120 / 1953546240",
+		"sync_speed":      "120000",
+		"mismatch_cnt":    "0",
+		"dev-sda1/state":  "in_sync",
+		"dev-sda1/slot":   "0",
+		"dev-sdb1/state":  "faulty",
+		"dev-sdb1/slot":   "none",
+		"dev-sdb1/errors": "17",
+		"dev-sdc1/state":  "",
+		"dev-sdc1/slot":   "1",
+		"dev-sdd1/state":  "spare",
+		"dev-sdd1/slot":   "none",
+	}
+	for p, data := range files {
+		p = filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := readMdSys(dir)
+	if s.Level != "raid1" || s.RaidDisks != 2 || s.Degraded != 1 || s.SyncAction != "recover" {
+		t.Fatalf("array: %+v", s)
+	}
+	if int(s.SyncProgress) != 50 || s.SyncSpeed != 120000 || s.SyncEta != 4069 {
+		t.Fatalf("sync: %v%% %d KiB/s eta %d", s.SyncProgress, s.SyncSpeed, s.SyncEta)
+	}
+	roles := []string{"active", "faulty", "rebuilding", "spare"}
+	if len(s.Members) != len(roles) {
+		t.Fatalf("members: %+v", s.Members)
+	}
+	for i, m := range s.Members {
+		if m.Role != roles[i] {
+			t.Fatalf("member %s: %s, want %s", m.Name, m.Role, roles[i])
+		}
+	}
+	if s.Members[1].Errors != 17 || s.Members[1].Slot != -1 || s.Members[2].Slot != 1 {
+		t.Fatalf("members: %+v", s.Members)
+	}
+}
+
+func TestMdChanges(t *testing.T) {
+	t.Parallel()
+
+	healthy := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, SyncAction: "idle", Members: []RaidMDMember{
+		{Name: "sda1", Role: "active"},
+		{Name: "sdb1", Role: "active"},
+	}}
+	if ch := mdChanges(healthy, healthy); len(ch) != 0 {
+		t.Fatalf("no change: %v", ch)
+	}
+
+	failed := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, Degraded: 1, SyncAction: "idle", Members: []RaidMDMember{
+		{Name: "sda1", Role: "active"},
+		{Name: "sdb1", Role: "faulty"},
+	}}
+	want := []string{"degraded: 1 of 2 members missing", "member sdb1: active -> faulty"}
+	if ch := mdChanges(healthy, failed); len(ch) != 2 || ch[0] != want[0] || ch[1] != want[1] {
+		t.Fatalf("failed: %v", ch)
+	}
+
+	rebuild := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, Degraded: 1, SyncAction: "recover", Members: []RaidMDMember{
+		{Name: "sda1", Role: "active"},
+		{Name: "sdc1", Role: "rebuilding"},
+	}}
+	want = []string{"recover started", "member sdc1 added as rebuilding", "member sdb1 removed"}
+	ch := mdChanges(failed, rebuild)
+	if len(ch) != 3 || ch[0] != want[0] || ch[1] != want[1] || ch[2] != want[2] {
+		t.Fatalf("rebuild: %v", ch)
+	}
+
+	check := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, SyncAction: "check", Members: healthy.Members}
+	done := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, SyncAction: "idle", MismatchCnt: 128, Members: healthy.Members}
+	if ch := mdChanges(check, done); len(ch) != 1 || ch[0] != "check finished, mismatch count 128" {
+		t.Fatalf("check: %v", ch)
+	}
+}
+
+func TestMdInitialChanges(t *testing.T) {
+	t.Parallel()
+
+	healthy := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, SyncAction: "idle", Members: []RaidMDMember{
+		{Name: "sda1", Role: "active"},
+		{Name: "sdb1", Role: "active"},
+	}}
+	if ch := mdInitialChanges(healthy); len(ch) != 0 {
+		t.Fatalf("healthy: %v", ch)
+	}
+
+	// agent restarted while the array rebuilds onto a new disk
+	rebuild := &RaidMDSys{ArrayState: "clean", RaidDisks: 2, Degraded: 1, SyncAction: "recover", Members: []RaidMDMember{
+		{Name: "sda1", Role: "active"},
+		{Name: "sdc1", Role: "rebuilding"},
+	}}
+	want := []string{"degraded: 1 of 2 members missing", "recover running", "member sdc1: rebuilding"}
+	if ch := mdInitialChanges(rebuild); strings.Join(ch, "|") != strings.Join(want, "|") {
+		t.Fatalf("rebuild: %v", ch)
+	}
+
+	inactive := &RaidMDSys{ArrayState: "inactive", RaidDisks: 2, Degraded: 2, SyncAction: ""}
+	want = []string{"degraded: 2 of 2 members missing", "array state: inactive"}
+	if ch := mdInitialChanges(inactive); strings.Join(ch, "|") != strings.Join(want, "|") {
+		t.Fatalf("inactive: %v", ch)
+	}
+}
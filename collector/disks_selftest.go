@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+type SmartSelfTest struct {
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	Passed        bool   `json:"passed"`
+	LifetimeHours int64  `json:"lifetimeHours"`
+	LBA           int64  `json:"lba,omitempty"` // first failing block
+}
+
+// SelfTestRun is a self-test started by the agent, kept until the next one on the same device
+type SelfTestRun struct {
+	Device    string         `json:"device"`
+	Type      string         `json:"type"`
+	Started   time.Time      `json:"started"`
+	Finished  time.Time      `json:"finished,omitzero"`
+	Running   bool           `json:"running"`
+	Remaining int            `json:"remaining"` // percent
+	Result    *SmartSelfTest `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+var selfTestMax = map[string]time.Duration{
+	"short":      30 * time.Minute,
+	"conveyance": time.Hour,
+	"long":       72 * time.Hour,
+}
+
+const selfTestPoll = 30 * time.Second
+
+// RunSelfTest starts smartctl -t and blocks until the drive logs the result, progress gets percent done
+func (c *Collector) RunSelfTest(dev, kind string, progress func(percent int, message string)) (*SelfTestRun, error) {
+	maxDur, ok := selfTestMax[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown self-test type %q, use short, long or conveyance", kind)
+	}
+
+	c.disksMu.Lock()
+	if !slices.Contains(c.disks, dev) {
+		c.disksMu.Unlock()
+		return nil, fmt.Errorf("unknown disk %q", dev)
+	}
+	if prev := c.selfTests[dev]; prev != nil && prev.Running {
+		c.disksMu.Unlock()
+		return nil, fmt.Errorf("%s self-test already running on %s", prev.Type, dev)
+	}
+	run := &SelfTestRun{Device: dev, Type: kind, Started: time.Now().UTC(), Running: true, Remaining: 100}
+	c.selfTests[dev] = run
+	c.disksMu.Unlock()
+
+	before := c.smartDisk(dev)
+	out, err := exec.Command("sh", "-c", "smartctl -t "+kind+" /dev/"+dev).CombinedOutput()
+	if err != nil {
+		return c.finishSelfTest(run, nil, fmt.Errorf("smartctl -t %s: %v | out: %s", kind, err, out))
+	}
+	log.Printf("[collector] %s self-test started on %s", kind, dev)
+
+	deadline := time.Now().Add(maxDur)
+	seenRunning := false
+	for time.Now().Before(deadline) {
+		time.Sleep(selfTestPoll)
+		sd := c.smartDisk(dev)
+		if sd.Error != "" {
+			continue
+		}
+		if sd.SelfTestRunning {
+			seenRunning = true
+			c.disksMu.Lock()
+			run.Remaining = sd.SelfTestRemaining
+			c.disksMu.Unlock()
+			if progress != nil {
+				progress(100-sd.SelfTestRemaining, fmt.Sprintf("%d%% remaining", sd.SelfTestRemaining))
+			}
+			continue
+		}
+		if selfTestDone(before.SelfTestLog, sd, seenRunning) {
+			return c.finishSelfTest(run, &sd.SelfTestLog[0], nil)
+		}
+	}
+	return c.finishSelfTest(run, nil, fmt.Errorf("%s self-test on %s not logged after %s", kind, dev, maxDur))
+}
+
+func (c *Collector) finishSelfTest(run *SelfTestRun, res *SmartSelfTest, err error) (*SelfTestRun, error) {
+	c.disksMu.Lock()
+	defer c.disksMu.Unlock()
+	run.Running = false
+	run.Finished = time.Now().UTC()
+	run.Result = res
+	if err != nil {
+		run.Error = err.Error()
+		log.Println("[collector] self-test err:", err)
+		return nil, err
+	}
+	run.Remaining = 0
+	log.Printf("[collector] %s self-test on %s: %s", run.Type, run.Device, res.Status)
+	copied := *run
+	return &copied, nil
+}
+
+// selfTestDone tells whether the newest log entry is the result of the test started, once the drive reported
+// it in progress any idle state is the end, otherwise the log must differ from the one seen before start,
+// a full log keeps its length and a test in the same hour with the same status repeats the newest entry
+func selfTestDone(before []SmartSelfTest, sd *SmartDisk, seenRunning bool) bool {
+	if sd.SelfTestRunning || len(sd.SelfTestLog) == 0 {
+		return false
+	}
+	return seenRunning || !slices.Equal(before, sd.SelfTestLog)
+}
+
+// selfTestRuns copies runs for DisksInfo
+func (c *Collector) selfTestRuns() map[string]*SelfTestRun {
+	c.disksMu.Lock()
+	defer c.disksMu.Unlock()
+	if len(c.selfTests) == 0 {
+		return nil
+	}
+	runs := make(map[string]*SelfTestRun, len(c.selfTests))
+	for dev, run := range c.selfTests {
+		copied := *run
+		runs[dev] = &copied
+	}
+	return runs
+}
+
+// scheduleSelfTests runs SMART_SELFTEST_WEEKLY type once a week on every disk,
+// disks get evenly spread slots of the week so members of one array are not busy together
+func (c *Collector) scheduleSelfTests() {
+	kind := os.Getenv("SMART_SELFTEST_WEEKLY")
+	if kind == "" {
+		return
+	}
+	if _, ok := selfTestMax[kind]; !ok {
+		log.Printf("[collector] SMART_SELFTEST_WEEKLY %q unknown, use short, long or conveyance", kind)
+		return
+	}
+
+	// slots already passed this week wait for the next one
+	lastRun := map[string]time.Time{}
+	started := time.Now()
+
+	for now := range time.Tick(10 * time.Minute) {
+		c.disksMu.Lock()
+		disks := slices.Clone(c.disks)
+		c.disksMu.Unlock()
+
+		for i, dev := range disks {
+			slot := selfTestSlot(now, i, len(disks))
+			if slot.After(now) || slot.Before(started) || !lastRun[dev].Before(slot) {
+				continue
+			}
+			lastRun[dev] = now
+			go func() {
+				_, _ = c.RunSelfTest(dev, kind, nil)
+			}()
+		}
+	}
+}
+
+// selfTestSlot is the start of the i-th of n slots in the week of now, weeks start on monday utc
+func selfTestSlot(now time.Time, i, n int) time.Time {
+	now = now.UTC()
+	day := (int(now.Weekday()) + 6) % 7
+	week := time.Date(now.Year(), now.Month(), now.Day()-day, 0, 0, 0, 0, time.UTC)
+	return week.Add(time.Duration(i) * 7 * 24 * time.Hour / time.Duration(n))
+}
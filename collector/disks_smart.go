@@ -0,0 +1,318 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type SmartAttr struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Worst      int    `json:"worst"`
+	Thresh     int    `json:"thresh"`
+	WhenFailed string `json:"whenFailed,omitempty"`
+	Raw        int64  `json:"raw"`
+	RawString  string `json:"rawString"`
+}
+
+type SmartNvme struct {
+	CriticalWarning  int    `json:"criticalWarning"` // bits: 0 spare, 1 temperature, 2 reliability, 3 read-only, 4 volatile backup
+	AvailableSpare   int    `json:"availableSpare"`
+	SpareThreshold   int    `json:"spareThreshold"`
+	MediaErrors      int64  `json:"mediaErrors"`
+	ErrorLogEntries  int64  `json:"errorLogEntries"`
+	UnsafeShutdowns  int64  `json:"unsafeShutdowns"`
+	DataUnitsRead    uint64 `json:"dataUnitsRead"` // units of 512000 bytes
+	DataUnitsWritten uint64 `json:"dataUnitsWritten"`
+}
+
+// smartctl --json output, only what is used
+type smartctlJson struct {
+	Smartctl struct {
+		Version    []int `json:"version"`
+		ExitStatus int   `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+		Output []string `json:"output"` // the text report, --json=o
+	} `json:"smartctl"`
+	Device struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelFamily  string `json:"model_family"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	Wwn          struct {
+		Naa int   `json:"naa"`
+		Oui int64 `json:"oui"`
+		ID  int64 `json:"id"`
+	} `json:"wwn"`
+	UserCapacity struct {
+		Bytes uint64 `json:"bytes"`
+	} `json:"user_capacity"`
+	NvmeTotalCapacity uint64 `json:"nvme_total_capacity"`
+	SmartStatus       *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	PowerOnTime struct {
+		Hours int64 `json:"hours"`
+	} `json:"power_on_time"`
+	PowerCycleCount int64 `json:"power_cycle_count"`
+	Temperature     struct {
+		Current *int `json:"current"`
+	} `json:"temperature"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Value      int    `json:"value"`
+			Worst      int    `json:"worst"`
+			Thresh     int    `json:"thresh"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value  int64  `json:"value"`
+				String string `json:"string"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	AtaSmartData struct {
+		SelfTest struct {
+			Status struct {
+				Value            int    `json:"value"`
+				String           string `json:"string"`
+				RemainingPercent *int   `json:"remaining_percent"`
+			} `json:"status"`
+		} `json:"self_test"`
+	} `json:"ata_smart_data"`
+	AtaSmartSelfTestLog struct {
+		Standard struct {
+			Table []struct {
+				Type struct {
+					String string `json:"string"`
+				} `json:"type"`
+				Status struct {
+					String string `json:"string"`
+					Passed bool   `json:"passed"`
+				} `json:"status"`
+				LifetimeHours int64 `json:"lifetime_hours"`
+				LBA           int64 `json:"lba"`
+			} `json:"table"`
+		} `json:"standard"`
+	} `json:"ata_smart_self_test_log"`
+	NvmeSelfTestLog *struct {
+		CurrentSelfTestOperation struct {
+			Value int `json:"value"`
+		} `json:"current_self_test_operation"`
+		CurrentSelfTestCompletionPercent int `json:"current_self_test_completion_percent"`
+		Table                            []struct {
+			SelfTestCode struct {
+				String string `json:"string"`
+			} `json:"self_test_code"`
+			SelfTestResult struct {
+				Value  int    `json:"value"`
+				String string `json:"string"`
+			} `json:"self_test_result"`
+			PowerOnHours int64 `json:"power_on_hours"`
+			LBA          int64 `json:"lba"`
+		} `json:"table"`
+	} `json:"nvme_self_test_log"`
+	NvmeSmartHealthInformationLog *struct {
+		CriticalWarning         int    `json:"critical_warning"`
+		Temperature             int    `json:"temperature"`
+		AvailableSpare          int    `json:"available_spare"`
+		AvailableSpareThreshold int    `json:"available_spare_threshold"`
+		PercentageUsed          int    `json:"percentage_used"`
+		DataUnitsRead           uint64 `json:"data_units_read"`
+		DataUnitsWritten        uint64 `json:"data_units_written"`
+		PowerCycles             int64  `json:"power_cycles"`
+		PowerOnHours            int64  `json:"power_on_hours"`
+		UnsafeShutdowns         int64  `json:"unsafe_shutdowns"`
+		MediaErrors             int64  `json:"media_errors"`
+		NumErrLogEntries        int64  `json:"num_err_log_entries"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// parseSmartJson builds SmartDisk from smartctl --json=co --all, text fields keep the shape parseSmart gives
+// and Full stays the text report
+func (c *Collector) parseSmartJson(data []byte) (*SmartDisk, error) {
+	var sj smartctlJson
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return nil, fmt.Errorf("smartctl json unmarshal err: %v", err)
+	}
+	if len(sj.Smartctl.Version) == 0 {
+		return nil, fmt.Errorf("smartctl json: no version, not a smartctl output")
+	}
+
+	sd := &SmartDisk{
+		Full:          strings.Join(append(sj.Smartctl.Output, ""), "\n"),
+		Device:        sj.Device.Name,
+		Protocol:      sj.Device.Protocol,
+		ExitStatus:    sj.Smartctl.ExitStatus,
+		Serial:        sj.SerialNumber,
+		PowerOnHours:  sj.PowerOnTime.Hours,
+		PowerCycles:   sj.PowerCycleCount,
+		Reallocated:   -1,
+		Pending:       -1,
+		Uncorrectable: -1,
+		CRCErrors:     -1,
+		PercentUsed:   -1,
+	}
+
+	// bits 0-1: command line did not parse or device open failed, nothing else is there
+	if sj.Smartctl.ExitStatus&0b11 != 0 {
+		var msgs []string
+		for _, m := range sj.Smartctl.Messages {
+			msgs = append(msgs, m.String)
+		}
+		sd.Error = fmt.Sprintf("err: exit status %d | out: %s", sj.Smartctl.ExitStatus, strings.Join(msgs, "; "))
+		return sd, nil
+	}
+
+	sd.Model = sj.ModelName
+	if sj.ModelFamily != "" {
+		sd.Model = sj.ModelFamily
+		if sj.ModelName != "" {
+			sd.Model += " (" + sj.ModelName + ")"
+		}
+	}
+	if sj.Wwn.Naa != 0 {
+		sd.Wwn = fmt.Sprintf("%x%06x%09x", sj.Wwn.Naa, sj.Wwn.Oui, sj.Wwn.ID)
+	}
+
+	capacity := sj.UserCapacity.Bytes
+	if capacity == 0 {
+		capacity = sj.NvmeTotalCapacity
+	}
+	if capacity > 0 {
+		sd.CapacityBytes = capacity
+		sd.Capacity = humanBytesSI(capacity)
+	}
+
+	if sj.SmartStatus != nil {
+		sd.Health = "FAILED"
+		if sj.SmartStatus.Passed {
+			sd.Health = "OK"
+		}
+	}
+
+	if sj.Temperature.Current != nil {
+		sd.TemperatureC = *sj.Temperature.Current
+		sd.Temperature = strconv.Itoa(sd.TemperatureC)
+	}
+
+	for _, a := range sj.AtaSmartAttributes.Table {
+		sd.Attributes = append(sd.Attributes, SmartAttr{
+			ID:         a.ID,
+			Name:       a.Name,
+			Value:      a.Value,
+			Worst:      a.Worst,
+			Thresh:     a.Thresh,
+			WhenFailed: a.WhenFailed,
+			Raw:        a.Raw.Value,
+			RawString:  a.Raw.String,
+		})
+		switch a.ID {
+		case 5:
+			sd.Reallocated = a.Raw.Value
+		case 197:
+			sd.Pending = a.Raw.Value
+		case 198:
+			sd.Uncorrectable = a.Raw.Value
+		case 199:
+			sd.CRCErrors = a.Raw.Value
+		case 231, 233:
+			// life left for intel and other ssd, 231 wins when both are there
+			if a.Value <= 100 && (sd.PercentUsed < 0 || a.ID == 231) {
+				sd.PercentUsed = 100 - a.Value
+			}
+		}
+	}
+
+	if nv := sj.NvmeSmartHealthInformationLog; nv != nil {
+		sd.Nvme = &SmartNvme{
+			CriticalWarning:  nv.CriticalWarning,
+			AvailableSpare:   nv.AvailableSpare,
+			SpareThreshold:   nv.AvailableSpareThreshold,
+			MediaErrors:      nv.MediaErrors,
+			ErrorLogEntries:  nv.NumErrLogEntries,
+			UnsafeShutdowns:  nv.UnsafeShutdowns,
+			DataUnitsRead:    nv.DataUnitsRead,
+			DataUnitsWritten: nv.DataUnitsWritten,
+		}
+		sd.PercentUsed = nv.PercentageUsed
+		if sd.PowerOnHours == 0 {
+			sd.PowerOnHours = nv.PowerOnHours
+		}
+		if sd.PowerCycles == 0 {
+			sd.PowerCycles = nv.PowerCycles
+		}
+		if sj.Temperature.Current == nil && nv.Temperature > 0 {
+			sd.TemperatureC = nv.Temperature
+			sd.Temperature = strconv.Itoa(nv.Temperature)
+		}
+	}
+
+	// ata status high nibble 15 is in progress, low nibble tens of percent remaining
+	if st := sj.AtaSmartData.SelfTest.Status; st.Value>>4 == 15 {
+		sd.SelfTestRunning = true
+		sd.SelfTestRemaining = (st.Value & 0x0f) * 10
+		if st.RemainingPercent != nil {
+			sd.SelfTestRemaining = *st.RemainingPercent
+		}
+	}
+	for _, e := range sj.AtaSmartSelfTestLog.Standard.Table {
+		sd.SelfTestLog = append(sd.SelfTestLog, SmartSelfTest{
+			Type:          e.Type.String,
+			Status:        e.Status.String,
+			Passed:        e.Status.Passed,
+			LifetimeHours: e.LifetimeHours,
+			LBA:           e.LBA,
+		})
+	}
+	if nl := sj.NvmeSelfTestLog; nl != nil {
+		if nl.CurrentSelfTestOperation.Value != 0 {
+			sd.SelfTestRunning = true
+			sd.SelfTestRemaining = 100 - nl.CurrentSelfTestCompletionPercent
+		}
+		for _, e := range nl.Table {
+			sd.SelfTestLog = append(sd.SelfTestLog, SmartSelfTest{
+				Type:          e.SelfTestCode.String,
+				Status:        e.SelfTestResult.String,
+				Passed:        e.SelfTestResult.Value == 0,
+				LifetimeHours: e.PowerOnHours,
+				LBA:           e.LBA,
+			})
+		}
+	}
+
+	if sd.PercentUsed >= 0 {
+		sd.Used = fmt.Sprintf("%d%%", sd.PercentUsed)
+	}
+	sd.Working = sd.PowerOnHours * 3600
+
+	return sd, nil
+}
+
+// humanBytesSI formats like smartctl does in brackets: 10.0 TB, 500 GB
+func humanBytesSI(b uint64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	v := float64(b)
+	i := 0
+	for v >= 1000 && i < len(units)-1 {
+		v /= 1000
+		i++
+	}
+	switch {
+	case i == 0 || v >= 100:
+		return fmt.Sprintf("%.0f %s", v, units[i])
+	case v >= 10:
+		return fmt.Sprintf("%.1f %s", v, units[i])
+	}
+	return fmt.Sprintf("%.2f %s", v, units[i])
+}
@@ -1,7 +1,9 @@
 package collector
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseSmart(t *testing.T) {
@@ -717,6 +719,269 @@ If Selective self-test is pending on power-up, resume after 0 minute delay.
 	}
 }
 
+func TestParseSmartJson(t *testing.T) {
+	t.Parallel()
+
+	ata := `{"json_format_version":[1,0],"smartctl":{"version":[7,3],"svn_revision":"5338",
+"platform_info":"x86_64-linux-6.1.0-13-amd64","build_info":"(local build)",
+"argv":["smartctl","--json=co","--all","/dev/sda"],"exit_status":0,
+"output":["smartctl 7.3 2022-02-28 r5338 [x86_64-linux-6.1.0-13-amd64] (local build)","",
+"=== START OF INFORMATION SECTION ===","Device Model:     ST10000NM0016-1TT101","Serial Number:    ZA27LJ8H"]},
+"device":{"name":"/dev/sda","info_name":"/dev/sda [SAT]","type":"sat","protocol":"ATA"},
+"model_family":"Seagate Enterprise Capacity 3.5 HDD","model_name":"ST10000NM0016-1TT101",
+"serial_number":"ZA27LJ8H","wwn":{"naa":5,"oui":3152,"id":2980595829},"firmware_version":"SND0",
+"user_capacity":{"blocks":19532873728,"bytes":10000831348736},"logical_block_size":512,
+"physical_block_size":4096,"rotation_rate":7200,
+"smart_status":{"passed":true},
+"ata_smart_attributes":{"revision":10,"table":[
+{"id":1,"name":"Raw_Read_Error_Rate","value":81,"worst":64,"thresh":44,"when_failed":"",
+"flags":{"value":15,"string":"POSR-- ","prefailure":true,"updated_online":true,"performance":true,
+"error_rate":true,"event_count":false,"auto_keep":false},"raw":{"value":116617481,"string":"116617481"}},
+{"id":5,"name":"Reallocated_Sector_Ct","value":100,"worst":100,"thresh":10,"when_failed":"",
+"flags":{"value":51,"string":"PO--CK "},"raw":{"value":8,"string":"8"}},
+{"id":9,"name":"Power_On_Hours","value":60,"worst":60,"thresh":0,"when_failed":"",
+"flags":{"value":50,"string":"-O--CK "},"raw":{"value":35459,"string":"35459 (77 134 0)"}},
+{"id":12,"name":"Power_Cycle_Count","value":100,"worst":100,"thresh":20,"when_failed":"",
+"flags":{"value":50,"string":"-O--CK "},"raw":{"value":72,"string":"72"}},
+{"id":194,"name":"Temperature_Celsius","value":44,"worst":55,"thresh":0,"when_failed":"",
+"flags":{"value":34,"string":"-O---K "},"raw":{"value":51539607596,"string":"44 (0 12 0 0 0)"}},
+{"id":197,"name":"Current_Pending_Sector","value":100,"worst":100,"thresh":0,"when_failed":"",
+"flags":{"value":18,"string":"-O--C- "},"raw":{"value":2,"string":"2"}},
+{"id":198,"name":"Offline_Uncorrectable","value":100,"worst":100,"thresh":0,"when_failed":"",
+"flags":{"value":16,"string":"----C- "},"raw":{"value":0,"string":"0"}},
+{"id":199,"name":"UDMA_CRC_Error_Count","value":200,"worst":200,"thresh":0,"when_failed":"",
+"flags":{"value":62,"string":"-OSRCK "},"raw":{"value":3,"string":"3"}}]},
+"power_on_time":{"hours":35459},"power_cycle_count":72,"temperature":{"current":44}}`
+
+	s, err := (&Collector{}).parseSmartJson([]byte(ata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Model != "Seagate Enterprise Capacity 3.5 HDD (ST10000NM0016-1TT101)" || s.Serial != "ZA27LJ8H" ||
+		s.Capacity != "10.0 TB" || s.Health != "OK" || s.Temperature != "44" || s.Working != 35459*3600 {
+		t.Fatalf("error compatible fields ata: %+v", s)
+	}
+	if s.Wwn != "5000c500b1a84875" || s.TemperatureC != 44 || s.PowerOnHours != 35459 || s.PowerCycles != 72 ||
+		s.Reallocated != 8 || s.Pending != 2 || s.Uncorrectable != 0 || s.CRCErrors != 3 || s.PercentUsed != -1 {
+		t.Fatalf("error typed fields ata: %+v", s)
+	}
+	if len(s.Attributes) != 8 || s.Attributes[0].Worst != 64 || s.Attributes[0].Thresh != 44 ||
+		s.Attributes[4].RawString != "44 (0 12 0 0 0)" {
+		t.Fatalf("error attributes ata: %+v", s.Attributes)
+	}
+	if !strings.HasPrefix(s.Full, "smartctl 7.3 2022-02-28 r5338") || !strings.HasSuffix(s.Full, "Serial Number:    ZA27LJ8H\n") {
+		t.Fatalf("error full text report ata: %q", s.Full)
+	}
+
+	nvme := `{"json_format_version":[1,0],"smartctl":{"version":[7,3],"exit_status":0},
+"device":{"name":"/dev/nvme0","info_name":"/dev/nvme0","type":"nvme","protocol":"NVMe"},
+"model_name":"Samsung SSD 960 EVO 500GB","serial_number":"S3X4NB0K402977T","firmware_version":"3B7QCXE7",
+"nvme_pci_vendor":{"id":5197,"subsystem_id":5197},"nvme_total_capacity":500107862016,
+"nvme_unallocated_capacity":0,"nvme_controller_id":2,"nvme_version":{"string":"1.2","value":66048},
+"nvme_number_of_namespaces":1,
+"nvme_namespaces":[{"id":1,"size":{"blocks":976773168,"bytes":500107862016}}],
+"user_capacity":{"blocks":976773168,"bytes":500107862016},
+"smart_support":{"available":true,"enabled":true},"smart_status":{"passed":true,"nvme":{"value":0}},
+"nvme_smart_health_information_log":{"critical_warning":4,"temperature":53,"available_spare":100,
+"available_spare_threshold":10,"percentage_used":9,"data_units_read":74461804,
+"data_units_written":101693959,"host_reads":1174678878,"host_writes":2139546706,
+"controller_busy_time":14207,"power_cycles":1751,"power_on_hours":27967,"unsafe_shutdowns":567,
+"media_errors":0,"num_err_log_entries":3676,"warning_temp_time":0,"critical_comp_time":0,
+"temperature_sensors":[53,73]},
+"temperature":{"current":53},"power_cycle_count":1751,"power_on_time":{"hours":27967}}`
+
+	s, err = (&Collector{}).parseSmartJson([]byte(nvme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Model != "Samsung SSD 960 EVO 500GB" || s.Capacity != "500 GB" || s.Health != "OK" ||
+		s.Used != "9%" || s.Temperature != "53" || s.Working != 27967*3600 {
+		t.Fatalf("error compatible fields nvme: %+v", s)
+	}
+	if s.Nvme == nil || s.Nvme.CriticalWarning != 4 || s.Nvme.ErrorLogEntries != 3676 ||
+		s.Nvme.DataUnitsWritten != 101693959 || s.Nvme.UnsafeShutdowns != 567 || s.PercentUsed != 9 {
+		t.Fatalf("error typed fields nvme: %+v", s.Nvme)
+	}
+	if s.Full != "" {
+		t.Fatalf("expected empty full without output array got: %q", s.Full)
+	}
+
+	failed := `{"json_format_version":[1,0],"smartctl":{"version":[7,3],"exit_status":2,
+"messages":[{"string":"Smartctl open device: /dev/sdz failed: No such device","severity":"error"}]}}`
+	s, err = (&Collector{}).parseSmartJson([]byte(failed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Error == "" || s.Health != "" {
+		t.Fatalf("expected open error got: %+v", s)
+	}
+
+	if _, err = (&Collector{}).parseSmartJson([]byte("smartctl 6.6 2016-05-31 r4324")); err == nil {
+		t.Fatal("expected error on text output")
+	}
+}
+
+func TestParseSmartJsonSelfTest(t *testing.T) {
+	t.Parallel()
+
+	ata := `{"smartctl":{"version":[7,3],"exit_status":0},
+"device":{"name":"/dev/sdb","type":"sat","protocol":"ATA"},"serial_number":"WD-1",
+"ata_smart_data":{"self_test":{"status":{"value":249,"string":"in progress, 90% remaining","remaining_percent":90},
+"polling_minutes":{"short":2,"extended":1090}}},
+"ata_smart_self_test_log":{"standard":{"revision":1,"table":[
+{"type":{"value":1,"string":"Short offline"},"status":{"value":121,"string":"Completed: read failure","remaining_percent":90,"passed":false},"lifetime_hours":41210,"lba":1953525160},
+{"type":{"value":2,"string":"Extended offline"},"status":{"value":0,"string":"Completed without error","passed":true},"lifetime_hours":41000}
+],"count":2}}}`
+
+	sd, err := (&Collector{}).parseSmartJson([]byte(ata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sd.SelfTestRunning || sd.SelfTestRemaining != 90 {
+		t.Fatalf("ata running %v remaining %d", sd.SelfTestRunning, sd.SelfTestRemaining)
+	}
+	if len(sd.SelfTestLog) != 2 {
+		t.Fatalf("ata log: %+v", sd.SelfTestLog)
+	}
+	want := SmartSelfTest{Type: "Short offline", Status: "Completed: read failure", LifetimeHours: 41210, LBA: 1953525160}
+	if sd.SelfTestLog[0] != want || !sd.SelfTestLog[1].Passed {
+		t.Fatalf("ata log: %+v", sd.SelfTestLog)
+	}
+
+	nvme := `{"smartctl":{"version":[7,4],"exit_status":0},
+"device":{"name":"/dev/nvme0","type":"nvme","protocol":"NVMe"},"serial_number":"S1",
+"nvme_self_test_log":{"current_self_test_operation":{"value":0,"string":"No self-test in progress"},
+"table":[{"self_test_code":{"value":1,"string":"Short"},"self_test_result":{"value":0,"string":"Completed without error"},"power_on_hours":812}]}}`
+
+	sd, err = (&Collector{}).parseSmartJson([]byte(nvme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sd.SelfTestRunning {
+		t.Fatal("nvme running")
+	}
+	want = SmartSelfTest{Type: "Short", Status: "Completed without error", Passed: true, LifetimeHours: 812}
+	if len(sd.SelfTestLog) != 1 || sd.SelfTestLog[0] != want {
+		t.Fatalf("nvme log: %+v", sd.SelfTestLog)
+	}
+}
+
+func TestSelfTestSlot(t *testing.T) {
+	t.Parallel()
+
+	// wednesday
+	now := time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	for i, want := range []time.Time{monday, monday.Add(42 * time.Hour), monday.Add(84 * time.Hour), monday.Add(126 * time.Hour)} {
+		if got := selfTestSlot(now, i, 4); !got.Equal(want) {
+			t.Fatalf("slot %d: %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSelfTestDone(t *testing.T) {
+	t.Parallel()
+
+	// full log of 21 entries, the new short test ends in the same hour with the same status
+	entry := SmartSelfTest{Type: "Short offline", Status: "Completed without error", Passed: true, LifetimeHours: 35459}
+	full := make([]SmartSelfTest, 21)
+	for i := range full {
+		full[i] = entry
+	}
+	idle := &SmartDisk{SelfTestLog: full}
+	if selfTestDone(full, idle, false) {
+		t.Fatal("unchanged log taken as done")
+	}
+	if !selfTestDone(full, idle, true) {
+		t.Fatal("idle after running not taken as done")
+	}
+	if selfTestDone(full, &SmartDisk{SelfTestRunning: true, SelfTestLog: full}, true) {
+		t.Fatal("running taken as done")
+	}
+
+	// finished between polls, the log shifted
+	shifted := append([]SmartSelfTest{{Type: "Short offline", Status: "Completed: read failure", LifetimeHours: 35459}}, full[:20]...)
+	if !selfTestDone(full, &SmartDisk{SelfTestLog: shifted}, false) {
+		t.Fatal("shifted log not taken as done")
+	}
+}
+
+func TestSmartHistoryObserve(t *testing.T) {
+	t.Parallel()
+
+	h := &smartHistory{Samples: map[string][]smartSample{}, tempMax: 60}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	disk := func(realloc, crc int64, used, temp int) *SmartDisk {
+		return &SmartDisk{
+			Serial:        "S1",
+			Model:         "SSD",
+			Health:        "OK",
+			Reallocated:   realloc,
+			Pending:       -1,
+			Uncorrectable: -1,
+			CRCErrors:     crc,
+			PercentUsed:   used,
+			TemperatureC:  temp,
+		}
+	}
+
+	if ch := h.observe("/dev/sda", disk(0, 1, 10, 40), now); ch != nil {
+		t.Fatalf("first sample: change %+v", ch)
+	}
+	if ch := h.observe("/dev/sda", disk(0, 1, 10, 45), now.Add(24*time.Hour)); ch != nil {
+		t.Fatalf("same counters: change %+v", ch)
+	}
+
+	sd := disk(4, 3, 12, 65)
+	ch := h.observe("/dev/sda", sd, now.Add(48*time.Hour))
+	if ch == nil {
+		t.Fatal("no change reported")
+	}
+	want := map[string][2]int64{
+		"reallocated": {0, 4},
+		"crcErrors":   {1, 3},
+		"percentUsed": {10, 12},
+		"temperature": {45, 65},
+	}
+	if len(ch.Changes) != len(want) {
+		t.Fatalf("changes: %+v", ch.Changes)
+	}
+	for _, c := range ch.Changes {
+		if w, ok := want[c.Counter]; !ok || w[0] != c.From || w[1] != c.To {
+			t.Fatalf("change %+v", c)
+		}
+	}
+
+	tr := sd.Trend
+	if tr.ReallocatedGrowth != 4 || tr.CRCGrowth != 2 || tr.PendingGrowth != 0 {
+		t.Fatalf("growth: %+v", tr)
+	}
+	if tr.WearPerDay != 1 || tr.DaysToWearOut != 88 {
+		t.Fatalf("wear: %v per day, %v days", tr.WearPerDay, tr.DaysToWearOut)
+	}
+	if tr.TempMax != 65 || tr.TempExcursions != 1 {
+		t.Fatalf("temp: max %d, excursions %d", tr.TempMax, tr.TempExcursions)
+	}
+
+	if ch = h.observe("/dev/sda", &SmartDisk{Serial: "S2", PercentUsed: -1}, now); ch != nil {
+		t.Fatalf("other serial: change %+v", ch)
+	}
+
+	sd = disk(4, 3, 12, 50)
+	sd.Health = "FAILED"
+	ch = h.observe("/dev/sda", sd, now.Add(72*time.Hour))
+	if ch == nil || len(ch.Changes) != 1 || ch.Changes[0].Counter != "health" ||
+		ch.Changes[0].FromStatus != "OK" || ch.Changes[0].ToStatus != "FAILED" {
+		t.Fatalf("health change: %+v", ch)
+	}
+
+	// S2 was swapped out a month ago, S1 is still in place
+	h.prune(now.Add(72*time.Hour + smartHistoryRetention))
+	if _, ok := h.Samples["S2"]; ok || len(h.Samples["S1"]) != 4 {
+		t.Fatalf("prune kept %v", h.Samples)
+	}
+}
+
 func TestParseMdStat(t *testing.T) {
 	t.Parallel()
 
@@ -1024,3 +1289,249 @@ func TestParseZfs(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestParseZfsNested(t *testing.T) {
+	t.Parallel()
+
+	js := []byte(`{
+  "output_version": { "command": "zpool list", "vers_major": 0, "vers_minor": 1 },
+  "pools": {
+    "fast": {
+      "name": "fast",
+      "type": "POOL",
+      "state": "ONLINE",
+      "vdevs": {
+        "raidz1-0": {
+          "name": "raidz1-0",
+          "vdev_type": "raidz",
+          "class": "normal",
+          "state": "DEGRADED",
+          "properties": { "size": { "value": "3000000000000" } },
+          "vdevs": {
+            "/dev/sda": { "name": "/dev/sda", "vdev_type": "disk", "state": "ONLINE" },
+            "replacing-1": {
+              "name": "replacing-1",
+              "vdev_type": "replacing",
+              "state": "DEGRADED",
+              "vdevs": {
+                "/dev/sdb": { "name": "/dev/sdb", "vdev_type": "disk", "state": "FAULTED" },
+                "/dev/sdc": { "name": "/dev/sdc", "vdev_type": "disk", "state": "ONLINE" }
+              }
+            }
+          }
+        }
+      },
+      "special": {
+        "mirror-1": {
+          "name": "mirror-1",
+          "vdev_type": "mirror",
+          "state": "ONLINE",
+          "vdevs": {
+            "/dev/nvme0n1": { "name": "/dev/nvme0n1", "vdev_type": "disk", "state": "ONLINE" },
+            "/dev/nvme1n1": { "name": "/dev/nvme1n1", "vdev_type": "disk", "state": "ONLINE" }
+          }
+        }
+      },
+      "logs": {
+        "/dev/sdd": { "name": "/dev/sdd", "vdev_type": "disk", "class": "logs", "state": "ONLINE" }
+      }
+    }
+  }
+}`)
+
+	rds, err := (&Collector{}).parseZfs(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rds) != 3 {
+		t.Fatalf("vdevs: %+v", rds)
+	}
+	if rds[0].Name != "raidz1-0" || rds[0].Capacity != 3000000000000 || len(rds[0].Devs) != 3 || rds[0].Devs[1] != (RaidZFSDevs{Name: "sdb", State: "faulted"}) {
+		t.Fatalf("raidz: %+v", rds[0])
+	}
+	if rds[1].Name != "mirror-1" || rds[1].Class != "special" || len(rds[1].Devs) != 2 {
+		t.Fatalf("special: %+v", rds[1])
+	}
+	if rds[2].Name != "/dev/sdd" || rds[2].Class != "logs" || len(rds[2].Devs) != 1 || rds[2].Devs[0].Name != "sdd" {
+		t.Fatalf("log: %+v", rds[2])
+	}
+}
+
+func TestParseZpoolStatus(t *testing.T) {
+	t.Parallel()
+
+	js := []byte(`{
+  "output_version": { "command": "zpool status", "vers_major": 0, "vers_minor": 1 },
+  "pools": {
+    "tank": {
+      "name": "tank",
+      "state": "DEGRADED",
+      "pool_guid": "3089852543256794290",
+      "txg": "1035955",
+      "spa_version": "5000",
+      "zpl_version": "5",
+      "status": "One or more devices has experienced an unrecoverable error.",
+      "action": "Determine if the device needs to be replaced, and clear the errors\nusing 'zpool clear' or replace the device with 'zpool replace'.",
+      "msgid": "ZFS-8000-9P",
+      "moreinfo": "https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-9P",
+      "scan_stats": {
+        "function": "RESILVER",
+        "state": "SCANNING",
+        "start_time": "1718000000",
+        "end_time": "0",
+        "to_examine": "1000000000",
+        "examined": "600000000",
+        "skipped": "0",
+        "processed": "4096",
+        "errors": "0",
+        "issued": "250000000"
+      },
+      "vdevs": {
+        "tank": {
+          "name": "tank",
+          "vdev_type": "root",
+          "state": "DEGRADED",
+          "read_errors": "0",
+          "write_errors": "0",
+          "checksum_errors": "0",
+          "vdevs": {
+            "mirror-0": {
+              "name": "mirror-0",
+              "vdev_type": "mirror",
+              "class": "normal",
+              "state": "DEGRADED",
+              "vdevs": {
+                "/dev/sdc2": { "name": "/dev/sdc2", "vdev_type": "disk", "state": "ONLINE", "read_errors": "0", "write_errors": "0", "checksum_errors": "0", "slow_ios": "0" },
+                "/dev/sdd2": { "name": "/dev/sdd2", "vdev_type": "disk", "state": "FAULTED", "read_errors": "3", "write_errors": "1", "checksum_errors": "27", "slow_ios": "5" }
+              }
+            }
+          }
+        }
+      },
+      "error_count": "2"
+    },
+    "backup": {
+      "name": "backup",
+      "state": "ONLINE",
+      "scan_stats": {
+        "function": "SCRUB",
+        "state": "FINISHED",
+        "start_time": "Sun Jun  9 00:24:01 2024",
+        "end_time": "Sun Jun  9 02:10:45 2024",
+        "to_examine": "500",
+        "issued": "500",
+        "processed": "0",
+        "errors": "0"
+      },
+      "vdevs": {
+        "backup": {
+          "name": "backup",
+          "vdev_type": "root",
+          "state": "ONLINE",
+          "vdevs": {
+            "/dev/sde": { "name": "/dev/sde", "vdev_type": "disk", "state": "ONLINE", "read_errors": "0", "write_errors": "0", "checksum_errors": "0" }
+          }
+        }
+      },
+      "error_count": "0"
+    }
+  }
+}`)
+
+	pools, err := (&Collector{}).parseZpoolStatus(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 || pools[0].Name != "backup" || pools[1].Name != "tank" {
+		t.Fatalf("pools: %+v", pools)
+	}
+
+	backup := pools[0]
+	if backup.Problem != "" || backup.Scan == nil || backup.Scan.Function != "scrub" || backup.Scan.Progress != 100 {
+		t.Fatalf("backup: %+v %+v", backup, backup.Scan)
+	}
+	if backup.Scan.End.Sub(backup.Scan.Start) != time.Hour+46*time.Minute+44*time.Second {
+		t.Fatalf("backup scrub: %v - %v", backup.Scan.Start, backup.Scan.End)
+	}
+	if len(backup.Vdevs) != 1 || backup.Vdevs[0].Name != "sde" || backup.Vdevs[0].Top != "/dev/sde" {
+		t.Fatalf("backup vdevs: %+v", backup.Vdevs)
+	}
+
+	tank := pools[1]
+	if tank.State != "degraded" || tank.MsgID != "ZFS-8000-9P" || tank.DataErrors != 2 || tank.Problem == "" {
+		t.Fatalf("tank: %+v", tank)
+	}
+	scan := tank.Scan
+	if scan.Function != "resilver" || scan.State != "scanning" || scan.Progress != 25 || scan.Repaired != 4096 || !scan.End.IsZero() || scan.Start.Unix() != 1718000000 {
+		t.Fatalf("tank scan: %+v", scan)
+	}
+	want := ZfsVdevStatus{Name: "sdd2", Top: "mirror-0", Class: "normal", State: "faulted", ReadErrors: 3, WriteErrors: 1, ChecksumErrors: 27, SlowIOs: 5}
+	if len(tank.Vdevs) != 2 || tank.Vdevs[1] != want {
+		t.Fatalf("tank vdevs: %+v", tank.Vdevs)
+	}
+}
+
+func TestParseZfsList(t *testing.T) {
+	t.Parallel()
+
+	js := []byte(`{
+  "output_version": { "command": "zfs list", "vers_major": 0, "vers_minor": 1 },
+  "datasets": {
+    "tank/vm-100-disk-0": {
+      "name": "tank/vm-100-disk-0",
+      "type": "VOLUME",
+      "pool": "tank",
+      "createtxg": "3391",
+      "properties": {
+        "used": { "value": "34359738368", "source": { "type": "NONE", "data": "-" } },
+        "available": { "value": "600000000000", "source": { "type": "NONE", "data": "-" } },
+        "referenced": { "value": "12884901888", "source": { "type": "NONE", "data": "-" } },
+        "quota": { "value": "-", "source": { "type": "NONE", "data": "-" } },
+        "compressratio": { "value": "1.52", "source": { "type": "NONE", "data": "-" } },
+        "mountpoint": { "value": "-", "source": { "type": "NONE", "data": "-" } }
+      }
+    },
+    "tank": {
+      "name": "tank",
+      "type": "FILESYSTEM",
+      "pool": "tank",
+      "createtxg": "1",
+      "properties": {
+        "used": { "value": "177686913024", "source": { "type": "NONE", "data": "-" } },
+        "available": { "value": "600000000000", "source": { "type": "NONE", "data": "-" } },
+        "referenced": { "value": "98304", "source": { "type": "NONE", "data": "-" } },
+        "quota": { "value": "1099511627776", "source": { "type": "LOCAL", "data": "-" } },
+        "compressratio": { "value": "1.00x", "source": { "type": "NONE", "data": "-" } },
+        "mountpoint": { "value": "/tank", "source": { "type": "DEFAULT", "data": "-" } }
+      }
+    }
+  }
+}`)
+
+	ds, err := (&Collector{}).parseZfsList(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ZfsDataset{
+		{Name: "tank", Type: "filesystem", Mountpoint: "/tank", Used: 177686913024, Available: 600000000000, Referenced: 98304, Quota: 1099511627776, CompressRatio: 1},
+		{Name: "tank/vm-100-disk-0", Type: "volume", Used: 34359738368, Available: 600000000000, Referenced: 12884901888, CompressRatio: 1.52},
+	}
+	if len(ds) != len(want) || ds[0] != want[0] || ds[1] != want[1] {
+		t.Fatalf("datasets: %+v", ds)
+	}
+}
+
+func TestZfsArcStats(t *testing.T) {
+	t.Parallel()
+
+	prev := map[string]uint64{"hits": 1000, "misses": 500}
+	cur := map[string]uint64{"size": 4 << 30, "c": 6 << 30, "c_max": 8 << 30, "hits": 1900, "misses": 600}
+
+	as := zfsArcStats(cur, prev)
+	if as.Hits != 900 || as.Misses != 100 || as.HitRate != 90 || as.Size != 4<<30 || as.Target != 6<<30 || as.Max != 8<<30 {
+		t.Fatalf("arc: %+v", as)
+	}
+	if as = zfsArcStats(cur, map[string]uint64{}); as.Hits != 1900 || as.HitRate != 1900.0*100/2500 {
+		t.Fatalf("arc first cycle: %+v", as)
+	}
+}
@@ -0,0 +1,389 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// BlockTopology is the holder/slave graph of block devices with what lvm, dm-crypt and caches build on it
+type BlockTopology struct {
+	Devices        map[string]*BlockNode `json:"devices"` // by kernel name
+	VolumeGroups   []LvmVG               `json:"volumeGroups,omitempty"`
+	LogicalVolumes []LvmLV               `json:"logicalVolumes,omitempty"`
+	Crypts         []CryptMapping        `json:"crypts,omitempty"`
+	Caches         []CacheDevice         `json:"caches,omitempty"`
+}
+
+type BlockNode struct {
+	Name    string   `json:"name"`  // kernel name, dm-3
+	Label   string   `json:"label"` // vg0/root, luks-data, sda1
+	Kind    string   `json:"kind"`  // disk, part, md, lvm, crypt, cache, bcache, multipath, dm
+	Layer   string   `json:"layer,omitempty"`
+	Size    uint64   `json:"size"`
+	Slaves  []string `json:"slaves,omitempty"`  // devices below
+	Holders []string `json:"holders,omitempty"` // devices above
+}
+
+type LvmVG struct {
+	Name    string `json:"name"`
+	Size    uint64 `json:"size"`
+	Free    uint64 `json:"free"`
+	PVCount int    `json:"pvCount"`
+	LVCount int    `json:"lvCount"`
+}
+
+type LvmLV struct {
+	VG              string  `json:"vg"`
+	Name            string  `json:"name"`
+	Device          string  `json:"device,omitempty"` // dm-N, empty when inactive
+	Type            string  `json:"type"`             // linear, striped, raid1, thin-pool, thin, cache, empty without lvs
+	Size            uint64  `json:"size"`
+	Pool            string  `json:"pool,omitempty"`
+	DataPercent     float64 `json:"dataPercent"`     // thin pool, thin volume or cache usage, -1 not reported
+	MetadataPercent float64 `json:"metadataPercent"` // thin pool or cache, -1 not reported
+}
+
+type CryptMapping struct {
+	Name    string   `json:"name"`
+	Device  string   `json:"device"`
+	Type    string   `json:"type"` // LUKS1, LUKS2, PLAIN
+	Backing []string `json:"backing"`
+}
+
+type CacheDevice struct {
+	Name      string   `json:"name"`
+	Device    string   `json:"device"`
+	Kind      string   `json:"kind"` // bcache, dm-cache
+	Backing   []string `json:"backing"`
+	Cache     []string `json:"cache"`
+	Mode      string   `json:"mode,omitempty"`
+	State     string   `json:"state,omitempty"`
+	DirtyData string   `json:"dirtyData,omitempty"`
+}
+
+var topologySkipPrefix = []string{"loop", "ram", "zram", "sr", "fd", "nbd"}
+
+// collectTopology keeps labels fresh for io and space stats, lvm reports go with disks info
+func (c *Collector) collectTopology() {
+	for {
+		t := readTopology("/sys")
+		c.mu.Lock()
+		c.blockLabels = t.Labels()
+		c.mu.Unlock()
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// readTopology walks sysRoot/class/block, partitions included
+func readTopology(sysRoot string) *BlockTopology {
+	t := &BlockTopology{Devices: map[string]*BlockNode{}}
+	base := sysRoot + "/class/block"
+	entries, _ := os.ReadDir(base)
+	uuids := map[string]string{}
+
+	for _, e := range entries {
+		name := e.Name()
+		if hasAnyPrefix(name, topologySkipPrefix) {
+			continue
+		}
+		dir := base + "/" + name
+		n := &BlockNode{Name: name, Label: name, Kind: "disk"}
+		sectors, _ := strconv.ParseUint(readSysString(dir+"/size"), 10, 64)
+		n.Size = sectors * 512
+		n.Slaves = dirNames(dir + "/slaves")
+		n.Holders = dirNames(dir + "/holders")
+
+		switch {
+		case fileExists(dir + "/partition"):
+			n.Kind = "part"
+		case strings.HasPrefix(name, "md"):
+			n.Kind = "md"
+		case strings.HasPrefix(name, "bcache"):
+			n.Kind = "bcache"
+		case strings.HasPrefix(name, "dm-"):
+			n.Kind = "dm"
+			dmName := readSysString(dir + "/dm/name")
+			uuid := readSysString(dir + "/dm/uuid")
+			uuids[name] = uuid
+			if dmName != "" {
+				n.Label = dmName
+			}
+			switch {
+			case strings.HasPrefix(uuid, "LVM-"):
+				n.Kind = "lvm"
+				vg, lv, layer := splitLvmName(dmName)
+				n.Label = vg + "/" + lv
+				if layer != "" {
+					n.Label += "-" + layer
+				}
+				// internal volumes carry the layer in the uuid only: pool_tdata is "LVM-<uuids>-tdata"
+				n.Layer = layer
+				if _, suffix, ok := strings.Cut(strings.TrimPrefix(uuid, "LVM-"), "-"); ok {
+					n.Layer = suffix
+				}
+			case strings.HasPrefix(uuid, "CRYPT-"):
+				n.Kind = "crypt"
+			case strings.HasPrefix(uuid, "mpath-"):
+				n.Kind = "multipath"
+			}
+		}
+		t.Devices[name] = n
+	}
+
+	for _, name := range sortedKeys(t.Devices) {
+		n := t.Devices[name]
+		switch n.Kind {
+		case "lvm":
+			if n.Layer != "" {
+				continue
+			}
+			vg, lv, _ := strings.Cut(n.Label, "/")
+			t.LogicalVolumes = append(t.LogicalVolumes, LvmLV{
+				VG: vg, Name: lv, Device: name, Size: n.Size,
+				DataPercent: -1, MetadataPercent: -1,
+			})
+			if cache := t.dmCache(n); cache != nil {
+				n.Kind = "cache"
+				t.Caches = append(t.Caches, *cache)
+			}
+		case "crypt":
+			// CRYPT-LUKS2-<uuid>-<name>
+			typ, _, _ := strings.Cut(strings.TrimPrefix(uuids[name], "CRYPT-"), "-")
+			t.Crypts = append(t.Crypts, CryptMapping{Name: n.Label, Device: name, Type: typ, Backing: t.labelsOf(n.Slaves)})
+		case "bcache":
+			t.Caches = append(t.Caches, bcacheDevice(base+"/"+name, n, t))
+		}
+	}
+
+	vgs := map[string]bool{}
+	for _, lv := range t.LogicalVolumes {
+		if !vgs[lv.VG] {
+			vgs[lv.VG] = true
+			t.VolumeGroups = append(t.VolumeGroups, LvmVG{Name: lv.VG})
+		}
+	}
+	for i := range t.VolumeGroups {
+		for _, lv := range t.LogicalVolumes {
+			if lv.VG == t.VolumeGroups[i].Name {
+				t.VolumeGroups[i].LVCount++
+			}
+		}
+	}
+	return t
+}
+
+// dmCache recognizes an lvm cache volume by its cdata and corig sub volumes
+func (t *BlockTopology) dmCache(n *BlockNode) *CacheDevice {
+	cd := &CacheDevice{Name: n.Label, Device: n.Name, Kind: "dm-cache"}
+	for _, s := range n.Slaves {
+		sub, ok := t.Devices[s]
+		if !ok {
+			continue
+		}
+		switch sub.Layer {
+		case "corig", "real":
+			cd.Backing = append(cd.Backing, sub.Label)
+		case "cdata", "cvol":
+			cd.Cache = append(cd.Cache, sub.Label)
+		}
+	}
+	if len(cd.Cache) == 0 {
+		return nil
+	}
+	return cd
+}
+
+// bcacheDevice reads the backing device of bcacheN and the cache set it is attached to
+func bcacheDevice(dir string, n *BlockNode, t *BlockTopology) CacheDevice {
+	cd := CacheDevice{Name: n.Name, Device: n.Name, Kind: "bcache", Backing: t.labelsOf(n.Slaves), Cache: []string{}}
+	bdir := dir + "/bcache"
+	cd.State = readSysString(bdir + "/state")
+	cd.DirtyData = readSysString(bdir + "/dirty_data")
+	// "writethrough [writeback] writearound none"
+	for _, m := range strings.Fields(readSysString(bdir + "/cache_mode")) {
+		if strings.HasPrefix(m, "[") {
+			cd.Mode = strings.Trim(m, "[]")
+		}
+	}
+	caches, _ := filepath.Glob(bdir + "/cache/cache[0-9]*")
+	for _, cdev := range caches {
+		if real, err := filepath.EvalSymlinks(cdev); err == nil {
+			// .../block/nvme0n1/nvme0n1p1/bcache
+			cd.Cache = append(cd.Cache, filepath.Base(filepath.Dir(real)))
+		}
+	}
+	return cd
+}
+
+func (t *BlockTopology) labelsOf(names []string) []string {
+	res := make([]string, 0, len(names))
+	for _, s := range names {
+		if n, ok := t.Devices[s]; ok {
+			res = append(res, n.Label)
+		} else {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Labels maps kernel names to human names, only where they differ
+func (t *BlockTopology) Labels() map[string]string {
+	labels := map[string]string{}
+	for name, n := range t.Devices {
+		if n.Label != name {
+			labels[name] = n.Label
+		}
+	}
+	return labels
+}
+
+// splitLvmName undoes dm escaping of lvm names: vg and lv are joined by "-", their own dashes doubled
+func splitLvmName(dm string) (vg, lv, layer string) {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(dm); i++ {
+		if dm[i] != '-' {
+			cur.WriteByte(dm[i])
+			continue
+		}
+		if i+1 < len(dm) && dm[i+1] == '-' {
+			cur.WriteByte('-')
+			i++
+			continue
+		}
+		parts = append(parts, cur.String())
+		cur.Reset()
+	}
+	parts = append(parts, cur.String())
+	switch len(parts) {
+	case 1:
+		return "", parts[0], ""
+	case 2:
+		return parts[0], parts[1], ""
+	}
+	return parts[0], parts[1], strings.Join(parts[2:], "-")
+}
+
+type lvmReport struct {
+	Report []struct {
+		LV []map[string]string `json:"lv"`
+		VG []map[string]string `json:"vg"`
+	} `json:"report"`
+}
+
+// mergeLvs takes lvs --reportformat json --units b --nosuffix -a
+// -o vg_name,lv_name,segtype,lv_size,pool_lv,data_percent,metadata_percent,lv_kernel_minor
+func (t *BlockTopology) mergeLvs(data []byte) error {
+	var r lvmReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("lvs json unmarshal err: %v", err)
+	}
+	var lvs []LvmLV
+	for _, rep := range r.Report {
+		for _, l := range rep.LV {
+			// hidden sub volumes like [pool_tdata] are in the graph already
+			if strings.HasPrefix(l["lv_name"], "[") {
+				continue
+			}
+			lv := LvmLV{
+				VG:              l["vg_name"],
+				Name:            l["lv_name"],
+				Type:            l["segtype"],
+				Pool:            l["pool_lv"],
+				DataPercent:     lvmPercent(l["data_percent"]),
+				MetadataPercent: lvmPercent(l["metadata_percent"]),
+			}
+			lv.Size, _ = strconv.ParseUint(l["lv_size"], 10, 64)
+			if minor, err := strconv.Atoi(l["lv_kernel_minor"]); err == nil && minor >= 0 {
+				lv.Device = "dm-" + strconv.Itoa(minor)
+			}
+			lvs = append(lvs, lv)
+		}
+	}
+	t.LogicalVolumes = lvs
+	return nil
+}
+
+// mergeVgs takes vgs --reportformat json --units b --nosuffix -o vg_name,vg_size,vg_free,pv_count,lv_count
+func (t *BlockTopology) mergeVgs(data []byte) error {
+	var r lvmReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("vgs json unmarshal err: %v", err)
+	}
+	var vgs []LvmVG
+	for _, rep := range r.Report {
+		for _, v := range rep.VG {
+			vg := LvmVG{Name: v["vg_name"]}
+			vg.Size, _ = strconv.ParseUint(v["vg_size"], 10, 64)
+			vg.Free, _ = strconv.ParseUint(v["vg_free"], 10, 64)
+			vg.PVCount, _ = strconv.Atoi(v["pv_count"])
+			vg.LVCount, _ = strconv.Atoi(v["lv_count"])
+			vgs = append(vgs, vg)
+		}
+	}
+	t.VolumeGroups = vgs
+	return nil
+}
+
+func lvmPercent(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// blockDeviceOf finds the kernel name of the block device a path lives on, empty for zfs, nfs, overlay
+func blockDeviceOf(sysRoot, path string) string {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return ""
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^uint64(0xfff)
+	minor := dev&0xff | (dev>>12)&^uint64(0xff)
+	return blockDeviceByNumber(sysRoot, fmt.Sprintf("%d:%d", major, minor))
+}
+
+// blockDeviceByNumber resolves "major:minor" through sysRoot/dev/block
+func blockDeviceByNumber(sysRoot, majMin string) string {
+	real, err := filepath.EvalSymlinks(sysRoot + "/dev/block/" + majMin)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(real)
+}
+
+func dirNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
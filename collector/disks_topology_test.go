@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReadTopology(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"sda/size":                  "1000",
+		"sda1/size":                 "900",
+		"sda1/partition":            "1",
+		"sda1/holders/md0":          "",
+		"sdb1/size":                 "900",
+		"sdb1/partition":            "1",
+		"sdb1/holders/md0":          "",
+		"md0/size":                  "900",
+		"md0/slaves/sda1":           "",
+		"md0/slaves/sdb1":           "",
+		"md0/holders/dm-0":          "",
+		"dm-0/size":                 "890",
+		"dm-0/dm/name":              "luks-data",
+		"dm-0/dm/uuid":              "CRYPT-LUKS2-1b7f9c0e2d4a4f8e9c1e0a7b6d5c4e3f-luks-data",
+		"dm-0/slaves/md0":           "",
+		"dm-1/size":                 "400",
+		"dm-1/dm/name":              "my--vg-root",
+		"dm-1/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"dm-1/slaves/dm-0":          "",
+		"dm-2/size":                 "300",
+		"dm-2/dm/name":              "my--vg-pool_tdata",
+		"dm-2/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaacccccccccccccccccccccccccccccccc-tdata",
+		"dm-3/size":                 "300",
+		"dm-3/dm/name":              "my--vg-pool-tpool",
+		"dm-3/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaadddddddddddddddddddddddddddddddd-tpool",
+		"dm-4/size":                 "200",
+		"dm-4/dm/name":              "my--vg-data_corig",
+		"dm-4/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee-real",
+		"dm-5/size":                 "50",
+		"dm-5/dm/name":              "my--vg-fast_cdata",
+		"dm-5/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaffffffffffffffffffffffffffffffff-cdata",
+		"dm-6/size":                 "200",
+		"dm-6/dm/name":              "my--vg-data",
+		"dm-6/dm/uuid":              "LVM-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1111111111111111111111111111111",
+		"dm-6/slaves/dm-4":          "",
+		"dm-6/slaves/dm-5":          "",
+		"bcache0/size":              "2000",
+		"bcache0/slaves/sdc":        "",
+		"bcache0/bcache/state":      "dirty",
+		"bcache0/bcache/dirty_data": "1.2M",
+		"bcache0/bcache/cache_mode": "writethrough [writeback] writearound none",
+		"loop0/size":                "100",
+	}
+	base := filepath.Join(root, "class", "block")
+	for p, data := range files {
+		p = filepath.Join(base, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topo := readTopology(root)
+	if _, ok := topo.Devices["loop0"]; ok {
+		t.Fatal("loop0 is not skipped")
+	}
+	kinds := map[string]string{"sda": "disk", "sda1": "part", "md0": "md", "dm-0": "crypt", "dm-1": "lvm", "dm-6": "cache", "bcache0": "bcache"}
+	for name, kind := range kinds {
+		if topo.Devices[name] == nil || topo.Devices[name].Kind != kind {
+			t.Fatalf("%s: %+v, want %s", name, topo.Devices[name], kind)
+		}
+	}
+
+	labels := topo.Labels()
+	want := map[string]string{
+		"dm-0": "luks-data",
+		"dm-1": "my-vg/root",
+		"dm-2": "my-vg/pool_tdata",
+		"dm-3": "my-vg/pool-tpool",
+		"dm-4": "my-vg/data_corig",
+		"dm-5": "my-vg/fast_cdata",
+		"dm-6": "my-vg/data",
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("labels: %v", labels)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Fatalf("label %s: %q, want %q", k, labels[k], v)
+		}
+	}
+	if topo.Devices["dm-2"].Layer != "tdata" || topo.Devices["dm-3"].Layer != "tpool" {
+		t.Fatalf("layers: %+v %+v", topo.Devices["dm-2"], topo.Devices["dm-3"])
+	}
+
+	if len(topo.LogicalVolumes) != 2 || topo.LogicalVolumes[0].Name != "root" || topo.LogicalVolumes[1].Name != "data" {
+		t.Fatalf("lvs: %+v", topo.LogicalVolumes)
+	}
+	if len(topo.VolumeGroups) != 1 || topo.VolumeGroups[0].Name != "my-vg" || topo.VolumeGroups[0].LVCount != 2 {
+		t.Fatalf("vgs: %+v", topo.VolumeGroups)
+	}
+
+	if len(topo.Crypts) != 1 || topo.Crypts[0].Type != "LUKS2" || topo.Crypts[0].Backing[0] != "md0" {
+		t.Fatalf("crypts: %+v", topo.Crypts)
+	}
+
+	if len(topo.Caches) != 2 {
+		t.Fatalf("caches: %+v", topo.Caches)
+	}
+	bc, dc := topo.Caches[0], topo.Caches[1]
+	if bc.Kind != "bcache" || bc.Mode != "writeback" || bc.State != "dirty" || bc.DirtyData != "1.2M" || bc.Backing[0] != "sdc" {
+		t.Fatalf("bcache: %+v", bc)
+	}
+	if dc.Kind != "dm-cache" || dc.Name != "my-vg/data" || dc.Backing[0] != "my-vg/data_corig" || dc.Cache[0] != "my-vg/fast_cdata" {
+		t.Fatalf("dm-cache: %+v", dc)
+	}
+}
+
+func TestSplitLvmName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct{ dm, vg, lv, layer string }{
+		{"vg0-root", "vg0", "root", ""},
+		{"my--vg-my--lv", "my-vg", "my-lv", ""},
+		{"vg0-pool-tpool", "vg0", "pool", "tpool"},
+		{"vg0-snap-cow", "vg0", "snap", "cow"},
+	}
+	for _, c := range cases {
+		vg, lv, layer := splitLvmName(c.dm)
+		if vg != c.vg || lv != c.lv || layer != c.layer {
+			t.Fatalf("%s: %q %q %q", c.dm, vg, lv, layer)
+		}
+	}
+}
+
+func TestMergeLvm(t *testing.T) {
+	t.Parallel()
+
+	lvs := []byte(`{
+      "report": [
+          {
+              "lv": [
+                  {"vg_name":"vg0", "lv_name":"root", "segtype":"linear", "lv_size":"21474836480", "pool_lv":"", "data_percent":"", "metadata_percent":"", "lv_kernel_minor":"1"},
+                  {"vg_name":"vg0", "lv_name":"pool", "segtype":"thin-pool", "lv_size":"107374182400", "pool_lv":"", "data_percent":"63.51", "metadata_percent":"12.08", "lv_kernel_minor":"4"},
+                  {"vg_name":"vg0", "lv_name":"[pool_tdata]", "segtype":"linear", "lv_size":"107374182400", "pool_lv":"", "data_percent":"", "metadata_percent":"", "lv_kernel_minor":"2"},
+                  {"vg_name":"vg0", "lv_name":"vm-100", "segtype":"thin", "lv_size":"34359738368", "pool_lv":"pool", "data_percent":"40.00", "metadata_percent":"", "lv_kernel_minor":"-1"}
+              ]
+          }
+      ]
+  }`)
+	vgs := []byte(`{"report":[{"vg":[{"vg_name":"vg0", "vg_size":"499570212864", "vg_free":"85899345920", "pv_count":"2", "lv_count":"3"}]}]}`)
+
+	topo := &BlockTopology{}
+	if err := topo.mergeLvs(lvs); err != nil {
+		t.Fatal(err)
+	}
+	if err := topo.mergeVgs(vgs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LvmLV{
+		{VG: "vg0", Name: "root", Device: "dm-1", Type: "linear", Size: 21474836480, DataPercent: -1, MetadataPercent: -1},
+		{VG: "vg0", Name: "pool", Device: "dm-4", Type: "thin-pool", Size: 107374182400, DataPercent: 63.51, MetadataPercent: 12.08},
+		{VG: "vg0", Name: "vm-100", Type: "thin", Size: 34359738368, Pool: "pool", DataPercent: 40, MetadataPercent: -1},
+	}
+	if len(topo.LogicalVolumes) != len(want) {
+		t.Fatalf("lvs: %+v", topo.LogicalVolumes)
+	}
+	for i := range want {
+		if topo.LogicalVolumes[i] != want[i] {
+			t.Fatalf("lv %d: %+v, want %+v", i, topo.LogicalVolumes[i], want[i])
+		}
+	}
+	if vg := topo.VolumeGroups[0]; len(topo.VolumeGroups) != 1 || vg.Size != 499570212864 || vg.Free != 85899345920 || vg.PVCount != 2 || vg.LVCount != 3 {
+		t.Fatalf("vgs: %+v", topo.VolumeGroups)
+	}
+}
+
+func TestBlockDeviceOf(t *testing.T) {
+	t.Parallel()
+
+	// the device of a temp file is whatever the test runs on, map its number to a fake disk
+	dir := t.TempDir()
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	dev := uint64(st.Dev)
+	majMin := fmt.Sprintf("%d:%d", (dev>>8)&0xfff|(dev>>32)&^uint64(0xfff), dev&0xff|(dev>>12)&^uint64(0xff))
+
+	sysRoot := t.TempDir()
+	disk := filepath.Join(sysRoot, "devices", "pci0000:00", "block", "sdz", "sdz1")
+	if err := os.MkdirAll(disk, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(sysRoot, "dev", "block"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(disk, filepath.Join(sysRoot, "dev", "block", majMin)); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := blockDeviceOf(sysRoot, dir); name != "sdz1" {
+		t.Fatalf("expected sdz1 got %q", name)
+	}
+	if name := blockDeviceByNumber(sysRoot, "0:99"); name != "" {
+		t.Fatalf("expected no device for an unknown number got %q", name)
+	}
+	if name := blockDeviceOf(sysRoot, filepath.Join(dir, "missing")); name != "" {
+		t.Fatalf("expected no device for a missing path got %q", name)
+	}
+}
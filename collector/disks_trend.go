@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// samples kept per drive, 15 minutes each is about a month
+const smartHistoryLen = 3000
+
+// drives not seen for this long are swapped out, their history is dropped
+const smartHistoryRetention = 31 * 24 * time.Hour
+
+type SmartTrend struct {
+	Since               time.Time `json:"since"` // oldest sample in history
+	ReallocatedGrowth   int64     `json:"reallocatedGrowth"`
+	PendingGrowth       int64     `json:"pendingGrowth"`
+	UncorrectableGrowth int64     `json:"uncorrectableGrowth"`
+	CRCGrowth           int64     `json:"crcGrowth"`
+	MediaErrorsGrowth   int64     `json:"mediaErrorsGrowth"`
+	WearPerDay          float64   `json:"wearPerDay"`    // percent used per day
+	DaysToWearOut       float64   `json:"daysToWearOut"` // -1 unknown or not wearing
+	TempMax             int       `json:"tempMax"`
+	TempExcursions      int       `json:"tempExcursions"` // samples over the limit
+}
+
+type SmartCounterChange struct {
+	Counter    string `json:"counter"`
+	From       int64  `json:"from"`
+	To         int64  `json:"to"`
+	FromStatus string `json:"fromStatus,omitempty"` // health only
+	ToStatus   string `json:"toStatus,omitempty"`
+}
+
+type DiskHealthChange struct {
+	Time    time.Time            `json:"time"`
+	Device  string               `json:"device"`
+	Serial  string               `json:"serial"`
+	Model   string               `json:"model"`
+	Health  string               `json:"health"`
+	Changes []SmartCounterChange `json:"changes"`
+	Trend   *SmartTrend          `json:"trend"`
+}
+
+type smartSample struct {
+	Time          time.Time `json:"t"`
+	Health        string    `json:"h"`
+	Reallocated   int64     `json:"re"`
+	Pending       int64     `json:"pe"`
+	Uncorrectable int64     `json:"un"`
+	CRCErrors     int64     `json:"crc"`
+	MediaErrors   int64     `json:"me"`
+	PercentUsed   int       `json:"used"`
+	TemperatureC  int       `json:"temp"`
+}
+
+// smartHistory keeps samples per serial on disk, a swapped drive in the same slot starts clean
+type smartHistory struct {
+	file    string
+	tempMax int
+	Samples map[string][]smartSample `json:"samples"`
+}
+
+func newSmartHistory() *smartHistory {
+	h := &smartHistory{
+		Samples: map[string][]smartSample{},
+		tempMax: 60,
+	}
+	if v, err := strconv.Atoi(os.Getenv("SMART_TEMP_MAX")); err == nil && v > 0 {
+		h.tempMax = v
+	}
+	dir := os.Getenv("STATE_DIR")
+	if dir == "" {
+		dir = "/var/lib/netip"
+	}
+	h.file = filepath.Join(dir, "smart-history.json")
+
+	data, err := os.ReadFile(h.file)
+	if err != nil {
+		return h
+	}
+	if err = json.Unmarshal(data, h); err != nil {
+		log.Println("[collector] smart history decode err:", err)
+		h.Samples = map[string][]smartSample{}
+	}
+	return h
+}
+
+func (h *smartHistory) save() {
+	if h.file == "" {
+		return
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		log.Println("[collector] smart history encode err:", err)
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(h.file), 0o700); err != nil {
+		log.Println("[collector] smart history dir err:", err)
+		return
+	}
+	tmp := h.file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err == nil {
+		err = os.Rename(tmp, h.file)
+	}
+	if err != nil {
+		log.Println("[collector] smart history save err:", err)
+	}
+}
+
+// observe appends the sample, fills sd.Trend and returns what moved since the previous sample
+func (h *smartHistory) observe(dev string, sd *SmartDisk, now time.Time) *DiskHealthChange {
+	if sd.Serial == "" || sd.Error != "" {
+		return nil
+	}
+	cur := smartSample{
+		Time:          now.UTC(),
+		Health:        sd.Health,
+		Reallocated:   sd.Reallocated,
+		Pending:       sd.Pending,
+		Uncorrectable: sd.Uncorrectable,
+		CRCErrors:     sd.CRCErrors,
+		MediaErrors:   -1,
+		PercentUsed:   sd.PercentUsed,
+		TemperatureC:  sd.TemperatureC,
+	}
+	if sd.Nvme != nil {
+		cur.MediaErrors = sd.Nvme.MediaErrors
+	}
+
+	samples := h.Samples[sd.Serial]
+	var changes []SmartCounterChange
+	if len(samples) > 0 {
+		prev := samples[len(samples)-1]
+		moved := func(name string, from, to int64) {
+			if from >= 0 && to >= 0 && from != to {
+				changes = append(changes, SmartCounterChange{Counter: name, From: from, To: to})
+			}
+		}
+		moved("reallocated", prev.Reallocated, cur.Reallocated)
+		moved("pending", prev.Pending, cur.Pending)
+		moved("uncorrectable", prev.Uncorrectable, cur.Uncorrectable)
+		moved("crcErrors", prev.CRCErrors, cur.CRCErrors)
+		moved("mediaErrors", prev.MediaErrors, cur.MediaErrors)
+		moved("percentUsed", int64(prev.PercentUsed), int64(cur.PercentUsed))
+		if prev.TemperatureC <= h.tempMax && cur.TemperatureC > h.tempMax {
+			changes = append(changes, SmartCounterChange{
+				Counter: "temperature",
+				From:    int64(prev.TemperatureC),
+				To:      int64(cur.TemperatureC),
+			})
+		}
+		if prev.Health != cur.Health && cur.Health != "" {
+			changes = append(changes, SmartCounterChange{
+				Counter:    "health",
+				FromStatus: prev.Health,
+				ToStatus:   cur.Health,
+			})
+		}
+	}
+
+	samples = append(samples, cur)
+	if len(samples) > smartHistoryLen {
+		samples = samples[len(samples)-smartHistoryLen:]
+	}
+	h.Samples[sd.Serial] = samples
+	sd.Trend = h.trend(samples)
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return &DiskHealthChange{
+		Time:    cur.Time,
+		Device:  dev,
+		Serial:  sd.Serial,
+		Model:   sd.Model,
+		Health:  sd.Health,
+		Changes: changes,
+		Trend:   sd.Trend,
+	}
+}
+
+// prune drops serials without a sample within the retention window
+func (h *smartHistory) prune(now time.Time) {
+	for serial, samples := range h.Samples {
+		if len(samples) == 0 || now.Sub(samples[len(samples)-1].Time) > smartHistoryRetention {
+			delete(h.Samples, serial)
+		}
+	}
+}
+
+func (h *smartHistory) trend(samples []smartSample) *SmartTrend {
+	first, last := samples[0], samples[len(samples)-1]
+	growth := func(a, b int64) int64 {
+		if a < 0 || b < 0 {
+			return 0
+		}
+		return b - a
+	}
+	t := &SmartTrend{
+		Since:               first.Time,
+		ReallocatedGrowth:   growth(first.Reallocated, last.Reallocated),
+		PendingGrowth:       growth(first.Pending, last.Pending),
+		UncorrectableGrowth: growth(first.Uncorrectable, last.Uncorrectable),
+		CRCGrowth:           growth(first.CRCErrors, last.CRCErrors),
+		MediaErrorsGrowth:   growth(first.MediaErrors, last.MediaErrors),
+		DaysToWearOut:       -1,
+	}
+	for _, s := range samples {
+		t.TempMax = max(t.TempMax, s.TemperatureC)
+		if s.TemperatureC > h.tempMax {
+			t.TempExcursions++
+		}
+	}
+
+	days := last.Time.Sub(first.Time).Hours() / 24
+	if days > 0 && first.PercentUsed >= 0 && last.PercentUsed >= 0 {
+		t.WearPerDay = float64(last.PercentUsed-first.PercentUsed) / days
+		if t.WearPerDay > 0 {
+			t.DaysToWearOut = max(0, float64(100-last.PercentUsed)/t.WearPerDay)
+		}
+	}
+	return t
+}
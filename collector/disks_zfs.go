@@ -0,0 +1,330 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ZfsProp struct {
+	Value  string `json:"value"`
+	Source struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	} `json:"source"`
+}
+
+// ZfsVdev is a vdev of zpool list -j or zpool status -j at any depth
+type ZfsVdev struct {
+	Name       string `json:"name"`
+	VdevType   string `json:"vdev_type"`
+	Guid       string `json:"guid"`
+	Path       string `json:"path"`
+	Class      string `json:"class"`
+	State      string `json:"state"`
+	Properties struct {
+		Size           ZfsProp `json:"size"`
+		Allocated      ZfsProp `json:"allocated"`
+		Free           ZfsProp `json:"free"`
+		Health         ZfsProp `json:"health"`
+		ReadErrors     ZfsProp `json:"read_errors"`
+		WriteErrors    ZfsProp `json:"write_errors"`
+		ChecksumErrors ZfsProp `json:"cksum_errors"`
+	} `json:"properties"`
+
+	// zpool status -j keeps counters on the vdev itself
+	ReadErrors     string `json:"read_errors"`
+	WriteErrors    string `json:"write_errors"`
+	ChecksumErrors string `json:"checksum_errors"`
+	SlowIOs        string `json:"slow_ios"`
+
+	Vdevs map[string]*ZfsVdev `json:"vdevs"`
+}
+
+type ZfsPoolStatus struct {
+	Name       string          `json:"name"`
+	State      string          `json:"state"`
+	Problem    string          `json:"problem,omitempty"` // what zpool status -x tells
+	Action     string          `json:"action,omitempty"`
+	MsgID      string          `json:"msgId,omitempty"`
+	DataErrors uint64          `json:"dataErrors"`
+	Scan       *ZfsScan        `json:"scan,omitempty"`
+	Vdevs      []ZfsVdevStatus `json:"vdevs"` // leaves only
+}
+
+type ZfsScan struct {
+	Function  string    `json:"function"` // scrub, resilver
+	State     string    `json:"state"`    // scanning, finished, canceled
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end,omitzero"`
+	Progress  float64   `json:"progress"` // percent issued of to examine
+	ToExamine uint64    `json:"toExamine"`
+	Examined  uint64    `json:"examined"`
+	Issued    uint64    `json:"issued"`
+	Repaired  uint64    `json:"repaired"` // bytes
+	Errors    uint64    `json:"errors"`
+}
+
+type ZfsVdevStatus struct {
+	Name           string `json:"name"`
+	Top            string `json:"top"` // top level vdev the leaf belongs to
+	Class          string `json:"class"`
+	State          string `json:"state"`
+	ReadErrors     uint64 `json:"readErrors"`
+	WriteErrors    uint64 `json:"writeErrors"`
+	ChecksumErrors uint64 `json:"checksumErrors"`
+	SlowIOs        uint64 `json:"slowIOs"`
+}
+
+type ZfsDataset struct {
+	Name          string  `json:"name"`
+	Type          string  `json:"type"` // filesystem, volume
+	Mountpoint    string  `json:"mountpoint,omitempty"`
+	Used          uint64  `json:"used"`
+	Available     uint64  `json:"available"`
+	Referenced    uint64  `json:"referenced"`
+	Quota         uint64  `json:"quota"` // 0 none
+	CompressRatio float64 `json:"compressRatio"`
+}
+
+type ZfsArcStats struct {
+	Size    uint64  `json:"size"`
+	Target  uint64  `json:"target"`
+	Max     uint64  `json:"max"`
+	Hits    uint64  `json:"hits"` // since the previous disks cycle
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"` // percent
+}
+
+var arcPrev = map[string]uint64{}
+
+// topVdevs lists top level vdevs of every allocation class, status -j wraps them in a root vdev
+func (zp *ZfsPool) topVdevs() []*ZfsVdev {
+	groups := []struct {
+		class string
+		vdevs map[string]*ZfsVdev
+	}{
+		{"normal", zp.Vdevs},
+		{"special", zp.Special},
+		{"dedup", zp.Dedup},
+		{"log", zp.Logs},
+		{"cache", zp.L2cache},
+		{"spare", zp.Spares},
+	}
+	var top []*ZfsVdev
+	for _, g := range groups {
+		for _, v := range sortedVdevs(g.vdevs) {
+			if v.VdevType == "root" {
+				for _, child := range sortedVdevs(v.Vdevs) {
+					if child.Class == "" {
+						child.Class = g.class
+					}
+					top = append(top, child)
+				}
+				continue
+			}
+			if v.Class == "" {
+				v.Class = g.class
+			}
+			top = append(top, v)
+		}
+	}
+	return top
+}
+
+// leaves walks down any depth, e.g. replacing-0 inside raidz or a mirror of the special class
+func (v *ZfsVdev) leaves() []*ZfsVdev {
+	if len(v.Vdevs) == 0 {
+		return []*ZfsVdev{v}
+	}
+	var res []*ZfsVdev
+	for _, child := range sortedVdevs(v.Vdevs) {
+		res = append(res, child.leaves()...)
+	}
+	return res
+}
+
+func (v *ZfsVdev) errorCounters() (read, write, cksum, slow uint64) {
+	pick := func(direct string, prop ZfsProp) uint64 {
+		if direct != "" {
+			return zfsUint(direct)
+		}
+		return zfsUint(prop.Value)
+	}
+	return pick(v.ReadErrors, v.Properties.ReadErrors),
+		pick(v.WriteErrors, v.Properties.WriteErrors),
+		pick(v.ChecksumErrors, v.Properties.ChecksumErrors),
+		zfsUint(v.SlowIOs)
+}
+
+func sortedVdevs(m map[string]*ZfsVdev) []*ZfsVdev {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*ZfsVdev, 0, len(m))
+	for _, k := range keys {
+		if m[k] != nil {
+			res = append(res, m[k])
+		}
+	}
+	return res
+}
+
+// parseZpoolStatus reads zpool status -jp
+func (c *Collector) parseZpoolStatus(data []byte) ([]ZfsPoolStatus, error) {
+	var out struct {
+		Pools map[string]struct {
+			ZfsPool
+			Status     string `json:"status"`
+			Action     string `json:"action"`
+			MsgID      string `json:"msgid"`
+			ErrorCount string `json:"error_count"`
+			ScanStats  *struct {
+				Function  string `json:"function"`
+				State     string `json:"state"`
+				StartTime string `json:"start_time"`
+				EndTime   string `json:"end_time"`
+				ToExamine string `json:"to_examine"`
+				Examined  string `json:"examined"`
+				Issued    string `json:"issued"`
+				Processed string `json:"processed"`
+				Errors    string `json:"errors"`
+			} `json:"scan_stats"`
+		} `json:"pools"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("zpool status json unmarshal err: %v", err)
+	}
+
+	names := make([]string, 0, len(out.Pools))
+	for name := range out.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]ZfsPoolStatus, 0, len(names))
+	for _, name := range names {
+		p := out.Pools[name]
+		ps := ZfsPoolStatus{
+			Name:       p.Name,
+			State:      strings.ToLower(p.State),
+			Problem:    strings.TrimSpace(p.Status),
+			Action:     strings.TrimSpace(p.Action),
+			MsgID:      p.MsgID,
+			DataErrors: zfsUint(p.ErrorCount),
+			Vdevs:      []ZfsVdevStatus{},
+		}
+		if s := p.ScanStats; s != nil && s.Function != "" && s.Function != "NONE" {
+			ps.Scan = &ZfsScan{
+				Function:  strings.ToLower(s.Function),
+				State:     strings.ToLower(s.State),
+				Start:     zfsTime(s.StartTime),
+				End:       zfsTime(s.EndTime),
+				ToExamine: zfsUint(s.ToExamine),
+				Examined:  zfsUint(s.Examined),
+				Issued:    zfsUint(s.Issued),
+				Repaired:  zfsUint(s.Processed),
+				Errors:    zfsUint(s.Errors),
+			}
+			switch {
+			case ps.Scan.State == "finished":
+				ps.Scan.Progress = 100
+			case ps.Scan.ToExamine > 0:
+				ps.Scan.Progress = float64(ps.Scan.Issued) * 100 / float64(ps.Scan.ToExamine)
+			}
+		}
+
+		for _, top := range p.topVdevs() {
+			for _, leaf := range top.leaves() {
+				r, w, ck, slow := leaf.errorCounters()
+				ps.Vdevs = append(ps.Vdevs, ZfsVdevStatus{
+					Name:           strings.TrimPrefix(leaf.Name, "/dev/"),
+					Top:            top.Name,
+					Class:          top.Class,
+					State:          strings.ToLower(leaf.State),
+					ReadErrors:     r,
+					WriteErrors:    w,
+					ChecksumErrors: ck,
+					SlowIOs:        slow,
+				})
+			}
+		}
+		res = append(res, ps)
+	}
+	return res, nil
+}
+
+// parseZfsList reads zfs list -jp -o name,type,used,available,referenced,quota,compressratio,mountpoint
+func (c *Collector) parseZfsList(data []byte) ([]ZfsDataset, error) {
+	var out struct {
+		Datasets map[string]struct {
+			Name       string             `json:"name"`
+			Type       string             `json:"type"`
+			Properties map[string]ZfsProp `json:"properties"`
+		} `json:"datasets"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("zfs list json unmarshal err: %v", err)
+	}
+
+	res := make([]ZfsDataset, 0, len(out.Datasets))
+	for _, d := range out.Datasets {
+		ds := ZfsDataset{
+			Name:       d.Name,
+			Type:       strings.ToLower(d.Type),
+			Used:       zfsUint(d.Properties["used"].Value),
+			Available:  zfsUint(d.Properties["available"].Value),
+			Referenced: zfsUint(d.Properties["referenced"].Value),
+			Quota:      zfsUint(d.Properties["quota"].Value),
+		}
+		if mp := d.Properties["mountpoint"].Value; mp != "-" && mp != "none" {
+			ds.Mountpoint = mp
+		}
+		ds.CompressRatio, _ = strconv.ParseFloat(strings.TrimSuffix(d.Properties["compressratio"].Value, "x"), 64)
+		res = append(res, ds)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// zfsArcStats gives hit rate between cycles, the first cycle counts since boot
+func zfsArcStats(cur, prev map[string]uint64) *ZfsArcStats {
+	as := &ZfsArcStats{
+		Size:   cur["size"],
+		Target: cur["c"],
+		Max:    cur["c_max"],
+		Hits:   cur["hits"],
+		Misses: cur["misses"],
+	}
+	if cur["hits"] >= prev["hits"] && cur["misses"] >= prev["misses"] {
+		as.Hits -= prev["hits"]
+		as.Misses -= prev["misses"]
+	}
+	if total := as.Hits + as.Misses; total > 0 {
+		as.HitRate = float64(as.Hits) * 100 / float64(total)
+	}
+	return as
+}
+
+// zfsUint parses -p values, "-" and "none" are zero
+func zfsUint(s string) uint64 {
+	v, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return v
+}
+
+// zfsTime accepts unix seconds of -p or ctime like "Sun Jun  9 00:24:01 2024"
+func zfsTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil && sec > 0 {
+		return time.Unix(sec, 0).UTC()
+	}
+	if t, err := time.ParseInLocation(time.ANSIC, s, time.Local); err == nil {
+		return t.UTC()
+	}
+	return time.Time{}
+}
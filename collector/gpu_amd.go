@@ -1,8 +1,7 @@
 package collector
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -19,9 +18,12 @@ func (c *Collector) collectGpuAmd() {
 		if err != nil {
 			continue
 		}
-		if len(data) < 128 {
-			log.Printf("[collector] warn: gpu_metrics too small: %d bytes", len(data))
-			continue
+		if _, err := decodeGpuMetrics(data); err != nil {
+			log.Printf("[collector] warn: card%d %v", i, err)
+			// an unknown revision is still reported, with -1 values
+			if !errors.Is(err, errGpuMetricsUnsupported) {
+				continue
+			}
 		}
 		cards = append(cards, uint8(i))
 	}
@@ -42,57 +44,9 @@ func (c *Collector) collectGpuAmd() {
 	}
 }
 
-type AMDGpuMetricsV22 struct {
-	StructureSize   uint16
-	FormatRevision  uint8
-	ContentRevision uint8
-
-	TemperatureGfx  uint16
-	TemperatureSoc  uint16
-	TemperatureCore [8]uint16
-	TemperatureL3   [2]uint16
-
-	AverageGfxActivity uint16
-	AverageMmActivity  uint16
-
-	SystemClockCounter uint64
-
-	AverageSocketPower uint16
-	AverageCpuPower    uint16
-	AverageSocPower    uint16
-	AverageGfxPower    uint16
-	AverageCorePower   [8]uint16
-
-	AverageGfxClk uint16
-	AverageSocClk uint16
-	AverageUClk   uint16
-	AverageFClk   uint16
-	AverageVClk   uint16
-	AverageDClk   uint16
-
-	CurrentGfxClk  uint16
-	CurrentSocClk  uint16
-	CurrentUClk    uint16
-	CurrentFClk    uint16
-	CurrentVClk    uint16
-	CurrentDClk    uint16
-	CurrentCoreClk [8]uint16
-	CurrentL3Clk   [2]uint16
-
-	ThrottleStatus uint32
-	FanPwm         uint16
-	Padding        [3]uint16
-
-	IndependentThrottleStatus uint64
-}
-
 func (c *Collector) parseGpuAmd(card uint8, data []byte) (GpuAmd, error) {
-	var m AMDGpuMetricsV22
-	if err := binary.Read(
-		bytes.NewReader(data),
-		binary.LittleEndian,
-		&m,
-	); err != nil {
+	ga, err := decodeGpuMetrics(data)
+	if err != nil && !errors.Is(err, errGpuMetricsUnsupported) {
 		return GpuAmd{}, err
 	}
 
@@ -101,20 +55,12 @@ func (c *Collector) parseGpuAmd(card uint8, data []byte) (GpuAmd, error) {
 	memUsed, _ := c.readUint64FromFile(fmt.Sprintf("/sys/class/drm/card%d/device/mem_info_vram_used", card))
 	memTotal, _ := c.readUint64FromFile(fmt.Sprintf("/sys/class/drm/card%d/device/mem_info_vram_total", card))
 
-	return GpuAmd{
-		VerFormat:  m.FormatRevision,
-		VerContent: m.ContentRevision,
-		Card:       card,
-		Vendor:     vendor,
-		Device:     device,
-		MemUse:     memUsed,
-		MemFree:    memTotal - memUsed,
-		UtilGpu:    fmt.Sprintf("%.2f", float64(m.AverageGfxActivity)/100),
-		UtilMedia:  fmt.Sprintf("%.2f", float64(m.AverageMmActivity)/100),
-		TmpGpu:     fmt.Sprintf("%.2f", float64(m.TemperatureSoc)/100),
-		Power:      fmt.Sprintf("%.2f", float64(m.AverageSocPower)/100),
-		ClockSoc:   int(m.CurrentSocClk),
-	}, nil
+	ga.Card = card
+	ga.Vendor = vendor
+	ga.Device = device
+	ga.MemUse = memUsed
+	ga.MemFree = memTotal - memUsed
+	return ga, nil
 }
 
 func (c *Collector) writeAmdStats(data []GpuAmd) {
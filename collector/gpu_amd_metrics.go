@@ -0,0 +1,620 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// gpu_metrics layouts of the amdgpu driver, transcribed from struct gpu_metrics_v* in
+// drivers/gpu/drm/amd/include/kgd_pp_interface.h of linux v6.10, the Padding fields stand for what C alignment inserts.
+// The driver fills the table with 0xff first, so all ones means the asic does not report the value.
+
+type AMDMetricsHeader struct {
+	StructureSize   uint16
+	FormatRevision  uint8
+	ContentRevision uint8
+}
+
+// AMDGpuMetricsV10 is the first dGPU table, arcturus
+type AMDGpuMetricsV10 struct {
+	AMDMetricsHeader
+	Padding0           uint32
+	SystemClockCounter uint64
+
+	TemperatureEdge    uint16
+	TemperatureHotspot uint16
+	TemperatureMem     uint16
+	TemperatureVrGfx   uint16
+	TemperatureVrSoc   uint16
+	TemperatureVrMem   uint16
+
+	AverageGfxActivity uint16
+	AverageUmcActivity uint16
+	AverageMmActivity  uint16
+
+	AverageSocketPower uint16
+	EnergyAccumulator  uint32
+
+	AverageGfxClk uint16
+	AverageSocClk uint16
+	AverageUClk   uint16
+	AverageVClk0  uint16
+	AverageDClk0  uint16
+	AverageVClk1  uint16
+	AverageDClk1  uint16
+
+	CurrentGfxClk uint16
+	CurrentSocClk uint16
+	CurrentUClk   uint16
+	CurrentVClk0  uint16
+	CurrentDClk0  uint16
+	CurrentVClk1  uint16
+	CurrentDClk1  uint16
+
+	ThrottleStatus  uint32
+	CurrentFanSpeed uint16
+	PcieLinkWidth   uint8
+	PcieLinkSpeed   uint8
+}
+
+// AMDGpuMetricsV11 serves v1.1 and v1.2, which only appends firmware_timestamp
+type AMDGpuMetricsV11 struct {
+	AMDMetricsHeader
+
+	TemperatureEdge    uint16
+	TemperatureHotspot uint16
+	TemperatureMem     uint16
+	TemperatureVrGfx   uint16
+	TemperatureVrSoc   uint16
+	TemperatureVrMem   uint16
+
+	AverageGfxActivity uint16
+	AverageUmcActivity uint16
+	AverageMmActivity  uint16
+
+	AverageSocketPower uint16
+	EnergyAccumulator  uint64
+
+	SystemClockCounter uint64
+
+	AverageGfxClk uint16
+	AverageSocClk uint16
+	AverageUClk   uint16
+	AverageVClk0  uint16
+	AverageDClk0  uint16
+	AverageVClk1  uint16
+	AverageDClk1  uint16
+
+	CurrentGfxClk uint16
+	CurrentSocClk uint16
+	CurrentUClk   uint16
+	CurrentVClk0  uint16
+	CurrentDClk0  uint16
+	CurrentVClk1  uint16
+	CurrentDClk1  uint16
+
+	ThrottleStatus  uint32
+	CurrentFanSpeed uint16
+	PcieLinkWidth   uint16
+	PcieLinkSpeed   uint16
+	Padding         uint16
+
+	GfxActivityAcc uint32
+	MemActivityAcc uint32
+
+	TemperatureHbm [4]uint16
+}
+
+type AMDGpuMetricsV13 struct {
+	AMDGpuMetricsV11
+	FirmwareTimestamp uint64
+
+	VoltageSoc uint16
+	VoltageGfx uint16
+	VoltageMem uint16
+	Padding1   uint16
+
+	IndependentThrottleStatus uint64
+}
+
+// AMDGpuMetricsV14 is the MI300 table, values in C, W and percent
+type AMDGpuMetricsV14 struct {
+	AMDMetricsHeader
+
+	TemperatureHotspot uint16
+	TemperatureMem     uint16
+	TemperatureVrSoc   uint16
+	CurrSocketPower    uint16
+	AverageGfxActivity uint16
+	AverageUmcActivity uint16
+	VcnActivity        [4]uint16
+
+	EnergyAccumulator  uint64
+	SystemClockCounter uint64
+	ThrottleStatus     uint32
+	GfxclkLockStatus   uint32
+
+	PcieLinkWidth uint16
+	PcieLinkSpeed uint16
+	XgmiLinkWidth uint16
+	XgmiLinkSpeed uint16
+
+	GfxActivityAcc uint32
+	MemActivityAcc uint32
+
+	PcieBandwidthAcc        uint64
+	PcieBandwidthInst       uint64
+	PcieL0ToRecovCountAcc   uint64
+	PcieReplayCountAcc      uint64
+	PcieReplayRoverCountAcc uint64
+
+	XgmiReadDataAcc  [8]uint64
+	XgmiWriteDataAcc [8]uint64
+
+	FirmwareTimestamp uint64
+
+	CurrentGfxClk [8]uint16
+	CurrentSocClk [4]uint16
+	CurrentVClk0  [4]uint16
+	CurrentDClk0  [4]uint16
+	CurrentUClk   uint16
+	Padding       uint16
+}
+
+// AMDGpuMetricsV15 adds jpeg activity and pcie nak counters to v1.4
+type AMDGpuMetricsV15 struct {
+	AMDMetricsHeader
+
+	TemperatureHotspot uint16
+	TemperatureMem     uint16
+	TemperatureVrSoc   uint16
+	CurrSocketPower    uint16
+	AverageGfxActivity uint16
+	AverageUmcActivity uint16
+	VcnActivity        [4]uint16
+	JpegActivity       [32]uint16
+
+	EnergyAccumulator  uint64
+	SystemClockCounter uint64
+	ThrottleStatus     uint32
+	GfxclkLockStatus   uint32
+
+	PcieLinkWidth uint16
+	PcieLinkSpeed uint16
+	XgmiLinkWidth uint16
+	XgmiLinkSpeed uint16
+
+	GfxActivityAcc uint32
+	MemActivityAcc uint32
+
+	PcieBandwidthAcc        uint64
+	PcieBandwidthInst       uint64
+	PcieL0ToRecovCountAcc   uint64
+	PcieReplayCountAcc      uint64
+	PcieReplayRoverCountAcc uint64
+	PcieNakSentCountAcc     uint32
+	PcieNakRcvdCountAcc     uint32
+
+	XgmiReadDataAcc  [8]uint64
+	XgmiWriteDataAcc [8]uint64
+
+	FirmwareTimestamp uint64
+
+	CurrentGfxClk [8]uint16
+	CurrentSocClk [4]uint16
+	CurrentVClk0  [4]uint16
+	CurrentDClk0  [4]uint16
+	CurrentUClk   uint16
+	Padding       uint16
+}
+
+// AMDGpuMetricsV20 is the first APU table, renoir, temperatures in centi C
+type AMDGpuMetricsV20 struct {
+	AMDMetricsHeader
+	Padding0           uint32
+	SystemClockCounter uint64
+
+	TemperatureGfx  uint16
+	TemperatureSoc  uint16
+	TemperatureCore [8]uint16
+	TemperatureL3   [2]uint16
+
+	AverageGfxActivity uint16
+	AverageMmActivity  uint16
+
+	AverageSocketPower uint16
+	AverageCpuPower    uint16
+	AverageSocPower    uint16
+	AverageGfxPower    uint16
+	AverageCorePower   [8]uint16
+
+	AverageGfxClk uint16
+	AverageSocClk uint16
+	AverageUClk   uint16
+	AverageFClk   uint16
+	AverageVClk   uint16
+	AverageDClk   uint16
+
+	CurrentGfxClk  uint16
+	CurrentSocClk  uint16
+	CurrentUClk    uint16
+	CurrentFClk    uint16
+	CurrentVClk    uint16
+	CurrentDClk    uint16
+	CurrentCoreClk [8]uint16
+	CurrentL3Clk   [2]uint16
+
+	ThrottleStatus uint32
+	FanPwm         uint16
+	Padding        uint16
+}
+
+// AMDGpuMetricsV21 moves the timestamp behind utilization
+type AMDGpuMetricsV21 struct {
+	AMDMetricsHeader
+
+	TemperatureGfx  uint16
+	TemperatureSoc  uint16
+	TemperatureCore [8]uint16
+	TemperatureL3   [2]uint16
+
+	AverageGfxActivity uint16
+	AverageMmActivity  uint16
+
+	SystemClockCounter uint64
+
+	AverageSocketPower uint16
+	AverageCpuPower    uint16
+	AverageSocPower    uint16
+	AverageGfxPower    uint16
+	AverageCorePower   [8]uint16
+
+	AverageGfxClk uint16
+	AverageSocClk uint16
+	AverageUClk   uint16
+	AverageFClk   uint16
+	AverageVClk   uint16
+	AverageDClk   uint16
+
+	CurrentGfxClk  uint16
+	CurrentSocClk  uint16
+	CurrentUClk    uint16
+	CurrentFClk    uint16
+	CurrentVClk    uint16
+	CurrentDClk    uint16
+	CurrentCoreClk [8]uint16
+	CurrentL3Clk   [2]uint16
+
+	ThrottleStatus uint32
+	FanPwm         uint16
+	Padding        [3]uint16
+}
+
+// AMDGpuMetricsV22 serves v2.2 to v2.4, later ones append average temperatures, voltages and currents
+type AMDGpuMetricsV22 struct {
+	AMDGpuMetricsV21
+	IndependentThrottleStatus uint64
+}
+
+// AMDGpuMetricsV30 is the phoenix and strix table, power in mW
+type AMDGpuMetricsV30 struct {
+	AMDMetricsHeader
+
+	TemperatureGfx  uint16
+	TemperatureSoc  uint16
+	TemperatureCore [16]uint16
+	TemperatureSkin uint16
+
+	AverageGfxActivity    uint16
+	AverageVcnActivity    uint16
+	AverageIpuActivity    [8]uint16
+	AverageCoreC0Activity [16]uint16
+	AverageDramReads      uint16
+	AverageDramWrites     uint16
+	AverageIpuReads       uint16
+	AverageIpuWrites      uint16
+	Padding0              uint16
+
+	SystemClockCounter uint64
+
+	AverageSocketPower     uint32
+	AverageIpuPower        uint16
+	Padding1               uint16
+	AverageApuPower        uint32
+	AverageGfxPower        uint32
+	AverageDgpuPower       uint32
+	AverageAllCorePower    uint32
+	AverageCorePower       [16]uint16
+	AverageSysPower        uint16
+	StapmPowerLimit        uint16
+	CurrentStapmPowerLimit uint16
+
+	AverageGfxClk   uint16
+	AverageSocClk   uint16
+	AverageVpeClk   uint16
+	AverageIpuClk   uint16
+	AverageFClk     uint16
+	AverageVClk     uint16
+	AverageUClk     uint16
+	AverageMpipuClk uint16
+
+	CurrentCoreClk     [16]uint16
+	CurrentCoreMaxFreq uint16
+	CurrentGfxMaxFreq  uint16
+	Padding2           uint16
+
+	ThrottleResidencyProchot uint32
+	ThrottleResidencySpl     uint32
+	ThrottleResidencyFppt    uint32
+	ThrottleResidencySppt    uint32
+	ThrottleResidencyThmCore uint32
+	ThrottleResidencyThmGfx  uint32
+	ThrottleResidencyThmSoc  uint32
+	TimeFilterAlphaValue     uint32
+}
+
+// asic independent throttler bits, SMU_THROTTLER_*_BIT
+var amdThrottlers = map[int]string{
+	0: "ppt0", 1: "ppt1", 2: "ppt2", 3: "ppt3", 4: "spl", 5: "fppt", 6: "sppt", 7: "sppt_apu",
+	16: "tdc_gfx", 17: "tdc_soc", 18: "tdc_mem", 19: "tdc_vdd", 20: "tdc_cvip", 21: "edc_cpu", 22: "edc_gfx", 23: "apcc",
+	32: "temp_gpu", 33: "temp_core", 34: "temp_mem", 35: "temp_edge", 36: "temp_hotspot", 37: "temp_soc",
+	38: "temp_vr_gfx", 39: "temp_vr_soc", 40: "temp_vr_mem0", 41: "temp_vr_mem1", 42: "temp_liquid0", 43: "temp_liquid1",
+	44: "vrhot0", 45: "vrhot1", 46: "prochot_cpu", 47: "prochot_gfx",
+	56: "ppm", 57: "fit",
+}
+
+// errGpuMetricsUnsupported comes with a GpuAmd carrying only the revision, its values stay -1
+var errGpuMetricsUnsupported = errors.New("not supported")
+
+// decodeGpuMetrics dispatches on the header revision and fills the typed values of GpuAmd
+func decodeGpuMetrics(data []byte) (GpuAmd, error) {
+	if len(data) < 4 {
+		return GpuAmd{}, fmt.Errorf("gpu_metrics too small: %d bytes", len(data))
+	}
+	if size := int(binary.LittleEndian.Uint16(data)); size > len(data) {
+		return GpuAmd{}, fmt.Errorf("gpu_metrics v%d.%d truncated: %d of %d bytes", data[2], data[3], len(data), size)
+	}
+	ga := GpuAmd{
+		VerFormat:     data[2],
+		VerContent:    data[3],
+		TempEdge:      -1,
+		TempHotspot:   -1,
+		TempMem:       -1,
+		FanRPM:        -1,
+		PowerW:        -1,
+		GfxActivity:   -1,
+		MemActivity:   -1,
+		MediaActivity: -1,
+		ClockGfx:      -1,
+		ClockVram:     -1,
+	}
+
+	var err error
+	switch {
+	case ga.VerFormat == 1 && ga.VerContent == 0:
+		err = ga.decodeV10(data)
+	case ga.VerFormat == 1 && ga.VerContent <= 3:
+		err = ga.decodeV11(data)
+	case ga.VerFormat == 1 && ga.VerContent == 4:
+		err = ga.decodeV14(data)
+	case ga.VerFormat == 1 && ga.VerContent == 5:
+		err = ga.decodeV15(data)
+	case ga.VerFormat == 2 && ga.VerContent == 0:
+		err = ga.decodeV20(data)
+	case ga.VerFormat == 2 && ga.VerContent <= 4:
+		err = ga.decodeV21(data)
+	case ga.VerFormat == 3 && ga.VerContent == 0:
+		err = ga.decodeV30(data)
+	default:
+		err = fmt.Errorf("gpu_metrics v%d.%d %w", ga.VerFormat, ga.VerContent, errGpuMetricsUnsupported)
+	}
+	if err != nil && !errors.Is(err, errGpuMetricsUnsupported) {
+		return GpuAmd{}, err
+	}
+
+	ga.UtilGpu = fmt.Sprintf("%.2f", ga.GfxActivity)
+	ga.UtilMedia = fmt.Sprintf("%.2f", ga.MediaActivity)
+	if ga.TmpGpu == "" {
+		ga.TmpGpu = fmt.Sprintf("%.2f", max(ga.TempEdge, ga.TempHotspot))
+	}
+	if ga.Power == "" {
+		ga.Power = fmt.Sprintf("%.2f", ga.PowerW)
+	}
+	return ga, err
+}
+
+func readGpuMetrics(data []byte, m any) error {
+	if n := binary.Size(m); len(data) < n {
+		return fmt.Errorf("gpu_metrics v%d.%d: %d bytes, layout needs %d", data[2], data[3], len(data), n)
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, m)
+}
+
+func (ga *GpuAmd) decodeV10(data []byte) error {
+	var m AMDGpuMetricsV10
+	if err := readGpuMetrics(data, &m); err != nil {
+		return err
+	}
+	ga.TempEdge = amdValue(m.TemperatureEdge, 1)
+	ga.TempHotspot = amdValue(m.TemperatureHotspot, 1)
+	ga.TempMem = amdValue(m.TemperatureMem, 1)
+	ga.FanRPM = int(amdValue(m.CurrentFanSpeed, 1))
+	ga.PowerW = amdValue(m.AverageSocketPower, 1)
+	ga.GfxActivity = amdValue(m.AverageGfxActivity, 1)
+	ga.MemActivity = amdValue(m.AverageUmcActivity, 1)
+	ga.MediaActivity = amdValue(m.AverageMmActivity, 1)
+	ga.ClockGfx = int(amdValue(m.CurrentGfxClk, 1))
+	ga.ClockVram = int(amdValue(m.CurrentUClk, 1))
+	ga.ClockSoc = int(amdValue(m.CurrentSocClk, 1))
+	ga.ThrottleStatus = m.ThrottleStatus
+	return nil
+}
+
+func (ga *GpuAmd) decodeV11(data []byte) error {
+	var m AMDGpuMetricsV13
+	if ga.VerContent == 3 {
+		if err := readGpuMetrics(data, &m); err != nil {
+			return err
+		}
+		ga.Throttle = amdThrottle(m.IndependentThrottleStatus)
+	} else if err := readGpuMetrics(data, &m.AMDGpuMetricsV11); err != nil {
+		return err
+	}
+	ga.TempEdge = amdValue(m.TemperatureEdge, 1)
+	ga.TempHotspot = amdValue(m.TemperatureHotspot, 1)
+	ga.TempMem = amdValue(m.TemperatureMem, 1)
+	ga.FanRPM = int(amdValue(m.CurrentFanSpeed, 1))
+	ga.PowerW = amdValue(m.AverageSocketPower, 1)
+	ga.GfxActivity = amdValue(m.AverageGfxActivity, 1)
+	ga.MemActivity = amdValue(m.AverageUmcActivity, 1)
+	ga.MediaActivity = amdValue(m.AverageMmActivity, 1)
+	ga.ClockGfx = int(amdValue(m.CurrentGfxClk, 1))
+	ga.ClockVram = int(amdValue(m.CurrentUClk, 1))
+	ga.ClockSoc = int(amdValue(m.CurrentSocClk, 1))
+	ga.ThrottleStatus = m.ThrottleStatus
+	return nil
+}
+
+func (ga *GpuAmd) decodeV14(data []byte) error {
+	var m AMDGpuMetricsV14
+	if err := readGpuMetrics(data, &m); err != nil {
+		return err
+	}
+	ga.TempHotspot = amdValue(m.TemperatureHotspot, 1)
+	ga.TempMem = amdValue(m.TemperatureMem, 1)
+	ga.PowerW = amdValue(m.CurrSocketPower, 1)
+	ga.GfxActivity = amdValue(m.AverageGfxActivity, 1)
+	ga.MemActivity = amdValue(m.AverageUmcActivity, 1)
+	ga.ClockGfx = int(amdValue(m.CurrentGfxClk[0], 1))
+	ga.ClockVram = int(amdValue(m.CurrentUClk, 1))
+	ga.ClockSoc = int(amdValue(m.CurrentSocClk[0], 1))
+	ga.MediaActivity = amdAverage(m.VcnActivity[:])
+	ga.ThrottleStatus = m.ThrottleStatus
+	return nil
+}
+
+func (ga *GpuAmd) decodeV15(data []byte) error {
+	var m AMDGpuMetricsV15
+	if err := readGpuMetrics(data, &m); err != nil {
+		return err
+	}
+	ga.TempHotspot = amdValue(m.TemperatureHotspot, 1)
+	ga.TempMem = amdValue(m.TemperatureMem, 1)
+	ga.PowerW = amdValue(m.CurrSocketPower, 1)
+	ga.GfxActivity = amdValue(m.AverageGfxActivity, 1)
+	ga.MemActivity = amdValue(m.AverageUmcActivity, 1)
+	ga.ClockGfx = int(amdValue(m.CurrentGfxClk[0], 1))
+	ga.ClockVram = int(amdValue(m.CurrentUClk, 1))
+	ga.ClockSoc = int(amdValue(m.CurrentSocClk[0], 1))
+	ga.MediaActivity = amdAverage(m.VcnActivity[:])
+	ga.ThrottleStatus = m.ThrottleStatus
+	return nil
+}
+
+func (ga *GpuAmd) decodeV20(data []byte) error {
+	var m AMDGpuMetricsV20
+	if err := readGpuMetrics(data, &m); err != nil {
+		return err
+	}
+	ga.apuValues(m.TemperatureGfx, m.AverageSocketPower, m.AverageGfxActivity, m.AverageMmActivity,
+		m.CurrentGfxClk, m.CurrentUClk)
+	ga.ClockSoc = int(m.CurrentSocClk)
+	ga.ThrottleStatus = m.ThrottleStatus
+	ga.TmpGpu = fmt.Sprintf("%.2f", float64(m.TemperatureSoc)/100)
+	ga.Power = fmt.Sprintf("%.2f", float64(m.AverageSocPower)/100)
+	return nil
+}
+
+func (ga *GpuAmd) decodeV21(data []byte) error {
+	var m AMDGpuMetricsV22
+	if ga.VerContent >= 2 {
+		if err := readGpuMetrics(data, &m); err != nil {
+			return err
+		}
+		ga.Throttle = amdThrottle(m.IndependentThrottleStatus)
+	} else if err := readGpuMetrics(data, &m.AMDGpuMetricsV21); err != nil {
+		return err
+	}
+	ga.apuValues(m.TemperatureGfx, m.AverageSocketPower, m.AverageGfxActivity, m.AverageMmActivity,
+		m.CurrentGfxClk, m.CurrentUClk)
+	ga.ClockSoc = int(m.CurrentSocClk)
+	ga.ThrottleStatus = m.ThrottleStatus
+	// legacy strings keep what v2 consumers always got
+	ga.TmpGpu = fmt.Sprintf("%.2f", float64(m.TemperatureSoc)/100)
+	ga.Power = fmt.Sprintf("%.2f", float64(m.AverageSocPower)/100)
+	return nil
+}
+
+// apuValues converts the v2 units: centi C, centi percent, socket power W on renoir and mW since yellow carp
+func (ga *GpuAmd) apuValues(tempGfx, socketPower, gfxActivity, mmActivity, gfxClk, uClk uint16) {
+	ga.TempEdge = amdValue(tempGfx, 100)
+	ga.PowerW = amdValue(socketPower, 1)
+	if ga.PowerW >= 1000 {
+		ga.PowerW /= 1000
+	}
+	ga.GfxActivity = amdValue(gfxActivity, 100)
+	ga.MediaActivity = amdValue(mmActivity, 100)
+	ga.ClockGfx = int(amdValue(gfxClk, 1))
+	ga.ClockVram = int(amdValue(uClk, 1))
+}
+
+func (ga *GpuAmd) decodeV30(data []byte) error {
+	var m AMDGpuMetricsV30
+	if err := readGpuMetrics(data, &m); err != nil {
+		return err
+	}
+	ga.TempEdge = amdValue(m.TemperatureGfx, 100)
+	if m.AverageSocketPower != 0xffffffff {
+		ga.PowerW = float64(m.AverageSocketPower) / 1000
+	}
+	ga.GfxActivity = amdValue(m.AverageGfxActivity, 1)
+	ga.MediaActivity = amdValue(m.AverageVcnActivity, 1)
+	ga.ClockGfx = int(amdValue(m.AverageGfxClk, 1))
+	ga.ClockVram = int(amdValue(m.AverageUClk, 1))
+	ga.ClockSoc = int(amdValue(m.AverageSocClk, 1))
+	ga.TmpGpu = fmt.Sprintf("%.2f", amdValue(m.TemperatureSoc, 100))
+	return nil
+}
+
+// amdValue scales a 16 bit field, all ones is -1
+func amdValue(v uint16, div float64) float64 {
+	if v == 0xffff {
+		return -1
+	}
+	return float64(v) / div
+}
+
+// amdAverage is the mean of the instances the asic reports, -1 when none does
+func amdAverage(vs []uint16) float64 {
+	sum, n := 0.0, 0
+	for _, v := range vs {
+		if v != 0xffff {
+			sum += float64(v)
+			n++
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return sum / float64(n)
+}
+
+func amdThrottle(status uint64) []string {
+	if status == ^uint64(0) {
+		return nil
+	}
+	res := []string{}
+	for status != 0 {
+		bit := bits.TrailingZeros64(status)
+		status &^= 1 << bit
+		if name, ok := amdThrottlers[bit]; ok {
+			res = append(res, name)
+		} else {
+			res = append(res, fmt.Sprintf("bit%d", bit))
+		}
+	}
+	return res
+}
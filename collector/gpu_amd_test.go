@@ -1,7 +1,11 @@
 package collector
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -28,12 +32,217 @@ func TestParseGpuAmd(t *testing.T) {
 	if amd.VerFormat != 2 || amd.VerContent != 2 || amd.Power != "6.93" || amd.ClockSoc != 400 {
 		t.Fatalf("error parse gpu amd v2.2: %+v", amd)
 	}
+	if amd.TempEdge != 40 || amd.PowerW != 7 || amd.TempHotspot != -1 || amd.FanRPM != -1 || len(amd.Throttle) != 0 {
+		t.Fatalf("error typed gpu amd v2.2: %+v", amd)
+	}
+}
+
+// fixtures dumped by a C program from the gpu_metrics structs of linux v6.10 kgd_pp_interface.h,
+// memset to 0xff like the driver does before filling in what the asic reports
+func TestDecodeGpuMetrics(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		edge, hotspot, mem float64
+		fan                int
+		power, gfx, umc    float64
+		media              float64
+		clkGfx, clkVram    int
+		clkSoc             int
+		status             uint32
+		throttle           string
+	}
+	tests := []struct {
+		name string
+		dump string
+		want want
+	}{
+		{"v1.0", `
+0000000 0050 0001 ffff ffff ffff ffff ffff ffff
+0000010 002d 003c 0046 ffff ffff ffff 0023 0014
+0000020 0005 0078 ffff ffff ffff ffff ffff ffff
+0000030 ffff ffff ffff 05dc 0320 036b ffff ffff
+0000040 ffff ffff 0004 0000 04b0 ffff ffff ffff
+0000050`, want{45, 60, 70, 1200, 120, 35, 20, 5, 1500, 875, 800, 4, ""}},
+		{"v1.1", `
+0000000 0060 0101 002e 003d 0047 ffff ffff ffff
+0000010 0024 0015 0006 0096 ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff ffff ffff ffff ffff
+0000030 ffff ffff ffff 0640 0384 03e8 ffff ffff
+0000040 ffff ffff 0000 0000 0514 ffff ffff ffff
+0000050 ffff ffff ffff ffff ffff ffff ffff ffff
+0000060`, want{46, 61, 71, 1300, 150, 36, 21, 6, 1600, 1000, 900, 0, ""}},
+		{"v1.2", `
+0000000 0068 0201 002f 003e 0048 ffff ffff ffff
+0000010 0025 0016 ffff 00a0 ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff ffff ffff ffff ffff
+0000030 ffff ffff ffff 06a4 03e8 044c ffff ffff
+0000040 ffff ffff 0000 0000 0578 ffff ffff ffff
+0000050 ffff ffff ffff ffff ffff ffff ffff ffff
+0000060 cd15 075b 0000 0000
+0000068`, want{47, 62, 72, 1400, 160, 37, 22, -1, 1700, 1100, 1000, 0, ""}},
+		{"v1.3", `
+0000000 0078 0301 0030 003f 0049 ffff ffff ffff
+0000010 0026 0017 0007 00aa ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff ffff ffff ffff ffff
+0000030 ffff ffff ffff 0708 044c 04b0 ffff ffff
+0000040 ffff ffff 0000 0000 05dc ffff ffff ffff
+0000050 ffff ffff ffff ffff ffff ffff ffff ffff
+0000060 ffff ffff ffff ffff ffff 0384 ffff ffff
+0000070 0001 0000 0008 0000
+0000078`, want{48, 63, 73, 1500, 170, 38, 23, 7, 1800, 1200, 1100, 0, "ppt0,temp_edge"}},
+		{"v1.4", `
+0000000 0120 0401 0037 0032 ffff 015e 0050 0028
+0000010 000a 001e ffff ffff ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff 0000 0000 ffff ffff
+0000030 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+00000f0 0834 ffff ffff ffff ffff ffff ffff ffff
+0000100 03e8 ffff ffff ffff ffff ffff ffff ffff
+0000110 ffff ffff ffff ffff 0514 ffff ffff ffff
+0000120`, want{-1, 55, 50, -1, 350, 80, 40, 20, 2100, 1300, 1000, 0, ""}},
+		{"v1.5", `
+0000000 0168 0501 0038 0033 ffff 0168 0051 0029
+0000010 0014 ffff ffff ffff 0032 ffff ffff ffff
+0000020 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+0000060 ffff ffff ffff ffff 0000 0000 ffff ffff
+0000070 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+00000a0 ffff ffff ffff ffff 0003 0000 ffff ffff
+00000b0 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+0000130 ffff ffff ffff ffff 0834 ffff ffff ffff
+0000140 ffff ffff ffff ffff 03e8 ffff ffff ffff
+0000150 ffff ffff ffff ffff ffff ffff ffff ffff
+0000160 0514 ffff ffff ffff
+0000168`, want{-1, 56, 51, -1, 360, 81, 41, 20, 2100, 1300, 1000, 0, ""}},
+		{"v2.0", `
+0000000 0078 0002 ffff ffff ffff ffff ffff ffff
+0000010 1388 12c0 ffff ffff ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff 09f6 0064 000f ffff
+0000030 04b0 ffff ffff ffff ffff ffff ffff ffff
+0000040 ffff ffff ffff ffff ffff ffff ffff ffff
+0000050 0708 0258 0640 ffff ffff ffff ffff ffff
+0000060 ffff ffff ffff ffff ffff ffff ffff ffff
+0000070 0000 0000 ffff ffff
+0000078`, want{50, -1, -1, -1, 15, 25.5, -1, 1, 1800, 1600, 600, 0, ""}},
+		{"v2.1", `
+0000000 0078 0102 13ec 1324 ffff ffff ffff ffff
+0000010 ffff ffff ffff ffff ffff ffff 03e8 0000
+0000020 ffff ffff ffff ffff 0009 ffff 0320 ffff
+0000030 ffff ffff ffff ffff ffff ffff ffff ffff
+0000040 ffff ffff ffff ffff ffff ffff 076c 02bc
+0000050 06a4 ffff ffff ffff ffff ffff ffff ffff
+0000060 ffff ffff ffff ffff ffff ffff 0000 0000
+0000070 ffff ffff ffff ffff
+0000078`, want{51, -1, -1, -1, 9, 10, -1, 0, 1900, 1700, 700, 0, ""}},
+		{"v2.3", `
+0000000 0098 0302 14b4 1450 ffff ffff ffff ffff
+0000010 ffff ffff ffff ffff ffff ffff 26ac 00fa
+0000020 ffff ffff ffff ffff 30d4 ffff 05dc ffff
+0000030 ffff ffff ffff ffff ffff ffff ffff ffff
+0000040 ffff ffff ffff ffff ffff ffff 0898 0320
+0000050 0c80 ffff ffff ffff ffff ffff ffff ffff
+0000060 ffff ffff ffff ffff ffff ffff 0000 0000
+0000070 ffff ffff ffff ffff 0000 0000 0010 0000
+0000080 1482 ffff ffff ffff ffff ffff ffff ffff
+0000090 ffff ffff ffff ffff
+0000098`, want{53, -1, -1, -1, 12.5, 99, -1, 2.5, 2200, 3200, 800, 0, "temp_hotspot"}},
+		{"v2.4", `
+0000000 00a8 0402 1130 10cc ffff ffff ffff ffff
+0000010 ffff ffff ffff ffff ffff ffff 01f4 0000
+0000020 ffff ffff ffff ffff 1900 ffff ffff ffff
+0000030 ffff ffff ffff ffff ffff ffff ffff ffff
+0000040 ffff ffff ffff ffff ffff ffff 0258 0190
+0000050 0af0 ffff ffff ffff ffff ffff ffff ffff
+0000060 ffff ffff ffff ffff ffff ffff 0000 0000
+0000070 ffff ffff ffff ffff 0000 0000 0000 0000
+0000080 ffff ffff ffff ffff ffff ffff ffff ffff
+0000090 ffff ffff ffff ffff ffff ffff 02ee ffff
+00000a0 ffff ffff ffff ffff
+00000a8`, want{44, -1, -1, -1, 6.4, 5, -1, 0, 600, 2800, 400, 0, ""}},
+		{"v3.0", `
+0000000 0108 0003 1450 1388 ffff ffff ffff ffff
+0000010 ffff ffff ffff ffff ffff ffff ffff ffff
+0000020 ffff ffff ffff ffff ffff 001e 000c ffff
+0000030 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+0000070 61a8 0000 ffff ffff ffff ffff ffff ffff
+0000080 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+00000a0 ffff ffff ffff ffff 7530 ffff ffff 0a8c
+00000b0 04b0 ffff ffff ffff ffff 0af0 ffff ffff
+00000c0 ffff ffff ffff ffff ffff ffff ffff ffff
+*
+0000100 ffff ffff ffff ffff
+0000108`, want{52, -1, -1, -1, 25, 30, -1, 12, 2700, 2800, 1200, 0, ""}},
+	}
+
+	for _, tt := range tests {
+		data, err := parseHexDump(tt.dump)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+		if int(data[0])|int(data[1])<<8 != len(data) {
+			t.Fatalf("%s: structure size %d, dump %d bytes", tt.name, int(data[0])|int(data[1])<<8, len(data))
+		}
+		ga, err := decodeGpuMetrics(data)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+		got := want{
+			ga.TempEdge, ga.TempHotspot, ga.TempMem, ga.FanRPM, ga.PowerW, ga.GfxActivity, ga.MemActivity, ga.MediaActivity,
+			ga.ClockGfx, ga.ClockVram, ga.ClockSoc, ga.ThrottleStatus, strings.Join(ga.Throttle, ","),
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+		if want := fmt.Sprintf("v%d.%d", ga.VerFormat, ga.VerContent); want != tt.name {
+			t.Fatalf("%s: decoded as %s", tt.name, want)
+		}
+
+		// a table cut short must not decode into garbage
+		if _, err := decodeGpuMetrics(data[:len(data)-8]); err == nil {
+			t.Fatalf("%s: truncated table decoded", tt.name)
+		}
+	}
+
+	// later revisions keep the card listed with nothing decoded
+	for _, ver := range [][2]byte{{1, 6}, {4, 0}} {
+		data := bytes.Repeat([]byte{0xff}, 64)
+		data[0], data[1], data[2], data[3] = 64, 0, ver[0], ver[1]
+		ga, err := decodeGpuMetrics(data)
+		if !errors.Is(err, errGpuMetricsUnsupported) {
+			t.Fatalf("v%d.%d: expected not supported got %v", ver[0], ver[1], err)
+		}
+		if ga.VerFormat != ver[0] || ga.VerContent != ver[1] || ga.TempHotspot != -1 || ga.PowerW != -1 || ga.UtilGpu != "-1.00" {
+			t.Fatalf("v%d.%d: expected -1 values got %+v", ver[0], ver[1], ga)
+		}
+	}
 }
 
+// parseHexDump reads hexdump output, "*" repeats the previous line up to the next offset
 func parseHexDump(dump string) ([]byte, error) {
 	var res []byte
+	repeat := false
 	for _, line := range strings.Split(dump, "\n") {
 		fields := strings.Fields(line)
+		if len(fields) == 1 && fields[0] == "*" {
+			repeat = true
+			continue
+		}
+		if repeat && len(fields) > 0 && len(res) >= 16 {
+			off, err := strconv.ParseInt(fields[0], 16, 64)
+			if err != nil {
+				return nil, err
+			}
+			last := res[len(res)-16:]
+			for int64(len(res)) < off {
+				res = append(res, last...)
+			}
+			repeat = false
+		}
 		if len(fields) < 2 {
 			continue
 		}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GpuClient is the usage of one process on one gpu from /proc/<pid>/fdinfo, any drm driver
+type GpuClient struct {
+	PID      int                `json:"pid"`
+	Name     string             `json:"name"`
+	Driver   string             `json:"driver"` // i915, xe, amdgpu
+	PciBus   string             `json:"pciBus"`
+	Engines  map[string]float64 `json:"engines"`  // percent busy per engine class, empty on the first scan
+	MemBytes uint64             `json:"memBytes"` // resident in all memory regions
+}
+
+// drmFdinfo is what drm-usage-stats puts into fdinfo of an open /dev/dri node
+type drmFdinfo struct {
+	driver   string
+	pdev     string
+	clientID string
+	engines  map[string]uint64 // busy ns, i915 and amdgpu
+	cycles   map[string]uint64 // busy gpu cycles, xe
+	total    map[string]uint64 // elapsed gpu cycles, xe
+	capacity map[string]uint64 // engines of the class
+	mem      uint64
+}
+
+type drmSample struct {
+	time    time.Time
+	engines map[string]uint64
+	cycles  map[string]uint64
+	total   map[string]uint64
+}
+
+// xe reports engine classes by hw name
+var drmEngineNames = map[string]string{
+	"rcs":  "render",
+	"bcs":  "copy",
+	"vcs":  "video",
+	"vecs": "video-enhance",
+	"ccs":  "compute",
+}
+
+var drmPrev = map[string]drmSample{}
+
+func parseDrmFdinfo(data string) (drmFdinfo, bool) {
+	fi := drmFdinfo{
+		engines:  map[string]uint64{},
+		cycles:   map[string]uint64{},
+		total:    map[string]uint64{},
+		capacity: map[string]uint64{},
+	}
+	var resident, memory uint64
+	s := bufio.NewScanner(strings.NewReader(data))
+	for s.Scan() {
+		key, val, ok := strings.Cut(s.Text(), ":")
+		if !ok || !strings.HasPrefix(key, "drm-") {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		num, _ := strconv.ParseUint(strings.Fields(val + " 0")[0], 10, 64)
+		switch {
+		case key == "drm-driver":
+			fi.driver = val
+		case key == "drm-pdev":
+			fi.pdev = val
+		case key == "drm-client-id":
+			fi.clientID = val
+		case strings.HasPrefix(key, "drm-engine-capacity-"):
+			fi.capacity[strings.TrimPrefix(key, "drm-engine-capacity-")] = num
+		case strings.HasPrefix(key, "drm-engine-"):
+			fi.engines[strings.TrimPrefix(key, "drm-engine-")] = num
+		case strings.HasPrefix(key, "drm-total-cycles-"):
+			fi.total[strings.TrimPrefix(key, "drm-total-cycles-")] = num
+		case strings.HasPrefix(key, "drm-cycles-"):
+			fi.cycles[strings.TrimPrefix(key, "drm-cycles-")] = num
+		case strings.HasPrefix(key, "drm-resident-"):
+			resident += drmBytes(val)
+		case strings.HasPrefix(key, "drm-memory-"):
+			// amdgpu before drm-resident existed
+			memory += drmBytes(val)
+		}
+	}
+	fi.mem = resident
+	if fi.mem == 0 {
+		fi.mem = memory
+	}
+	return fi, fi.driver != "" && fi.clientID != ""
+}
+
+// drmBytes parses "1024 KiB", "12 MiB" or plain bytes
+func drmBytes(val string) uint64 {
+	f := strings.Fields(val)
+	if len(f) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseUint(f[0], 10, 64)
+	if len(f) > 1 {
+		switch f[1] {
+		case "KiB":
+			v <<= 10
+		case "MiB":
+			v <<= 20
+		case "GiB":
+			v <<= 30
+		}
+	}
+	return v
+}
+
+// drmBusy is percent per engine class between two samples, divided by the engines of the class
+func drmBusy(prev, cur drmSample, capacity map[string]uint64) map[string]float64 {
+	busy := map[string]float64{}
+	capOf := func(e string) float64 {
+		return float64(max(capacity[e], 1))
+	}
+	if elapsed := cur.time.Sub(prev.time).Nanoseconds(); elapsed > 0 {
+		for e, ns := range cur.engines {
+			if p, ok := prev.engines[e]; ok && ns >= p {
+				busy[e] = min(float64(ns-p)/float64(elapsed)*100/capOf(e), 100)
+			}
+		}
+	}
+	for e, cyc := range cur.cycles {
+		p, ok := prev.cycles[e]
+		if !ok || cyc < p || cur.total[e] <= prev.total[e] {
+			continue
+		}
+		name := e
+		if n, ok := drmEngineNames[e]; ok {
+			name = n
+		}
+		busy[name] = min(float64(cyc-p)/float64(cur.total[e]-prev.total[e])*100/capOf(e), 100)
+	}
+	return busy
+}
+
+// collectDrmClients refreshes GPUStats.Processes, fdinfo works for any drm driver, amdgpu included
+func (c *Collector) collectDrmClients() {
+	if !fileExists("/dev/dri") {
+		return
+	}
+	// walking every fd of every process is not cheap
+	for now := range time.Tick(5 * time.Second) {
+		clients := scanDrmClients(pathProc, now)
+
+		c.mu.Lock()
+		c.data.GPUStats.Processes = clients
+		c.mu.Unlock()
+	}
+}
+
+// scanDrmClients walks the fds of every process for /dev/dri nodes, one entry per process and gpu
+func scanDrmClients(procRoot string, now time.Time) []GpuClient {
+	pids, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]drmSample{}
+	merged := map[string]*GpuClient{}
+	for _, p := range pids {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		dir := procRoot + "/" + p.Name()
+		fds, err := os.ReadDir(dir + "/fd")
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(dir + "/fd/" + fd.Name())
+			if err != nil || !strings.HasPrefix(target, "/dev/dri/") {
+				continue
+			}
+			data, err := os.ReadFile(dir + "/fdinfo/" + fd.Name())
+			if err != nil {
+				continue
+			}
+			fi, ok := parseDrmFdinfo(string(data))
+			if !ok {
+				continue
+			}
+			// dup'ed fds share the client
+			key := p.Name() + "/" + fi.pdev + "/" + fi.clientID
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			cur := drmSample{time: now, engines: fi.engines, cycles: fi.cycles, total: fi.total}
+			seen[key] = cur
+
+			gk := p.Name() + "/" + fi.pdev
+			cl, ok := merged[gk]
+			if !ok {
+				cl = &GpuClient{
+					PID:     pid,
+					Name:    readSysString(dir + "/comm"),
+					Driver:  fi.driver,
+					PciBus:  fi.pdev,
+					Engines: map[string]float64{},
+				}
+				merged[gk] = cl
+			}
+			cl.MemBytes += fi.mem
+			if prev, ok := drmPrev[key]; ok {
+				for e, v := range drmBusy(prev, cur, fi.capacity) {
+					cl.Engines[e] = min(cl.Engines[e]+v, 100)
+				}
+			}
+		}
+	}
+	drmPrev = seen
+
+	res := make([]GpuClient, 0, len(merged))
+	for _, cl := range merged {
+		res = append(res, *cl)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].PID != res[j].PID {
+			return res[i].PID < res[j].PID
+		}
+		return res[i].PciBus < res[j].PciBus
+	})
+	return res
+}
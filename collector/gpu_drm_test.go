@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const fdinfoI915 = `pos:	0
+flags:	02100002
+mnt_id:	26
+drm-driver:	i915
+drm-client-id:	7
+drm-pdev:	0000:00:02.0
+drm-total-system0:	12 MiB
+drm-resident-system0:	8 MiB
+drm-engine-render:	%d ns
+drm-engine-copy:	0 ns
+drm-engine-video:	%d ns
+drm-engine-capacity-video:	2
+drm-engine-video-enhance:	0 ns
+`
+
+const fdinfoXe = `drm-driver:	xe
+drm-client-id:	41
+drm-pdev:	0000:03:00.0
+drm-resident-vram0:	1024 KiB
+drm-cycles-rcs:	%d
+drm-total-cycles-rcs:	%d
+drm-cycles-vcs:	0
+drm-total-cycles-vcs:	%d
+`
+
+const fdinfoAmd = `drm-driver:	amdgpu
+drm-pdev:	0000:0a:00.0
+drm-client-id:	12
+drm-memory-vram:	2048 KiB
+drm-memory-gtt:	1024 KiB
+drm-engine-gfx:	%d ns
+drm-engine-dec:	0 ns
+`
+
+func TestParseDrmFdinfo(t *testing.T) {
+	t.Parallel()
+
+	fi, ok := parseDrmFdinfo(fmt.Sprintf(fdinfoI915, 100, 200))
+	if !ok || fi.driver != "i915" || fi.pdev != "0000:00:02.0" || fi.clientID != "7" {
+		t.Fatalf("i915 = %+v", fi)
+	}
+	if fi.engines["render"] != 100 || fi.engines["video"] != 200 || fi.capacity["video"] != 2 || fi.mem != 8<<20 {
+		t.Fatalf("i915 = %+v", fi)
+	}
+	if _, ok := fi.engines["capacity-video"]; ok {
+		t.Fatal("capacity parsed as engine")
+	}
+
+	fi, _ = parseDrmFdinfo(fmt.Sprintf(fdinfoXe, 10, 20, 30))
+	if fi.cycles["rcs"] != 10 || fi.total["rcs"] != 20 || fi.mem != 1<<20 {
+		t.Fatalf("xe = %+v", fi)
+	}
+
+	fi, _ = parseDrmFdinfo(fmt.Sprintf(fdinfoAmd, 5))
+	if fi.engines["gfx"] != 5 || fi.mem != 3<<20 {
+		t.Fatalf("amdgpu = %+v", fi)
+	}
+
+	if _, ok := parseDrmFdinfo("pos:\t0\nflags:\t02\n"); ok {
+		t.Fatal("plain fd parsed as drm client")
+	}
+}
+
+func TestScanDrmClients(t *testing.T) {
+	root := t.TempDir()
+	writeClient := func(pid, fd, content string) {
+		writeSysTree(t, root, map[string]string{
+			pid + "/comm":         "ffmpeg",
+			pid + "/fdinfo/" + fd: content,
+			pid + "/fd/0":         "not a drm node",
+		})
+		link := filepath.Join(root, pid, "fd", fd)
+		if _, err := os.Lstat(link); err == nil {
+			return
+		}
+		if err := os.Symlink("/dev/dri/renderD128", link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Unix(1000, 0)
+	writeClient("100", "5", fmt.Sprintf(fdinfoI915, 0, 0))
+	writeClient("100", "6", fmt.Sprintf(fdinfoI915, 0, 0)) // dup of fd 5
+	writeClient("200", "4", fmt.Sprintf(fdinfoXe, 0, 0, 0))
+	writeClient("300", "9", fmt.Sprintf(fdinfoAmd, 0))
+	clients := scanDrmClients(root, start)
+	if len(clients) != 3 || len(clients[0].Engines) != 0 || clients[0].MemBytes != 8<<20 {
+		t.Fatalf("first scan = %+v", clients)
+	}
+
+	// 5s later: render busy 2.5s, video 5s over two engines, xe rcs 3/4 of cycles, gfx all the time
+	writeClient("100", "5", fmt.Sprintf(fdinfoI915, 2_500_000_000, 5_000_000_000))
+	writeClient("100", "6", fmt.Sprintf(fdinfoI915, 2_500_000_000, 5_000_000_000))
+	writeClient("200", "4", fmt.Sprintf(fdinfoXe, 750, 1000, 1000))
+	writeClient("300", "9", fmt.Sprintf(fdinfoAmd, 5_000_000_000))
+	clients = scanDrmClients(root, start.Add(5*time.Second))
+	if len(clients) != 3 {
+		t.Fatalf("clients = %+v", clients)
+	}
+	if c := clients[0]; c.PID != 100 || c.Name != "ffmpeg" || c.Engines["render"] != 50 || c.Engines["video"] != 50 {
+		t.Fatalf("i915 = %+v", c)
+	}
+	if c := clients[1]; c.Driver != "xe" || c.Engines["render"] != 75 || c.Engines["video"] != 0 {
+		t.Fatalf("xe = %+v", c)
+	}
+	if c := clients[2]; c.Driver != "amdgpu" || c.Engines["gfx"] != 100 || c.MemBytes != 3<<20 {
+		t.Fatalf("amdgpu = %+v", c)
+	}
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GpuIntel struct {
+	Card       string             `json:"card"`
+	Driver     string             `json:"driver"` // i915, xe
+	PciBus     string             `json:"pciBus"`
+	Device     string             `json:"device"`
+	FreqMHz    int                `json:"freqMHz"`
+	FreqMaxMHz int                `json:"freqMaxMHz"`
+	Busy       float64            `json:"busy"`    // percent out of rc6 residency, -1 unknown
+	PowerW     float64            `json:"powerW"`  // -1 without an energy counter
+	Engines    map[string]float64 `json:"engines"` // percent per engine class summed over drm clients
+}
+
+type intelCard struct {
+	name   string
+	driver string
+	pciBus string
+	device string
+
+	freq    string
+	freqMax string
+	rc6     string // ms spent idle
+	energy  string // uJ, discrete cards only
+
+	prevTime   time.Time
+	prevRc6    uint64
+	prevEnergy uint64
+}
+
+func (c *Collector) collectGpuIntel() {
+	cards := findIntelCards("/sys/class/drm")
+	if len(cards) == 0 {
+		return
+	}
+
+	for now := range time.Tick(time.Second) {
+		// clients are refreshed every 5s by collectDrmClients
+		c.mu.RLock()
+		clients := c.data.GPUStats.Processes
+		c.mu.RUnlock()
+
+		gis := make([]GpuIntel, 0, len(cards))
+		for _, card := range cards {
+			gi := card.read(now)
+			gi.addClients(clients)
+			gis = append(gis, gi)
+		}
+		c.writeIntelStats(gis)
+	}
+}
+
+// addClients sums engine usage of the drm clients of this card
+func (gi *GpuIntel) addClients(clients []GpuClient) {
+	for _, cl := range clients {
+		if cl.PciBus != gi.PciBus {
+			continue
+		}
+		for e, v := range cl.Engines {
+			gi.Engines[e] = min(gi.Engines[e]+v, 100)
+		}
+	}
+}
+
+func (c *Collector) writeIntelStats(data []GpuIntel) {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.data.Time = time.Now().UTC()
+	c.data.GPUStats.Intel = data
+}
+
+func findIntelCards(drmRoot string) []*intelCard {
+	dirs, _ := filepath.Glob(drmRoot + "/card[0-9]*")
+	var cards []*intelCard
+	for _, dir := range dirs {
+		// connectors are card0-DP-1
+		if strings.Contains(filepath.Base(dir), "-") {
+			continue
+		}
+		driver, err := os.Readlink(dir + "/device/driver")
+		if err != nil {
+			continue
+		}
+		card := &intelCard{
+			name:   filepath.Base(dir),
+			driver: filepath.Base(driver),
+			device: readSysString(dir + "/device/device"),
+		}
+		if pci, err := filepath.EvalSymlinks(dir + "/device"); err == nil {
+			card.pciBus = filepath.Base(pci)
+		}
+		switch card.driver {
+		case "i915":
+			card.freq = dir + "/gt_act_freq_mhz"
+			card.freqMax = dir + "/gt_max_freq_mhz"
+			for _, p := range []string{dir + "/gt/gt0/rc6_residency_ms", dir + "/power/rc6_residency_ms"} {
+				if fileExists(p) {
+					card.rc6 = p
+					break
+				}
+			}
+		case "xe":
+			gt := dir + "/device/tile0/gt0"
+			card.freq = gt + "/freq0/act_freq"
+			card.freqMax = gt + "/freq0/max_freq"
+			card.rc6 = gt + "/gtidle/idle_residency_ms"
+		default:
+			continue
+		}
+		if energy, _ := filepath.Glob(dir + "/device/hwmon/hwmon*/energy1_input"); len(energy) > 0 {
+			card.energy = energy[0]
+		}
+		cards = append(cards, card)
+	}
+	return cards
+}
+
+func (ic *intelCard) read(now time.Time) GpuIntel {
+	gi := GpuIntel{
+		Card:    ic.name,
+		Driver:  ic.driver,
+		PciBus:  ic.pciBus,
+		Device:  ic.device,
+		Busy:    -1,
+		PowerW:  -1,
+		Engines: map[string]float64{},
+	}
+	gi.FreqMHz, _ = strconv.Atoi(readSysString(ic.freq))
+	gi.FreqMaxMHz, _ = strconv.Atoi(readSysString(ic.freqMax))
+
+	rc6, rc6Err := strconv.ParseUint(readSysString(ic.rc6), 10, 64)
+	energy, energyErr := strconv.ParseUint(readSysString(ic.energy), 10, 64)
+	if elapsed := now.Sub(ic.prevTime); !ic.prevTime.IsZero() && elapsed > 0 {
+		if rc6Err == nil && rc6 >= ic.prevRc6 {
+			idle := float64(rc6-ic.prevRc6) / float64(elapsed.Milliseconds()) * 100
+			gi.Busy = max(0, min(100-idle, 100))
+		}
+		if energyErr == nil && energy >= ic.prevEnergy {
+			gi.PowerW = float64(energy-ic.prevEnergy) / 1e6 / elapsed.Seconds()
+		}
+	}
+	ic.prevTime, ic.prevRc6, ic.prevEnergy = now, rc6, energy
+	return gi
+}
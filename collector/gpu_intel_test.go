@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindIntelCards(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeSysTree(t, root, map[string]string{
+		"devices/0000:00:02.0/device":                             "0x46a6",
+		"devices/0000:03:00.0/device":                             "0x56a0",
+		"devices/0000:03:00.0/tile0/gt0/freq0/act_freq":           "2050",
+		"devices/0000:03:00.0/tile0/gt0/freq0/max_freq":           "2400",
+		"devices/0000:03:00.0/tile0/gt0/gtidle/idle_residency_ms": "1000",
+		"devices/0000:03:00.0/hwmon/hwmon3/energy1_input":         "5000000",
+		"devices/0000:0a:00.0/device":                             "0x744c",
+		"drivers/i915/bind":                                       "",
+		"drivers/xe/bind":                                         "",
+		"drivers/amdgpu/bind":                                     "",
+		"drm/card0/gt_act_freq_mhz":                               "1300",
+		"drm/card0/gt_max_freq_mhz":                               "1400",
+		"drm/card0/gt/gt0/rc6_residency_ms":                       "500",
+		"drm/card0-eDP-1/status":                                  "connected",
+	})
+	link := func(target, name string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link("devices/0000:00:02.0", "drm/card0/device")
+	link("drivers/i915", "devices/0000:00:02.0/driver")
+	link("devices/0000:03:00.0", "drm/card1/device")
+	link("drivers/xe", "devices/0000:03:00.0/driver")
+	link("devices/0000:0a:00.0", "drm/card2/device")
+	link("drivers/amdgpu", "devices/0000:0a:00.0/driver")
+
+	cards := findIntelCards(filepath.Join(root, "drm"))
+	if len(cards) != 2 {
+		t.Fatalf("cards = %+v", cards)
+	}
+
+	start := time.Unix(1000, 0)
+	igpu := cards[0].read(start)
+	if igpu.Driver != "i915" || igpu.PciBus != "0000:00:02.0" || igpu.Device != "0x46a6" || igpu.FreqMHz != 1300 || igpu.FreqMaxMHz != 1400 {
+		t.Fatalf("igpu = %+v", igpu)
+	}
+	if igpu.Busy != -1 || igpu.PowerW != -1 {
+		t.Fatalf("first read = %+v", igpu)
+	}
+	cards[1].read(start)
+
+	// 2s later: the igpu idled 0.5s, the arc 1.5s and drew 30J
+	writeSysTree(t, root, map[string]string{
+		"drm/card0/gt/gt0/rc6_residency_ms":                       "1000",
+		"devices/0000:03:00.0/tile0/gt0/gtidle/idle_residency_ms": "2500",
+		"devices/0000:03:00.0/hwmon/hwmon3/energy1_input":         "35000000",
+	})
+	igpu = cards[0].read(start.Add(2 * time.Second))
+	if igpu.Busy != 75 || igpu.PowerW != -1 {
+		t.Fatalf("igpu = %+v", igpu)
+	}
+	arc := cards[1].read(start.Add(2 * time.Second))
+	if arc.Driver != "xe" || arc.FreqMHz != 2050 || arc.Busy != 25 || arc.PowerW != 15 {
+		t.Fatalf("arc = %+v", arc)
+	}
+}
+
+func TestIntelAddClients(t *testing.T) {
+	t.Parallel()
+
+	gi := GpuIntel{PciBus: "0000:00:02.0", Engines: map[string]float64{}}
+	gi.addClients([]GpuClient{
+		{PID: 10, PciBus: "0000:00:02.0", Engines: map[string]float64{"render": 70, "video": 5}, MemBytes: 64 << 20},
+		{PID: 11, PciBus: "0000:00:02.0", Engines: map[string]float64{"render": 45}, MemBytes: 64 << 20},
+		{PID: 12, PciBus: "0000:03:00.0", Engines: map[string]float64{"render": 90}},
+	})
+	if gi.Engines["render"] != 100 || gi.Engines["video"] != 5 || len(gi.Engines) != 2 {
+		t.Fatalf("error engines of card clients: %+v", gi.Engines)
+	}
+}
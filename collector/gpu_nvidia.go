@@ -1,10 +1,10 @@
 package collector
 
 import (
-	"bufio"
 	"encoding/xml"
 	"log"
-	"os/exec"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,44 +12,62 @@ import (
 var chanGpuNvidia = make(chan string, 1)
 
 func (c *Collector) collectGpuNvidia() {
-	cmd := exec.Command("nvidia-smi", "-q", "-x", "-l", "1")
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return
+	stall := 10 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("GPU_STALL_TIMEOUT")); err == nil && d >= 2*time.Second {
+		stall = d
+	} else if os.Getenv("GPU_STALL_TIMEOUT") != "" {
+		log.Println("[collector] GPU_STALL_TIMEOUT invalid, at least 2s, using", stall)
 	}
 
-	if err = cmd.Start(); err != nil {
-		return
-	}
 	go func() {
 		for {
 			c.parseGpuNvidia(<-chanGpuNvidia)
 		}
 	}()
 
-	reader := bufio.NewReader(stdout)
 	buff := strings.Builder{}
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		buff.WriteString(line)
+	sv := &supervisor{
+		name:       "nvidia-smi",
+		args:       []string{"nvidia-smi", "-q", "-x", "-l", "1"},
+		prefix:     "[collector]",
+		stall:      stall,
+		backoffMin: time.Second,
+		backoffMax: 5 * time.Minute,
+		line: func(line string) bool {
+			buff.WriteString(line)
+			buff.WriteString("\n")
 
-		if strings.TrimSpace(line) == "</nvidia_smi_log>" {
-			if len(chanGpuNvidia) == 0 {
-				chanGpuNvidia <- buff.String()
+			if strings.TrimSpace(line) == "</nvidia_smi_log>" {
+				if len(chanGpuNvidia) == 0 {
+					chanGpuNvidia <- buff.String()
+				}
+				buff.Reset()
+				return true
 			}
-			buff.Reset()
-		}
 
-		// safe buffer flush over 3MB
-		if buff.Len() > 3*1e6 {
-			log.Println("[collector] warn: over buffer gpu stats, forced flush")
+			// safe buffer flush over 3MB
+			if buff.Len() > 3*1e6 {
+				log.Println("[collector] warn: over buffer gpu stats, forced flush")
+				buff.Reset()
+			}
+			return false
+		},
+		down: func() {
 			buff.Reset()
-		}
+			// a frame still queued would bring the frozen values back
+			select {
+			case <-chanGpuNvidia:
+			default:
+			}
+			c.mu.Lock()
+			c.data.GPUStats.Nvidia = nil
+			c.mu.Unlock()
+		},
+		state: func(st *SubprocessState) {
+			c.ChanGpuState <- st
+		},
 	}
+	sv.run()
 }
 
 type NvidiaSmiLog struct {
@@ -70,7 +88,18 @@ type NvidiaSmiLog struct {
 			CurrentMig string `xml:"current_mig"`
 			PendingMig string `xml:"pending_mig"`
 		} `xml:"mig_mode"`
-		MigDevices               string `xml:"mig_devices"`
+		MigDevices struct {
+			MigDevice []struct {
+				Index             string `xml:"index"`
+				GpuInstanceID     string `xml:"gpu_instance_id"`
+				ComputeInstanceID string `xml:"compute_instance_id"`
+				FbMemoryUsage     struct {
+					Total string `xml:"total"`
+					Used  string `xml:"used"`
+					Free  string `xml:"free"`
+				} `xml:"fb_memory_usage"`
+			} `xml:"mig_device"`
+		} `xml:"mig_devices"`
 		AccountingMode           string `xml:"accounting_mode"`
 		AccountingModeBufferSize string `xml:"accounting_mode_buffer_size"`
 		DriverModel              struct {
@@ -140,20 +169,11 @@ type NvidiaSmiLog struct {
 			AtomicCapsInbound     string `xml:"atomic_caps_inbound"`
 			AtomicCapsOutbound    string `xml:"atomic_caps_outbound"`
 		} `xml:"pci"`
-		FanSpeed              string `xml:"fan_speed"`
-		PerformanceState      string `xml:"performance_state"`
-		ClocksThrottleReasons struct {
-			ClocksThrottleReasonGpuIdle                   string `xml:"clocks_throttle_reason_gpu_idle"`
-			ClocksThrottleReasonApplicationsClocksSetting string `xml:"clocks_throttle_reason_applications_clocks_setting"`
-			ClocksThrottleReasonSwPowerCap                string `xml:"clocks_throttle_reason_sw_power_cap"`
-			ClocksThrottleReasonHwSlowdown                string `xml:"clocks_throttle_reason_hw_slowdown"`
-			ClocksThrottleReasonHwThermalSlowdown         string `xml:"clocks_throttle_reason_hw_thermal_slowdown"`
-			ClocksThrottleReasonHwPowerBrakeSlowdown      string `xml:"clocks_throttle_reason_hw_power_brake_slowdown"`
-			ClocksThrottleReasonSyncBoost                 string `xml:"clocks_throttle_reason_sync_boost"`
-			ClocksThrottleReasonSwThermalSlowdown         string `xml:"clocks_throttle_reason_sw_thermal_slowdown"`
-			ClocksThrottleReasonDisplayClocksSetting      string `xml:"clocks_throttle_reason_display_clocks_setting"`
-		} `xml:"clocks_throttle_reasons"`
-		FbMemoryUsage struct {
+		FanSpeed              string        `xml:"fan_speed"`
+		PerformanceState      string        `xml:"performance_state"`
+		ClocksThrottleReasons nvidiaReasons `xml:"clocks_throttle_reasons"`
+		ClocksEventReasons    nvidiaReasons `xml:"clocks_event_reasons"` // renamed since NVIDIA-SMI 535
+		FbMemoryUsage         struct {
 			Total    string `xml:"total"`
 			Reserved string `xml:"reserved"`
 			Used     string `xml:"used"`
@@ -186,50 +206,8 @@ type NvidiaSmiLog struct {
 			PendingEcc string `xml:"pending_ecc"`
 		} `xml:"ecc_mode"`
 		EccErrors struct {
-			Volatile struct {
-				SingleBit struct {
-					DeviceMemory  string `xml:"device_memory"`
-					RegisterFile  string `xml:"register_file"`
-					L1Cache       string `xml:"l1_cache"`
-					L2Cache       string `xml:"l2_cache"`
-					TextureMemory string `xml:"texture_memory"`
-					TextureShm    string `xml:"texture_shm"`
-					Cbu           string `xml:"cbu"`
-					Total         string `xml:"total"`
-				} `xml:"single_bit"`
-				DoubleBit struct {
-					DeviceMemory  string `xml:"device_memory"`
-					RegisterFile  string `xml:"register_file"`
-					L1Cache       string `xml:"l1_cache"`
-					L2Cache       string `xml:"l2_cache"`
-					TextureMemory string `xml:"texture_memory"`
-					TextureShm    string `xml:"texture_shm"`
-					Cbu           string `xml:"cbu"`
-					Total         string `xml:"total"`
-				} `xml:"double_bit"`
-			} `xml:"volatile"`
-			Aggregate struct {
-				SingleBit struct {
-					DeviceMemory  string `xml:"device_memory"`
-					RegisterFile  string `xml:"register_file"`
-					L1Cache       string `xml:"l1_cache"`
-					L2Cache       string `xml:"l2_cache"`
-					TextureMemory string `xml:"texture_memory"`
-					TextureShm    string `xml:"texture_shm"`
-					Cbu           string `xml:"cbu"`
-					Total         string `xml:"total"`
-				} `xml:"single_bit"`
-				DoubleBit struct {
-					DeviceMemory  string `xml:"device_memory"`
-					RegisterFile  string `xml:"register_file"`
-					L1Cache       string `xml:"l1_cache"`
-					L2Cache       string `xml:"l2_cache"`
-					TextureMemory string `xml:"texture_memory"`
-					TextureShm    string `xml:"texture_shm"`
-					Cbu           string `xml:"cbu"`
-					Total         string `xml:"total"`
-				} `xml:"double_bit"`
-			} `xml:"aggregate"`
+			Volatile  NvidiaEccCounts `xml:"volatile"`
+			Aggregate NvidiaEccCounts `xml:"aggregate"`
 		} `xml:"ecc_errors"`
 		RetiredPages struct {
 			MultipleSingleBitRetirement struct {
@@ -243,8 +221,13 @@ type NvidiaSmiLog struct {
 			PendingBlacklist  string `xml:"pending_blacklist"`
 			PendingRetirement string `xml:"pending_retirement"`
 		} `xml:"retired_pages"`
-		RemappedRows string `xml:"remapped_rows"`
-		Temperature  struct {
+		RemappedRows struct {
+			Correctable   string `xml:"remapped_row_corr"`
+			Uncorrectable string `xml:"remapped_row_unc"`
+			Pending       string `xml:"remapped_row_pending"`
+			Failure       string `xml:"remapped_row_failure"`
+		} `xml:"remapped_rows"`
+		Temperature struct {
 			GpuTemp                string `xml:"gpu_temp"`
 			GpuTempMaxThreshold    string `xml:"gpu_temp_max_threshold"`
 			GpuTempSlowThreshold   string `xml:"gpu_temp_slow_threshold"`
@@ -334,6 +317,77 @@ type NvidiaSmiLog struct {
 	} `xml:"gpu"`
 }
 
+// NvidiaEccCounts covers both layouts, single/double bit of older boards and sram/dram since Ampere
+type NvidiaEccCounts struct {
+	SingleBit struct {
+		DeviceMemory  string `xml:"device_memory"`
+		RegisterFile  string `xml:"register_file"`
+		L1Cache       string `xml:"l1_cache"`
+		L2Cache       string `xml:"l2_cache"`
+		TextureMemory string `xml:"texture_memory"`
+		TextureShm    string `xml:"texture_shm"`
+		Cbu           string `xml:"cbu"`
+		Total         string `xml:"total"`
+	} `xml:"single_bit"`
+	DoubleBit struct {
+		DeviceMemory  string `xml:"device_memory"`
+		RegisterFile  string `xml:"register_file"`
+		L1Cache       string `xml:"l1_cache"`
+		L2Cache       string `xml:"l2_cache"`
+		TextureMemory string `xml:"texture_memory"`
+		TextureShm    string `xml:"texture_shm"`
+		Cbu           string `xml:"cbu"`
+		Total         string `xml:"total"`
+	} `xml:"double_bit"`
+	SramCorrectable   string `xml:"sram_correctable"`
+	SramUncorrectable string `xml:"sram_uncorrectable"`
+	DramCorrectable   string `xml:"dram_correctable"`
+	DramUncorrectable string `xml:"dram_uncorrectable"`
+}
+
+func (e *NvidiaEccCounts) typed() NvidiaEcc {
+	if e.SingleBit.Total != "" || e.DoubleBit.Total != "" {
+		return NvidiaEcc{
+			Correctable:   nvidiaCount(e.SingleBit.Total),
+			Uncorrectable: nvidiaCount(e.DoubleBit.Total),
+		}
+	}
+	sum := func(a, b string) int64 {
+		x, y := nvidiaCount(a), nvidiaCount(b)
+		if x < 0 || y < 0 {
+			return max(x, y)
+		}
+		return x + y
+	}
+	return NvidiaEcc{
+		Correctable:   sum(e.SramCorrectable, e.DramCorrectable),
+		Uncorrectable: sum(e.SramUncorrectable, e.DramUncorrectable),
+	}
+}
+
+// nvidiaReasons reads every <clocks_*_reason_*> flag, the set grows with drivers
+type nvidiaReasons struct {
+	Reason []struct {
+		XMLName xml.Name
+		State   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+func (r nvidiaReasons) active() []string {
+	var res []string
+	for _, v := range r.Reason {
+		if strings.TrimSpace(v.State) != "Active" {
+			continue
+		}
+		name := v.XMLName.Local
+		for _, prefix := range []string{"clocks_throttle_reason_", "clocks_event_reason_"} {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		res = append(res, name)
+	}
+	return res
+}
+
 func (c *Collector) parseGpuNvidia(data string) []SmiNvidia {
 	smi := &NvidiaSmiLog{}
 	err := xml.Unmarshal([]byte(data), smi)
@@ -342,6 +396,10 @@ func (c *Collector) parseGpuNvidia(data string) []SmiNvidia {
 		return nil
 	}
 
+	c.mu.RLock()
+	procs := c.procs
+	c.mu.RUnlock()
+
 	var stats []SmiNvidia
 	for _, g := range smi.Gpu {
 		power := g.PowerReadings.PowerDraw
@@ -356,7 +414,7 @@ func (c *Collector) parseGpuNvidia(data string) []SmiNvidia {
 			power = g.GpuPowerReadings.InstantPowerDraw
 		}
 
-		stats = append(stats, SmiNvidia{
+		st := SmiNvidia{
 			Name:   g.ProductName,
 			Driver: smi.DriverVersion,
 			PciDev: g.Pci.PciDevice,
@@ -380,7 +438,74 @@ func (c *Collector) parseGpuNvidia(data string) []SmiNvidia {
 			ClockSm:    g.Clocks.SmClock,
 			ClockMem:   g.Clocks.MemClock,
 			ClockVideo: g.Clocks.VideoClock,
-		})
+
+			UUID:          nvidiaString(g.Uuid),
+			Serial:        nvidiaString(g.Serial),
+			PState:        nvidiaString(g.PerformanceState),
+			FanPct:        nvidiaNum(g.FanSpeed),
+			MemTotalBytes: nvidiaBytes(g.FbMemoryUsage.Total),
+			MemUsedBytes:  nvidiaBytes(g.FbMemoryUsage.Used),
+			MemFreeBytes:  nvidiaBytes(g.FbMemoryUsage.Free),
+			UtilGpuPct:    nvidiaNum(g.Utilization.GpuUtil),
+			UtilMemPct:    nvidiaNum(g.Utilization.MemoryUtil),
+			UtilEncPct:    nvidiaNum(g.Utilization.EncoderUtil),
+			UtilDecPct:    nvidiaNum(g.Utilization.DecoderUtil),
+			TempC:         nvidiaNum(g.Temperature.GpuTemp),
+			TempMemC:      nvidiaNum(g.Temperature.MemoryTemp),
+			TempSlowC:     nvidiaNum(g.Temperature.GpuTempSlowThreshold),
+			TempMaxC:      nvidiaNum(g.Temperature.GpuTempMaxThreshold),
+			PowerW:        nvidiaNum(power),
+			PowerLimitW:   nvidiaNum(powerLimit),
+			ClockGraMHz:   int(nvidiaNum(g.Clocks.GraphicsClock)),
+			ClockSmMHz:    int(nvidiaNum(g.Clocks.SmClock)),
+			ClockMemMHz:   int(nvidiaNum(g.Clocks.MemClock)),
+			ClockVideoMHz: int(nvidiaNum(g.Clocks.VideoClock)),
+			PciTxBytes:    nvidiaBytes(strings.TrimSuffix(g.Pci.TxUtil, "/s")),
+			PciRxBytes:    nvidiaBytes(strings.TrimSuffix(g.Pci.RxUtil, "/s")),
+			Throttle:      append(g.ClocksThrottleReasons.active(), g.ClocksEventReasons.active()...),
+
+			EccEnabled:       g.EccMode.CurrentEcc == "Enabled",
+			EccVolatile:      g.EccErrors.Volatile.typed(),
+			EccAggregate:     g.EccErrors.Aggregate.typed(),
+			RetiredSingle:    nvidiaCount(g.RetiredPages.MultipleSingleBitRetirement.RetiredCount),
+			RetiredDouble:    nvidiaCount(g.RetiredPages.DoubleBitRetirement.RetiredCount),
+			RetiredPending:   g.RetiredPages.PendingRetirement == "Yes" || g.RetiredPages.PendingBlacklist == "Yes",
+			RemappedCorr:     nvidiaCount(g.RemappedRows.Correctable),
+			RemappedUnc:      nvidiaCount(g.RemappedRows.Uncorrectable),
+			RemappedPending:  g.RemappedRows.Pending == "Yes",
+			RemappedFailure:  g.RemappedRows.Failure == "Yes",
+			ResetRequired:    g.GpuResetStatus.ResetRequired == "Yes",
+			DrainRecommended: g.GpuResetStatus.DrainAndResetRecommended == "Yes",
+			MigMode:          nvidiaString(g.MigMode.CurrentMig),
+			Processes:        []GpuProcess{},
+		}
+
+		for _, m := range g.MigDevices.MigDevice {
+			st.Mig = append(st.Mig, NvidiaMig{
+				Index:           int(nvidiaCount(m.Index)),
+				GpuInstance:     int(nvidiaCount(m.GpuInstanceID)),
+				ComputeInstance: int(nvidiaCount(m.ComputeInstanceID)),
+				MemTotalBytes:   nvidiaBytes(m.FbMemoryUsage.Total),
+				MemUsedBytes:    nvidiaBytes(m.FbMemoryUsage.Used),
+			})
+		}
+
+		for _, p := range g.Processes.ProcessInfo {
+			gp := GpuProcess{
+				PID:             int(nvidiaCount(p.Pid)),
+				Type:            p.Type,
+				GpuInstance:     int(nvidiaCount(p.GpuInstanceID)),
+				ComputeInstance: int(nvidiaCount(p.ComputeInstanceID)),
+				MemUsedBytes:    nvidiaBytes(p.UsedMemory),
+			}
+			// joined with the last process scan, nil for processes started since
+			if pc, ok := procs[gp.PID]; ok {
+				gp.Proc = &pc
+			}
+			st.Processes = append(st.Processes, gp)
+		}
+
+		stats = append(stats, st)
 	}
 
 	defer c.mu.Unlock()
@@ -390,3 +515,54 @@ func (c *Collector) parseGpuNvidia(data string) []SmiNvidia {
 
 	return stats
 }
+
+// nvidiaString drops the N/A placeholder
+func nvidiaString(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "N/A" || s == "None" {
+		return ""
+	}
+	return s
+}
+
+// nvidiaNum parses the number of "45 C", "39.10 W", "2 %" or "139 MHz", N/A is zero
+func nvidiaNum(s string) float64 {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(f[0], 64)
+	return v
+}
+
+// nvidiaCount is -1 when the gpu does not report the counter
+func nvidiaCount(s string) int64 {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+var nvidiaUnits = map[string]uint64{
+	"B":   1,
+	"KB":  1 << 10,
+	"KiB": 1 << 10,
+	"MB":  1 << 20,
+	"MiB": 1 << 20,
+	"GB":  1 << 30,
+	"GiB": 1 << 30,
+}
+
+// nvidiaBytes parses "1270 MiB" or "0 KB" to bytes
+func nvidiaBytes(s string) uint64 {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return 0
+	}
+	v, err := strconv.ParseFloat(f[0], 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return uint64(v * float64(nvidiaUnits[f[1]]))
+}
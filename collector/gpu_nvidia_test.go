@@ -903,11 +903,19 @@ func TestParseGpuNvidia(t *testing.T) {
 `}
 
 	for k, d := range data {
-		g := (&Collector{}).parseGpuNvidia(d)
+		c := &Collector{procs: map[int]Proc{200870: {PID: 200870, Name: "python3", User: "ml"}}}
+		g := c.parseGpuNvidia(d)
 		if k == 0 {
 			if len(g) != 2 {
 				t.Fatal("expected 2 GPUs, arr test data:", k)
 			}
+			ps := g[0].Processes
+			if len(ps) != 2 || ps[0].PID != 200870 || ps[0].MemUsedBytes != 958<<20 || ps[0].GpuInstance != -1 {
+				t.Fatalf("processes %+v, arr test data: %d", ps, k)
+			}
+			if ps[0].Proc == nil || ps[0].Proc.Name != "python3" || ps[1].Proc != nil {
+				t.Fatalf("process join %+v %+v, arr test data: %d", ps[0].Proc, ps[1].Proc, k)
+			}
 		}
 		for _, gpu := range g {
 			if k == 0 {
@@ -917,9 +925,33 @@ func TestParseGpuNvidia(t *testing.T) {
 				if gpu.PowerLimit != "190.00 W" {
 					t.Fatal("expected '190.00 W', arr test data:", k)
 				}
+				if gpu.PowerW != 39.1 || gpu.PowerLimitW != 190 {
+					t.Fatalf("typed power %v/%v, arr test data: %d", gpu.PowerW, gpu.PowerLimitW, k)
+				}
+				if gpu.UUID != "GPU-0f28aa42-45b8-2019-1c8b-35a2ccd7ce89" || gpu.Serial != "" {
+					t.Fatalf("uuid %q serial %q, arr test data: %d", gpu.UUID, gpu.Serial, k)
+				}
+				if gpu.EccVolatile.Correctable != -1 || gpu.RetiredDouble != -1 || gpu.RemappedUnc != -1 || gpu.ResetRequired {
+					t.Fatalf("ecc %+v retired %d remapped %d, arr test data: %d", gpu.EccVolatile, gpu.RetiredDouble, gpu.RemappedUnc, k)
+				}
+				if len(gpu.Throttle) != 0 {
+					t.Fatal("expected no throttle reasons, got:", gpu.Throttle)
+				}
 			}
 
 			if k == 1 {
+				if gpu.PowerW != 8.19 || gpu.TempC != 39 || gpu.TempSlowC != 96 || gpu.ClockMemMHz != 405 {
+					t.Fatalf("typed values %+v, arr test data: %d", gpu, k)
+				}
+				if gpu.MemUsedBytes != 984<<20 || gpu.MemTotalBytes != 8192<<20 || gpu.FanPct != 33 {
+					t.Fatalf("typed mem %d/%d fan %v, arr test data: %d", gpu.MemUsedBytes, gpu.MemTotalBytes, gpu.FanPct, k)
+				}
+				if len(gpu.Throttle) != 1 || gpu.Throttle[0] != "gpu_idle" {
+					t.Fatal("expected gpu_idle throttle reason, got:", gpu.Throttle)
+				}
+				if len(gpu.Processes) != 1 || gpu.Processes[0].PID != 281698 || gpu.Processes[0].MemUsedBytes != 982<<20 || gpu.Processes[0].Proc != nil {
+					t.Fatalf("processes %+v, arr test data: %d", gpu.Processes, k)
+				}
 				if gpu.Power != "8.19 W" {
 					t.Fatal("expected '8.19 W', arr test data:", k)
 				}
@@ -930,3 +962,113 @@ func TestParseGpuNvidia(t *testing.T) {
 		}
 	}
 }
+
+func TestParseGpuNvidiaAmpere(t *testing.T) {
+	t.Parallel()
+
+	data := `<?xml version="1.0" ?>
+<nvidia_smi_log>
+	<driver_version>550.54.14</driver_version>
+	<gpu id="00000000:17:00.0">
+		<product_name>NVIDIA A100-SXM4-40GB</product_name>
+		<mig_mode>
+			<current_mig>Enabled</current_mig>
+			<pending_mig>Enabled</pending_mig>
+		</mig_mode>
+		<mig_devices>
+			<mig_device>
+				<index>0</index>
+				<gpu_instance_id>1</gpu_instance_id>
+				<compute_instance_id>0</compute_instance_id>
+				<fb_memory_usage>
+					<total>9728 MiB</total>
+					<reserved>0 MiB</reserved>
+					<used>12 MiB</used>
+					<free>9715 MiB</free>
+				</fb_memory_usage>
+			</mig_device>
+		</mig_devices>
+		<serial>1564720004631</serial>
+		<gpu_reset_status>
+			<reset_required>Yes</reset_required>
+			<drain_and_reset_recommended>No</drain_and_reset_recommended>
+		</gpu_reset_status>
+		<clocks_event_reasons>
+			<clocks_event_reason_gpu_idle>Not Active</clocks_event_reason_gpu_idle>
+			<clocks_event_reason_sw_power_cap>Active</clocks_event_reason_sw_power_cap>
+			<clocks_event_reason_hw_thermal_slowdown>Active</clocks_event_reason_hw_thermal_slowdown>
+		</clocks_event_reasons>
+		<ecc_mode>
+			<current_ecc>Enabled</current_ecc>
+			<pending_ecc>Enabled</pending_ecc>
+		</ecc_mode>
+		<ecc_errors>
+			<volatile>
+				<sram_correctable>2</sram_correctable>
+				<sram_uncorrectable>0</sram_uncorrectable>
+				<dram_correctable>5</dram_correctable>
+				<dram_uncorrectable>1</dram_uncorrectable>
+			</volatile>
+			<aggregate>
+				<sram_correctable>10</sram_correctable>
+				<sram_uncorrectable>0</sram_uncorrectable>
+				<dram_correctable>N/A</dram_correctable>
+				<dram_uncorrectable>3</dram_uncorrectable>
+			</aggregate>
+		</ecc_errors>
+		<retired_pages>
+			<multiple_single_bit_retirement>
+				<retired_count>N/A</retired_count>
+			</multiple_single_bit_retirement>
+			<double_bit_retirement>
+				<retired_count>N/A</retired_count>
+			</double_bit_retirement>
+			<pending_blacklist>N/A</pending_blacklist>
+			<pending_retirement>N/A</pending_retirement>
+		</retired_pages>
+		<remapped_rows>
+			<remapped_row_corr>0</remapped_row_corr>
+			<remapped_row_unc>2</remapped_row_unc>
+			<remapped_row_pending>Yes</remapped_row_pending>
+			<remapped_row_failure>No</remapped_row_failure>
+		</remapped_rows>
+		<processes>
+			<process_info>
+				<gpu_instance_id>1</gpu_instance_id>
+				<compute_instance_id>0</compute_instance_id>
+				<pid>4242</pid>
+				<type>C</type>
+				<process_name>python3</process_name>
+				<used_memory>1 GiB</used_memory>
+			</process_info>
+		</processes>
+	</gpu>
+</nvidia_smi_log>
+`
+	g := (&Collector{}).parseGpuNvidia(data)
+	if len(g) != 1 {
+		t.Fatal("expected 1 GPU, got:", len(g))
+	}
+	gpu := g[0]
+	if gpu.Serial != "1564720004631" || gpu.MigMode != "Enabled" || !gpu.EccEnabled || !gpu.ResetRequired {
+		t.Fatalf("gpu %+v", gpu)
+	}
+	if len(gpu.Throttle) != 2 || gpu.Throttle[0] != "sw_power_cap" || gpu.Throttle[1] != "hw_thermal_slowdown" {
+		t.Fatal("throttle reasons:", gpu.Throttle)
+	}
+	if gpu.EccVolatile != (NvidiaEcc{Correctable: 7, Uncorrectable: 1}) {
+		t.Fatalf("volatile ecc %+v", gpu.EccVolatile)
+	}
+	if gpu.EccAggregate != (NvidiaEcc{Correctable: 10, Uncorrectable: 3}) {
+		t.Fatalf("aggregate ecc %+v", gpu.EccAggregate)
+	}
+	if gpu.RemappedCorr != 0 || gpu.RemappedUnc != 2 || !gpu.RemappedPending || gpu.RemappedFailure || gpu.RetiredSingle != -1 {
+		t.Fatalf("remapped %d/%d pending %v failure %v retired %d", gpu.RemappedCorr, gpu.RemappedUnc, gpu.RemappedPending, gpu.RemappedFailure, gpu.RetiredSingle)
+	}
+	if len(gpu.Mig) != 1 || gpu.Mig[0].GpuInstance != 1 || gpu.Mig[0].MemTotalBytes != 9728<<20 {
+		t.Fatalf("mig %+v", gpu.Mig)
+	}
+	if len(gpu.Processes) != 1 || gpu.Processes[0].GpuInstance != 1 || gpu.Processes[0].MemUsedBytes != 1<<30 {
+		t.Fatalf("processes %+v", gpu.Processes)
+	}
+}
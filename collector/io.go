@@ -143,7 +143,9 @@ func (c *Collector) disksStatsHandler(file string) {
 
 	ios := map[string]IOStat{}
 	for devName, val := range ioLoad {
-		ios[devName] = *val
+		st := *val
+		st.Label = c.blockLabels[devName]
+		ios[devName] = st
 	}
 	c.data.IOStats = ios
 }
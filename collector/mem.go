@@ -1,15 +1,23 @@
 package collector
 
 import (
+	"bufio"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const pathArcStats = "/proc/spl/kstat/zfs/arcstats"
+
+var vmStatPrev = map[string]int{}
+
 func (c *Collector) collectMem() {
 	for range time.Tick(time.Second) {
 		c.procMemInfoHandler("/proc/meminfo")
+		c.vmStatHandler("/proc/vmstat")
+		c.zfsArcHandler(pathArcStats)
 	}
 }
 
@@ -37,12 +45,36 @@ func (c *Collector) procMemInfoHandler(file string) {
 			c.data.MemStats.MemTotal = val
 		case "MemFree":
 			c.data.MemStats.MemFree = val
+		case "MemAvailable":
+			c.data.MemStats.MemAvailable = val
 		case "Buffers":
 			c.data.MemStats.Buffers = val
 		case "Cached":
 			c.data.MemStats.Cached = val
+		case "Shmem":
+			c.data.MemStats.Shmem = val
 		case "Slab":
 			c.data.MemStats.Slab = val
+		case "SReclaimable":
+			c.data.MemStats.SReclaimable = val
+		case "SUnreclaim":
+			c.data.MemStats.SUnreclaim = val
+		case "Dirty":
+			c.data.MemStats.Dirty = val
+		case "Writeback":
+			c.data.MemStats.Writeback = val
+		case "AnonHugePages":
+			c.data.MemStats.AnonHugePages = val
+		case "HugePages_Total":
+			c.data.MemStats.HugePagesTotal = val
+		case "HugePages_Free":
+			c.data.MemStats.HugePagesFree = val
+		case "HugePages_Rsvd":
+			c.data.MemStats.HugePagesRsvd = val
+		case "HugePages_Surp":
+			c.data.MemStats.HugePagesSurp = val
+		case "Hugepagesize":
+			c.data.MemStats.HugePageSize = val
 		case "SwapTotal":
 			c.data.MemStats.SwapTotal = val
 		case "SwapFree":
@@ -50,3 +82,63 @@ func (c *Collector) procMemInfoHandler(file string) {
 		}
 	}
 }
+
+func (c *Collector) vmStatHandler(file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	rates := map[string]int{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, v, ok := strings.Cut(s.Text(), " ")
+		if !ok || (key != "pswpin" && key != "pswpout" && key != "pgmajfault") {
+			continue
+		}
+		val, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		if prev, ok := vmStatPrev[key]; ok && val >= prev {
+			rates[key] = val - prev
+		}
+		vmStatPrev[key] = val
+	}
+
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.data.MemStats.SwapIn = rates["pswpin"]
+	c.data.MemStats.SwapOut = rates["pswpout"]
+	c.data.MemStats.MajFaults = rates["pgmajfault"]
+}
+
+func (c *Collector) zfsArcHandler(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	size := parseArcStats(string(data))["size"]
+
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.data.MemStats.ZfsArc = int(size / 1024)
+}
+
+// parseArcStats reads kstat lines "name type data" into name -> data
+func parseArcStats(data string) map[string]uint64 {
+	res := map[string]uint64{}
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		if v, err := strconv.ParseUint(f[2], 10, 64); err == nil {
+			res[f[0]] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcMemInfoHandler(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "meminfo")
+	err := os.WriteFile(file, []byte(`MemTotal:       65758948 kB
+MemFree:         1869336 kB
+MemAvailable:   41237120 kB
+Buffers:          912472 kB
+Cached:         33010880 kB
+SwapCached:        10240 kB
+Active:         27338148 kB
+Shmem:            512000 kB
+Slab:            4190720 kB
+SReclaimable:    3670016 kB
+SUnreclaim:       520704 kB
+Dirty:               884 kB
+Writeback:            12 kB
+AnonHugePages:   2097152 kB
+HugePages_Total:      64
+HugePages_Free:       60
+HugePages_Rsvd:        2
+HugePages_Surp:        0
+Hugepagesize:       2048 kB
+SwapTotal:       8388604 kB
+SwapFree:        8126460 kB
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Collector{}
+	c.procMemInfoHandler(file)
+	m := c.data.MemStats
+	if m.MemTotal != 65758948 || m.MemFree != 1869336 || m.MemAvailable != 41237120 || m.Buffers != 912472 ||
+		m.Cached != 33010880 || m.SwapTotal != 8388604 || m.SwapFree != 8126460 {
+		t.Fatalf("error parse meminfo totals: %+v", m)
+	}
+	if m.Shmem != 512000 || m.Slab != 4190720 || m.SReclaimable != 3670016 || m.SUnreclaim != 520704 ||
+		m.Dirty != 884 || m.Writeback != 12 || m.AnonHugePages != 2097152 {
+		t.Fatalf("error parse meminfo breakdown: %+v", m)
+	}
+	if m.HugePagesTotal != 64 || m.HugePagesFree != 60 || m.HugePagesRsvd != 2 || m.HugePagesSurp != 0 || m.HugePageSize != 2048 {
+		t.Fatalf("error parse meminfo huge pages: %+v", m)
+	}
+}
+
+func TestVmStatHandler(t *testing.T) {
+	vmStatPrev = map[string]int{}
+
+	file := filepath.Join(t.TempDir(), "vmstat")
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &Collector{}
+	write("nr_free_pages 467334\npswpin 1000\npswpout 2000\npgmajfault 300\npgfault 999999\n")
+	c.vmStatHandler(file)
+	if m := c.data.MemStats; m.SwapIn != 0 || m.SwapOut != 0 || m.MajFaults != 0 {
+		t.Fatalf("expected no rates on the first read got %+v", m)
+	}
+
+	write("nr_free_pages 467000\npswpin 1016\npswpout 2064\npgmajfault 305\npgfault 1000999\n")
+	c.vmStatHandler(file)
+	if m := c.data.MemStats; m.SwapIn != 16 || m.SwapOut != 64 || m.MajFaults != 5 {
+		t.Fatalf("error vmstat rates: %+v", m)
+	}
+
+	// counters going back, a wrap or a restored snapshot, give no rate
+	write("pswpin 10\npswpout 2064\npgmajfault 305\n")
+	c.vmStatHandler(file)
+	if m := c.data.MemStats; m.SwapIn != 0 || m.SwapOut != 0 || m.MajFaults != 0 {
+		t.Fatalf("error vmstat rates after reset: %+v", m)
+	}
+	write("pswpin 12\npswpout 2064\npgmajfault 305\n")
+	c.vmStatHandler(file)
+	if m := c.data.MemStats; m.SwapIn != 2 {
+		t.Fatalf("error vmstat rate after reset: %+v", m)
+	}
+}
+
+func TestZfsArcHandler(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "arcstats")
+	err := os.WriteFile(file, []byte(`13 1 0x01 123 33456 5218453322 2147301099436
+name                            type data
+hits                            4    1928373
+misses                          4    28373
+size                            4    8589934592
+c_max                           4    33669623808
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Collector{}
+	c.zfsArcHandler(file)
+	if c.data.MemStats.ZfsArc != 8388608 {
+		t.Fatalf("expected arc 8388608 KiB got %d", c.data.MemStats.ZfsArc)
+	}
+}
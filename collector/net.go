@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pathNet = "/sys/class/net"
+
+type NetStat struct {
+	RxBytes        uint64 `json:"rxBytes"` // per second
+	TxBytes        uint64 `json:"txBytes"` // per second
+	RxPackets      uint64 `json:"rxPackets"`
+	TxPackets      uint64 `json:"txPackets"`
+	RxErrors       uint64 `json:"rxErrors"`
+	TxErrors       uint64 `json:"txErrors"`
+	RxDrops        uint64 `json:"rxDrops"`
+	TxDrops        uint64 `json:"txDrops"`
+	Speed          int    `json:"speed"` // Mbit/s, -1 unknown
+	Duplex         string `json:"duplex"`
+	MTU            int    `json:"mtu"`
+	OperState      string `json:"operState"`
+	CarrierChanges int    `json:"carrierChanges"`
+	Kind           string `json:"kind"`
+	Virtual        bool   `json:"virtual"`
+}
+
+type netCounters struct {
+	rxBytes, rxPackets, rxErrors, rxDrops uint64
+	txBytes, txPackets, txErrors, txDrops uint64
+}
+
+var netPrev = map[string]netCounters{}
+
+func (c *Collector) collectNet() {
+	for range time.Tick(time.Second) {
+		c.netDevHandler("/proc/net/dev")
+	}
+}
+
+func (c *Collector) netDevHandler(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	counters := parseNetDev(string(data))
+	stats := make(map[string]NetStat, len(counters))
+	for name, cur := range counters {
+		prev, ok := netPrev[name]
+		netPrev[name] = cur
+		// first sight or counters reset, wait for the next tick
+		if !ok || cur.rxBytes < prev.rxBytes || cur.txBytes < prev.txBytes {
+			continue
+		}
+
+		ns := netSysInfo(name)
+		ns.RxBytes = cur.rxBytes - prev.rxBytes
+		ns.TxBytes = cur.txBytes - prev.txBytes
+		ns.RxPackets = cur.rxPackets - prev.rxPackets
+		ns.TxPackets = cur.txPackets - prev.txPackets
+		ns.RxErrors = cur.rxErrors - prev.rxErrors
+		ns.TxErrors = cur.txErrors - prev.txErrors
+		ns.RxDrops = cur.rxDrops - prev.rxDrops
+		ns.TxDrops = cur.txDrops - prev.txDrops
+		stats[name] = ns
+	}
+	for name := range netPrev {
+		if _, ok := counters[name]; !ok {
+			delete(netPrev, name)
+		}
+	}
+
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.data.Time = time.Now().UTC()
+	c.data.NetStats = stats
+}
+
+func parseNetDev(data string) map[string]netCounters {
+	res := map[string]netCounters{}
+	s := bufio.NewScanner(strings.NewReader(data))
+	for s.Scan() {
+		name, values, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(values)
+		if len(f) < 16 {
+			continue
+		}
+		v := make([]uint64, 16)
+		for i := range v {
+			v[i], _ = strconv.ParseUint(f[i], 10, 64)
+		}
+		res[strings.TrimSpace(name)] = netCounters{
+			rxBytes: v[0], rxPackets: v[1], rxErrors: v[2], rxDrops: v[3],
+			txBytes: v[8], txPackets: v[9], txErrors: v[10], txDrops: v[11],
+		}
+	}
+	return res
+}
+
+func netSysInfo(name string) NetStat {
+	dir := pathNet + "/" + name
+	ns := NetStat{
+		Speed:     -1,
+		Duplex:    readSysString(dir + "/duplex"),
+		OperState: readSysString(dir + "/operstate"),
+	}
+	if v, err := strconv.Atoi(readSysString(dir + "/speed")); err == nil {
+		ns.Speed = v
+	}
+	ns.MTU, _ = strconv.Atoi(readSysString(dir + "/mtu"))
+	ns.CarrierChanges, _ = strconv.Atoi(readSysString(dir + "/carrier_changes"))
+	ns.Kind = netKind(name, dir)
+	ns.Virtual = ns.Kind != "physical"
+	return ns
+}
+
+// netKind classifies an interface so the dashboard can hide container and bridge noise
+func netKind(name, dir string) string {
+	exists := func(p string) bool {
+		_, err := os.Stat(dir + "/" + p)
+		return err == nil
+	}
+	switch {
+	case name == "lo" || readSysString(dir+"/type") == "772":
+		return "loopback"
+	case exists("bridge"):
+		return "bridge"
+	case exists("bonding"):
+		return "bond"
+	case strings.HasPrefix(name, "veth"):
+		return "veth"
+	case exists("tun_flags"):
+		return "tun"
+	case strings.HasPrefix(name, "wg"):
+		return "wireguard"
+	case exists("device"):
+		if exists("device/physfn") {
+			return "sriov"
+		}
+		return "physical"
+	}
+	return "virtual"
+}
+
+func readSysString(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
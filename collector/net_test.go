@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestParseNetDev(t *testing.T) {
+	t.Parallel()
+
+	nd := parseNetDev(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 11103838    1960    0    0    0     0          0         0 11103838    1960    0    0    0     0       0          0
+  eth0: 83324      50    3    7    0     0          0         0     4490      42    1    2    0     0       0          0
+`)
+	if len(nd) != 2 {
+		t.Fatalf("expected 2 got %d", len(nd))
+	}
+	eth := nd["eth0"]
+	if eth.rxBytes != 83324 || eth.rxPackets != 50 || eth.rxErrors != 3 || eth.rxDrops != 7 ||
+		eth.txBytes != 4490 || eth.txPackets != 42 || eth.txErrors != 1 || eth.txDrops != 2 {
+		t.Fatalf("error parse net dev: %+v", eth)
+	}
+}
@@ -1,8 +1,11 @@
 package collector
 
 import (
+	"bufio"
+	"bytes"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -10,8 +13,26 @@ import (
 
 const pathProc = "/proc"
 
+// USER_HZ, fixed at 100 on every architecture the kernel exports to userspace
+const clockTicks = 100
+
 type Processes []Proc
 
+type procSample struct {
+	start     uint64
+	ticks     uint64
+	readBytes uint64
+	wrBytes   uint64
+	time      time.Time
+}
+
+var (
+	procPrev  = map[int]procSample{}
+	procUsers = map[int]string{}
+	bootTime  int64
+	pageSize  = uint64(os.Getpagesize())
+)
+
 func (p *Proc) path(stat string) string {
 	return pathProc + "/" + strconv.Itoa(p.PID) + "/" + stat
 }
@@ -33,7 +54,7 @@ func (p *Proc) quantityFd() {
 	p.FDs = len(names)
 }
 
-func (p *Proc) fillState() {
+func (p *Proc) fillState() (ticks, start uint64) {
 	f, err := os.Open(p.path("stat"))
 	if err != nil {
 		return
@@ -45,18 +66,156 @@ func (p *Proc) fillState() {
 	reader := io.LimitReader(f, 1024)
 	s, _ := io.ReadAll(reader)
 
-	sn := strings.SplitN(string(s), " ", 21)
-	if len(sn) < 19 {
+	return p.parseStat(string(s))
+}
+
+// parseStat reads /proc/<pid>/stat, comm may contain spaces and parentheses so split after the last ')'
+func (p *Proc) parseStat(s string) (ticks, start uint64) {
+	l, r := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if l < 0 || r < l {
+		return
+	}
+	p.Name = s[l+1 : r]
+
+	sn := strings.Fields(s[r+1:])
+	if len(sn) < 20 {
+		return
+	}
+	p.State = sn[0]
+	p.PPID, _ = strconv.Atoi(sn[1])
+	p.Threads, _ = strconv.Atoi(sn[17])
+
+	utime, _ := strconv.ParseUint(sn[11], 10, 64)
+	stime, _ := strconv.ParseUint(sn[12], 10, 64)
+	start, _ = strconv.ParseUint(sn[19], 10, 64)
+	if bootTime > 0 {
+		p.StartTime = time.Unix(bootTime+int64(start/clockTicks), 0).UTC()
+	}
+	return utime + stime, start
+}
+
+func (p *Proc) fillMem() {
+	statm, err := os.ReadFile(p.path("statm"))
+	if err == nil {
+		if f := strings.Fields(string(statm)); len(f) > 1 {
+			rss, _ := strconv.ParseUint(f[1], 10, 64)
+			p.RSS = rss * pageSize
+		}
+	}
+
+	// kernel threads and foreign processes without ptrace access have no rollup
+	rollup, err := os.ReadFile(p.path("smaps_rollup"))
+	if err != nil {
+		return
+	}
+	s := bufio.NewScanner(bytes.NewReader(rollup))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) > 1 && f[0] == "Pss:" {
+			pss, _ := strconv.ParseUint(f[1], 10, 64)
+			p.PSS = pss * 1024
+			break
+		}
+	}
+}
+
+func (p *Proc) fillIO() {
+	data, err := os.ReadFile(p.path("io"))
+	if err != nil {
 		return
 	}
-	p.Name = sn[1][1 : len(sn[1])-1]
-	p.State = sn[2]
-	p.PPID, _ = strconv.Atoi(sn[3])
-	p.Threads, _ = strconv.Atoi(sn[19])
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "read_bytes:":
+			p.ReadBytes, _ = strconv.ParseUint(f[1], 10, 64)
+		case "write_bytes:":
+			p.WriteBytes, _ = strconv.ParseUint(f[1], 10, 64)
+		}
+	}
+}
+
+func (p *Proc) fillOwner() {
+	data, err := os.ReadFile(p.path("status"))
+	if err != nil {
+		return
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			return
+		}
+		p.UID, _ = strconv.Atoi(f[1])
+		p.User = procUsers[p.UID]
+		return
+	}
+}
+
+func (p *Proc) fillCmdline() {
+	f, err := os.Open(p.path("cmdline"))
+	if err != nil {
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	s, _ := io.ReadAll(f)
+	p.Cmdline = strings.TrimSpace(string(bytes.ReplaceAll(s, []byte{0}, []byte{' '})))
+}
+
+func (p *Proc) fillCgroup() {
+	data, err := os.ReadFile(p.path("cgroup"))
+	if err != nil {
+		return
+	}
+	p.Cgroup = parseProcCgroup(string(data))
+}
+
+// parseProcCgroup prefers the unified v2 hierarchy, then the v1 cpu controller
+func parseProcCgroup(data string) string {
+	cpu := ""
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		f := strings.SplitN(line, ":", 3)
+		if len(f) < 3 {
+			continue
+		}
+		if f[0] == "0" && f[1] == "" && f[2] != "/" {
+			return f[2]
+		}
+		for _, ctl := range strings.Split(f[1], ",") {
+			if ctl == "cpu" && cpu == "" {
+				cpu = f[2]
+			}
+		}
+	}
+	return cpu
 }
 
 func (c *Collector) collectProc() {
+	bootTime = readBootTime(pathProc + "/stat")
+
+	topN, _ := strconv.Atoi(os.Getenv("PROCESSES_TOP"))
+	sortBy := os.Getenv("PROCESSES_SORT")
+
+	// uids belong to the host, the passwd of the container knows none of its users
+	passwd := ""
+	if hostProc := os.Getenv("HOST_PROC"); hostProc != "" {
+		passwd = hostProc + "/1/root/etc/passwd"
+	}
+
 	for range time.Tick(30 * time.Second) {
+		procUsers = readPasswd(passwd)
+
 		d, err := os.Open(pathProc)
 		if err != nil {
 			return
@@ -67,6 +226,8 @@ func (c *Collector) collectProc() {
 		}
 		_ = d.Close()
 
+		now := time.Now()
+		seen := make(map[int]procSample, len(names))
 		p := Processes{}
 		for _, n := range names {
 			pid, err := strconv.ParseInt(n, 10, 64)
@@ -75,9 +236,110 @@ func (c *Collector) collectProc() {
 			}
 			pc := Proc{PID: int(pid)}
 			pc.quantityFd()
-			pc.fillState()
+			ticks, start := pc.fillState()
+			pc.fillMem()
+			pc.fillIO()
+			pc.fillOwner()
+			pc.fillCmdline()
+			pc.fillCgroup()
+
+			cur := procSample{
+				start:     start,
+				ticks:     ticks,
+				readBytes: pc.ReadBytes,
+				wrBytes:   pc.WriteBytes,
+				time:      now,
+			}
+			// same pid with another start time is a reused pid, no delta
+			if prev, ok := procPrev[pc.PID]; ok && prev.start == start {
+				pc.delta(prev, cur)
+			}
+			seen[pc.PID] = cur
+
 			p = append(p, pc)
 		}
+		procPrev = seen
+
+		byPID := make(map[int]Proc, len(p))
+		for _, pc := range p {
+			byPID[pc.PID] = pc
+		}
+		c.mu.Lock()
+		c.procs = byPID
+		c.mu.Unlock()
+
+		p.top(sortBy, topN)
 		c.ChanProcesses <- &p
 	}
 }
+
+func (p *Proc) delta(prev, cur procSample) {
+	sec := cur.time.Sub(prev.time).Seconds()
+	if sec <= 0 {
+		return
+	}
+	if cur.ticks >= prev.ticks {
+		p.CPU = float64(cur.ticks-prev.ticks) / clockTicks / sec * 100
+	}
+	if cur.readBytes >= prev.readBytes {
+		p.ReadRate = uint64(float64(cur.readBytes-prev.readBytes) / sec)
+	}
+	if cur.wrBytes >= prev.wrBytes {
+		p.WriteRate = uint64(float64(cur.wrBytes-prev.wrBytes) / sec)
+	}
+}
+
+// top sorts processes by cpu or mem (rss) and keeps the first n, n <= 0 keeps all
+func (ps *Processes) top(by string, n int) {
+	switch by {
+	case "cpu":
+		sort.SliceStable(*ps, func(i, j int) bool {
+			return (*ps)[i].CPU > (*ps)[j].CPU
+		})
+	case "mem":
+		sort.SliceStable(*ps, func(i, j int) bool {
+			return (*ps)[i].RSS > (*ps)[j].RSS
+		})
+	}
+	if n > 0 && len(*ps) > n {
+		*ps = (*ps)[:n]
+	}
+}
+
+// readPasswd maps uids to user names, an empty path leaves only the numeric uids
+func readPasswd(file string) map[int]string {
+	users := map[int]string{}
+	if file == "" {
+		return users
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return users
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Split(line, ":")
+		if len(f) < 3 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if uid, err := strconv.Atoi(f[2]); err == nil {
+			if _, ok := users[uid]; !ok {
+				users[uid] = f[0]
+			}
+		}
+	}
+	return users
+}
+
+func readBootTime(file string) int64 {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			bt, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return bt
+		}
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	t.Parallel()
+
+	p := Proc{PID: 6277}
+	ticks, start := p.parseStat("6277 (Web (Content) 1) S 6273 6277 6273 0 -1 4194304 82 0 0 0 " +
+		"250 70 0 0 20 0 12 0 104120 2703360 327 18446744073709551615 94092082888704 0")
+	if p.Name != "Web (Content) 1" || p.State != "S" || p.PPID != 6273 || p.Threads != 12 {
+		t.Fatalf("error parse proc stat: %+v", p)
+	}
+	if ticks != 320 || start != 104120 {
+		t.Fatalf("expected ticks 320 start 104120 got %d %d", ticks, start)
+	}
+}
+
+func TestParseProcCgroup(t *testing.T) {
+	t.Parallel()
+
+	v2 := "0::/system.slice/docker-3f2a.scope\n"
+	if cg := parseProcCgroup(v2); cg != "/system.slice/docker-3f2a.scope" {
+		t.Fatal("expected docker scope got", cg)
+	}
+	v1 := "9:name=systemd:/\n2:cpuacct:/\n1:cpu,cpuacct:/docker/3f2a\n0::/\n"
+	if cg := parseProcCgroup(v1); cg != "/docker/3f2a" {
+		t.Fatal("expected /docker/3f2a got", cg)
+	}
+}
+
+func TestProcessesTop(t *testing.T) {
+	t.Parallel()
+
+	ps := Processes{{PID: 1, CPU: 1, RSS: 30}, {PID: 2, CPU: 50, RSS: 10}, {PID: 3, CPU: 7, RSS: 20}}
+	ps.top("cpu", 2)
+	if len(ps) != 2 || ps[0].PID != 2 || ps[1].PID != 3 {
+		t.Fatalf("error top by cpu: %+v", ps)
+	}
+	ps.top("mem", 0)
+	if ps[0].PID != 3 {
+		t.Fatalf("error top by mem: %+v", ps)
+	}
+}
+
+func TestReadPasswd(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "passwd")
+	err := os.WriteFile(file, []byte("root:x:0:0:root:/root:/bin/bash\n"+
+		"# comment\n"+
+		"postgres:x:70:70::/var/lib/postgresql:/bin/sh\n"+
+		"toor:x:0:0::/root:/bin/sh\n"+
+		"broken line\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := readPasswd(file)
+	if len(users) != 2 || users[0] != "root" || users[70] != "postgres" {
+		t.Fatalf("error read passwd: %+v", users)
+	}
+	if users := readPasswd(""); len(users) != 0 {
+		t.Fatalf("expected no users without HOST_PROC got %+v", users)
+	}
+}
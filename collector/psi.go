@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pathPressure = "/proc/pressure"
+
+type PSIStats struct {
+	CPU    *PSIResource `json:"cpu,omitempty"`
+	Memory *PSIResource `json:"memory,omitempty"`
+	IO     *PSIResource `json:"io,omitempty"`
+}
+
+type PSIResource struct {
+	Some PSILine  `json:"some"`
+	Full *PSILine `json:"full,omitempty"` // cpu full is reported since kernel 5.13
+}
+
+type PSILine struct {
+	Avg10  float64 `json:"avg10"`  // percent
+	Avg60  float64 `json:"avg60"`  // percent
+	Avg300 float64 `json:"avg300"` // percent
+	Stall  uint64  `json:"stall"`  // microseconds stalled since the previous tick
+	total  uint64
+}
+
+var psiPrev = map[string]uint64{}
+
+func (c *Collector) collectPSI() {
+	// kernel without CONFIG_PSI or booted with psi=0
+	if _, err := os.Stat(pathPressure + "/cpu"); err != nil {
+		return
+	}
+
+	for range time.Tick(time.Second) {
+		ps := &PSIStats{
+			CPU:    psiResourceHandler("cpu"),
+			Memory: psiResourceHandler("memory"),
+			IO:     psiResourceHandler("io"),
+		}
+		c.mu.Lock()
+		c.data.PSIStats = ps
+		c.mu.Unlock()
+	}
+}
+
+func psiResourceHandler(resource string) *PSIResource {
+	data, err := os.ReadFile(pathPressure + "/" + resource)
+	if err != nil {
+		return nil
+	}
+	r := parsePSI(string(data))
+	if r == nil {
+		return nil
+	}
+	r.Some.Stall = psiDelta(resource+"/some", r.Some.total)
+	if r.Full != nil {
+		r.Full.Stall = psiDelta(resource+"/full", r.Full.total)
+	}
+	return r
+}
+
+func psiDelta(key string, total uint64) uint64 {
+	prev, ok := psiPrev[key]
+	psiPrev[key] = total
+	if !ok || total < prev {
+		return 0
+	}
+	return total - prev
+}
+
+func parsePSI(data string) *PSIResource {
+	var r *PSIResource
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 5 {
+			continue
+		}
+		pl := PSILine{}
+		for _, kv := range f[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "avg10":
+				pl.Avg10, _ = strconv.ParseFloat(v, 64)
+			case "avg60":
+				pl.Avg60, _ = strconv.ParseFloat(v, 64)
+			case "avg300":
+				pl.Avg300, _ = strconv.ParseFloat(v, 64)
+			case "total":
+				pl.total, _ = strconv.ParseUint(v, 10, 64)
+			}
+		}
+		if r == nil {
+			r = &PSIResource{}
+		}
+		switch f[0] {
+		case "some":
+			r.Some = pl
+		case "full":
+			r.Full = &pl
+		}
+	}
+	return r
+}
@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestParsePSI(t *testing.T) {
+	t.Parallel()
+
+	mem := parsePSI(`some avg10=3.09 avg60=1.69 avg300=1.67 total=18410742
+full avg10=0.50 avg60=0.00 avg300=0.04 total=1752249
+`)
+	if mem == nil || mem.Full == nil {
+		t.Fatal("expected some and full lines")
+	}
+	if mem.Some.Avg10 != 3.09 || mem.Some.Avg300 != 1.67 || mem.Some.total != 18410742 {
+		t.Fatalf("error parse psi some: %+v", mem.Some)
+	}
+	if mem.Full.Avg10 != 0.5 || mem.Full.total != 1752249 {
+		t.Fatalf("error parse psi full: %+v", mem.Full)
+	}
+
+	// cpu before kernel 5.13 has no full line
+	cpu := parsePSI("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	if cpu == nil || cpu.Full != nil {
+		t.Fatalf("error parse psi cpu: %+v", cpu)
+	}
+}
@@ -4,11 +4,32 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// pseudo and in-memory filesystems are never reported in discovery mode
+var spacePseudoFs = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "tmpfs": true, "ramfs": true,
+	"cgroup": true, "cgroup2": true, "securityfs": true, "pstore": true, "debugfs": true, "tracefs": true,
+	"configfs": true, "fusectl": true, "mqueue": true, "hugetlbfs": true, "bpf": true, "autofs": true,
+	"binfmt_misc": true, "overlay": true, "nsfs": true, "squashfs": true, "efivarfs": true, "rpc_pipefs": true,
+	"selinuxfs": true, "nfsd": true, "fuse.lxcfs": true, "fuse.gvfsd-fuse": true, "fuse.portal": true,
+}
+
+type mountEntry struct {
+	MajMin       string
+	Root         string
+	MountPoint   string
+	Options      string
+	FsType       string
+	Source       string
+	SuperOptions string
+}
+
 func (c *Collector) collectSpace() {
 	var volumes []string
 	if _, err := os.Stat("/_external"); !os.IsNotExist(err) {
@@ -27,32 +48,238 @@ func (c *Collector) collectSpace() {
 		}
 	}
 
+	// SPACE_DISCOVERY=mountinfo reports every real filesystem of the host, markers become an allow-list,
+	// HOST_PROC must point at the proc of the host (-v /proc:/host/proc:ro), the own /proc/1 of the container
+	// is the agent, HOST_PROC=/proc is right only when the agent runs on the host itself
+	discovery := os.Getenv("SPACE_DISCOVERY") == "mountinfo"
+	hostProc := os.Getenv("HOST_PROC")
+	if discovery && hostProc == "" {
+		log.Println("[collector] SPACE_DISCOVERY=mountinfo needs HOST_PROC, using markers")
+		discovery = false
+	}
+	allowed := map[string]bool{}
+	for _, path := range volumes {
+		allowed[filepath.Dir(strings.Replace(path, "/_external", "", 1))] = true
+	}
+
+	trend := newSpaceTrend()
+	guard := &statfsGuard{timeout: time.Second, inflight: map[string]bool{}, statfs: statFS}
+	var mounts []mountEntry
+	tick := 0
 	for range time.Tick(time.Second) {
+		if discovery && tick%30 == 0 {
+			data, err := os.ReadFile(hostProc + "/1/mountinfo")
+			if err != nil {
+				log.Println("[collector] read mountinfo err:", err)
+			} else {
+				mounts = filterMounts(parseMountinfo(string(data)), allowed)
+			}
+		}
+		tick++
+
+		// statfs runs outside the lock, a hung nfs or cifs server must not stall other collectors
+		stats := map[string]SpaceStatFS{}
+		if discovery {
+			for _, m := range mounts {
+				statMount(guard, hostProc+"/1/root", m, stats)
+			}
+		} else {
+			statPath(guard, "/", stats)
+			for _, path := range volumes {
+				statPath(guard, path, stats)
+			}
+		}
+
 		c.mu.Lock()
 		c.data.Time = time.Now().UTC()
-		c.data.SpaceStats = map[string]SpaceStatFS{}
-		c.handlerStatFS("/")
-		for _, path := range volumes {
-			c.handlerStatFS(path)
+		for mount, r := range stats {
+			r.Label = c.blockLabels[r.Device]
+			stats[mount] = r
 		}
+		c.data.SpaceStats = stats
+		events := trend.apply(c.data.SpaceStats, c.data.Time)
 		c.mu.Unlock()
+
+		for _, ev := range events {
+			c.ChanSpaceForecast <- ev
+		}
+	}
+}
+
+func statPath(guard *statfsGuard, path string, stats map[string]SpaceStatFS) {
+	r, ok := guard.stat(path)
+	if !ok {
+		return
+	}
+	r.Device = blockDeviceOf("/sys", path)
+
+	realPath := strings.Replace(path, "/_external", "", 1)
+	stats[filepath.Dir(realPath)] = r
+}
+
+// statMount stats a host mount through the root of host pid 1
+func statMount(guard *statfsGuard, hostRoot string, m mountEntry, stats map[string]SpaceStatFS) {
+	r, ok := guard.stat(hostRoot + m.MountPoint)
+	if !ok {
+		return
+	}
+	r.FsType = m.FsType
+	r.Source = m.Source
+	r.Options = m.Options
+	r.ReadOnly = r.ReadOnly || mountReadOnly(m)
+	r.Device = blockDeviceByNumber("/sys", m.MajMin)
+	stats[m.MountPoint] = r
+}
+
+// statfsGuard bounds statfs by a timeout, a path whose previous call has not returned yet,
+// a hung network mount, is skipped until the kernel gives it back
+type statfsGuard struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	inflight map[string]bool
+	statfs   func(path string) (SpaceStatFS, bool)
+}
+
+func (g *statfsGuard) stat(path string) (SpaceStatFS, bool) {
+	g.mu.Lock()
+	if g.inflight[path] {
+		g.mu.Unlock()
+		return SpaceStatFS{}, false
+	}
+	g.inflight[path] = true
+	g.mu.Unlock()
+
+	type result struct {
+		r  SpaceStatFS
+		ok bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, ok := g.statfs(path)
+		g.mu.Lock()
+		delete(g.inflight, path)
+		g.mu.Unlock()
+		done <- result{r, ok}
+	}()
+
+	select {
+	case res := <-done:
+		return res.r, res.ok
+	case <-time.After(g.timeout):
+		log.Println("[collector] stat fs path: "+path+" timed out after", g.timeout)
+		return SpaceStatFS{}, false
 	}
 }
 
-func (c *Collector) handlerStatFS(path string) {
+func statFS(path string) (SpaceStatFS, bool) {
 	var sf syscall.Statfs_t
 	err := syscall.Statfs(path, &sf)
 	if err != nil {
 		log.Println("[collector] stat fs path: "+path+" err: ", err)
-		return
+		return SpaceStatFS{}, false
 	}
 	r := SpaceStatFS{
 		ReservedBlocks: sf.Bfree - sf.Bavail,
 		BlockSize:      uint64(sf.Bsize),
+		Files:          sf.Files,
+		FilesFree:      sf.Ffree,
+		ReadOnly:       sf.Flags&0x1 != 0, // ST_RDONLY
 	}
 	r.Total = r.BlockSize * (sf.Blocks - r.ReservedBlocks)
 	r.Free = r.BlockSize * sf.Bavail
+	return r, true
+}
 
-	realPath := strings.Replace(path, "/_external", "", 1)
-	c.data.SpaceStats[filepath.Dir(realPath)] = r
+// parseMountinfo reads /proc/<pid>/mountinfo:
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountinfo(data string) []mountEntry {
+	var res []mountEntry
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Fields(line)
+		sep := -1
+		for i, v := range f {
+			if v == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 6 || len(f) < sep+3 {
+			continue
+		}
+		m := mountEntry{
+			MajMin:     f[2],
+			Root:       unescapeMount(f[3]),
+			MountPoint: unescapeMount(f[4]),
+			Options:    f[5],
+			FsType:     f[sep+1],
+			Source:     unescapeMount(f[sep+2]),
+		}
+		if len(f) > sep+3 {
+			m.SuperOptions = f[sep+3]
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+// filterMounts drops pseudo filesystems and bind mounts of an already listed filesystem,
+// with an allow-list only / and listed mount points are kept
+func filterMounts(mounts []mountEntry, allowed map[string]bool) []mountEntry {
+	best := map[string]int{}
+	var res []mountEntry
+	for _, m := range mounts {
+		if spacePseudoFs[m.FsType] {
+			continue
+		}
+		if len(allowed) > 0 && m.MountPoint != "/" && !allowed[m.MountPoint] {
+			continue
+		}
+		// zfs datasets share no device number, the source tells them apart
+		key := m.MajMin
+		if m.FsType == "zfs" || strings.HasPrefix(m.FsType, "nfs") || m.FsType == "cifs" {
+			key = m.FsType + ":" + m.Source
+		}
+		i, seen := best[key]
+		if !seen {
+			best[key] = len(res)
+			res = append(res, m)
+			continue
+		}
+		// prefer the mount of the filesystem root, then the shorter path
+		cur := res[i]
+		if (m.Root == "/" && cur.Root != "/") || (m.Root == cur.Root && len(m.MountPoint) < len(cur.MountPoint)) {
+			res[i] = m
+		}
+	}
+	return res
+}
+
+func mountReadOnly(m mountEntry) bool {
+	for _, opts := range []string{m.Options, m.SuperOptions} {
+		for _, o := range strings.Split(opts, ",") {
+			if o == "ro" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unescapeMount undoes octal escapes of space, tab, newline and backslash
+func unescapeMount(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
 }
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+type SpaceForecast struct {
+	Time               time.Time `json:"time"`
+	Mount              string    `json:"mount"`
+	State              string    `json:"state"` // filling, resolved
+	Free               uint64    `json:"free"`
+	Total              uint64    `json:"total"`
+	GrowthBytesPerHour float64   `json:"growthBytesPerHour"`
+	TimeToFull         int64     `json:"timeToFull"` // seconds
+	Horizon            int64     `json:"horizon"`    // seconds
+}
+
+// time to full is capped at a hundred years
+const spaceMaxTimeToFull = 100 * 365 * 24 * 3600.0
+
+type spaceSample struct {
+	t    time.Time
+	used float64
+}
+
+// spaceTrend keeps a rolling window of used bytes per mount and fits a line through it
+type spaceTrend struct {
+	window  time.Duration
+	every   time.Duration
+	horizon time.Duration
+	samples map[string][]spaceSample
+	growth  map[string]float64 // bytes per second of the last fit
+	firing  map[string]bool
+}
+
+func newSpaceTrend() *spaceTrend {
+	st := &spaceTrend{
+		window:  time.Hour,
+		every:   10 * time.Second,
+		horizon: 24 * time.Hour,
+		samples: map[string][]spaceSample{},
+		growth:  map[string]float64{},
+		firing:  map[string]bool{},
+	}
+	if d, err := time.ParseDuration(os.Getenv("SPACE_FORECAST_WINDOW")); err == nil && d > time.Minute {
+		st.window = d
+		st.every = max(10*time.Second, d/360)
+	} else if os.Getenv("SPACE_FORECAST_WINDOW") != "" {
+		log.Println("[collector] SPACE_FORECAST_WINDOW invalid, at least 1m, using", st.window)
+	}
+	if d, err := time.ParseDuration(os.Getenv("SPACE_FULL_HORIZON")); err == nil && d > 0 {
+		st.horizon = d
+	} else if os.Getenv("SPACE_FULL_HORIZON") != "" {
+		log.Println("[collector] SPACE_FULL_HORIZON invalid, using", st.horizon)
+	}
+	return st
+}
+
+// apply fills growth and time to full of every mount, returns events for mounts crossing the horizon
+func (st *spaceTrend) apply(stats map[string]SpaceStatFS, now time.Time) []*SpaceForecast {
+	var events []*SpaceForecast
+	for mount, r := range stats {
+		samples := st.samples[mount]
+		if len(samples) == 0 || now.Sub(samples[len(samples)-1].t) >= st.every {
+			samples = append(samples, spaceSample{t: now, used: float64(r.Total) - float64(r.Free)})
+			cut := 0
+			for cut < len(samples) && now.Sub(samples[cut].t) > st.window {
+				cut++
+			}
+			samples = samples[cut:]
+			st.samples[mount] = samples
+			st.growth[mount] = spaceSlope(samples)
+		}
+
+		growth := st.growth[mount]
+		r.GrowthBytesPerHour = growth * 3600
+		r.TimeToFull = -1
+		// compared in float seconds, noise growth on a large static filesystem is centuries away
+		toFull := -1.0
+		if growth > 0 {
+			toFull = float64(r.Free) / growth
+			r.TimeToFull = int64(min(toFull, spaceMaxTimeToFull))
+		}
+		stats[mount] = r
+
+		filling := toFull >= 0 && toFull < st.horizon.Seconds()
+		// resolve only well above the horizon so a steady rate near it does not flap
+		calm := toFull < 0 || toFull > st.horizon.Seconds()*5/4
+		switch {
+		case filling && !st.firing[mount]:
+			st.firing[mount] = true
+			events = append(events, st.event(mount, "filling", r, now))
+		case calm && st.firing[mount]:
+			st.firing[mount] = false
+			events = append(events, st.event(mount, "resolved", r, now))
+		}
+	}
+
+	for mount := range st.samples {
+		if _, ok := stats[mount]; !ok {
+			delete(st.samples, mount)
+			delete(st.growth, mount)
+			delete(st.firing, mount)
+		}
+	}
+	return events
+}
+
+func (st *spaceTrend) event(mount, state string, r SpaceStatFS, now time.Time) *SpaceForecast {
+	return &SpaceForecast{
+		Time:               now.UTC(),
+		Mount:              mount,
+		State:              state,
+		Free:               r.Free,
+		Total:              r.Total,
+		GrowthBytesPerHour: r.GrowthBytesPerHour,
+		TimeToFull:         r.TimeToFull,
+		Horizon:            int64(st.horizon.Seconds()),
+	}
+}
+
+// spaceSlope is the least squares slope in bytes per second, zero until a minute of samples
+func spaceSlope(samples []spaceSample) float64 {
+	if len(samples) < 6 || samples[len(samples)-1].t.Sub(samples[0].t) < time.Minute {
+		return 0
+	}
+	var sx, sy float64
+	for _, s := range samples {
+		sx += s.t.Sub(samples[0].t).Seconds()
+		sy += s.used
+	}
+	n := float64(len(samples))
+	mx, my := sx/n, sy/n
+	var sxy, sxx float64
+	for _, s := range samples {
+		dx := s.t.Sub(samples[0].t).Seconds() - mx
+		sxy += dx * (s.used - my)
+		sxx += dx * dx
+	}
+	if sxx == 0 {
+		return 0
+	}
+	return sxy / sxx
+}
@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseMountinfo(t *testing.T) {
+	t.Parallel()
+
+	data := `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+24 22 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+25 22 0:5 / /dev rw,nosuid,relatime shared:2 - devtmpfs udev rw,size=8132136k,nr_inodes=2033034,mode=755
+26 22 0:25 / /run rw,nosuid,nodev,noexec,relatime shared:5 - tmpfs tmpfs rw,size=1631360k,mode=755
+30 22 253:1 / /var/log rw,relatime shared:20 - xfs /dev/mapper/vg0-log rw,attr2,inode64,noquota
+31 22 8:17 / /mnt/backup\040disk ro,relatime shared:21 - ext4 /dev/sdb1 rw
+32 22 8:2 /srv/www /var/www rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+33 22 0:45 / /tank rw,noatime shared:30 - zfs tank rw,xattr,noacl
+34 33 0:46 / /tank/data rw,noatime shared:31 - zfs tank/data rw,xattr,noacl
+35 22 0:50 / /var/lib/docker/overlay2/abc/merged rw,relatime - overlay overlay rw,lowerdir=/a,upperdir=/b,workdir=/c
+36 22 253:1 / /srv/log-bind rw,relatime shared:20 - xfs /dev/mapper/vg0-log rw,attr2,inode64,noquota
+37 22 0:60 / /mnt/nas rw,relatime shared:40 - nfs4 nas:/export rw,vers=4.2
+`
+	mounts := parseMountinfo(data)
+	if len(mounts) != 13 {
+		t.Fatalf("mounts: %d", len(mounts))
+	}
+	if m := mounts[6]; m.MountPoint != "/mnt/backup disk" || m.FsType != "ext4" || m.Source != "/dev/sdb1" || !mountReadOnly(m) {
+		t.Fatalf("backup: %+v", m)
+	}
+	if mountReadOnly(mounts[0]) {
+		t.Fatal("root is read-only")
+	}
+
+	kept := filterMounts(mounts, nil)
+	want := []string{"/", "/var/log", "/mnt/backup disk", "/tank", "/tank/data", "/mnt/nas"}
+	if len(kept) != len(want) {
+		t.Fatalf("kept: %+v", kept)
+	}
+	for i, m := range kept {
+		if m.MountPoint != want[i] {
+			t.Fatalf("kept %d: %s, want %s", i, m.MountPoint, want[i])
+		}
+	}
+
+	kept = filterMounts(mounts, map[string]bool{"/tank/data": true})
+	if len(kept) != 2 || kept[0].MountPoint != "/" || kept[1].MountPoint != "/tank/data" {
+		t.Fatalf("allowed: %+v", kept)
+	}
+}
+
+func TestSpaceTrend(t *testing.T) {
+	t.Parallel()
+
+	st := &spaceTrend{
+		window:  time.Hour,
+		every:   10 * time.Second,
+		horizon: 24 * time.Hour,
+		samples: map[string][]spaceSample{},
+		growth:  map[string]float64{},
+		firing:  map[string]bool{},
+	}
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	const gb = 1 << 30
+
+	// steady 1 GiB per hour, 100 GiB free: 100h to full, above the horizon
+	var events []*SpaceForecast
+	var stats map[string]SpaceStatFS
+	for i := 0; i <= 60; i++ {
+		used := uint64(i) * gb / 360
+		stats = map[string]SpaceStatFS{"/var/log": {Total: 200 * gb, Free: 100*gb - used}}
+		events = append(events, st.apply(stats, start.Add(time.Duration(i)*10*time.Second))...)
+	}
+	r := stats["/var/log"]
+	if len(events) != 0 || r.GrowthBytesPerHour < 0.99*gb || r.GrowthBytesPerHour > 1.01*gb {
+		t.Fatalf("steady: %v per hour, events %v", r.GrowthBytesPerHour/gb, events)
+	}
+	if h := r.TimeToFull / 3600; h < 98 || h > 100 {
+		t.Fatalf("steady: %d h to full", h)
+	}
+
+	// a burst of 10 GiB per minute brings it below the horizon once
+	now := start.Add(10 * time.Minute)
+	free := r.Free
+	for i := 1; i <= 30; i++ {
+		free -= 10 * gb / 6
+		stats = map[string]SpaceStatFS{"/var/log": {Total: 200 * gb, Free: free}}
+		events = append(events, st.apply(stats, now.Add(time.Duration(i)*10*time.Second))...)
+	}
+	if len(events) != 1 || events[0].State != "filling" || events[0].Mount != "/var/log" || events[0].Horizon != 86400 {
+		t.Fatalf("burst events: %+v", events)
+	}
+
+	// space freed and the mount vanished: nothing is kept
+	if ev := st.apply(map[string]SpaceStatFS{}, now.Add(time.Hour)); len(ev) != 0 || len(st.samples) != 0 || len(st.firing) != 0 {
+		t.Fatalf("vanished: %v %v", ev, st.samples)
+	}
+}
+
+func TestSpaceTrendStatic(t *testing.T) {
+	t.Parallel()
+
+	st := &spaceTrend{
+		window:  time.Hour,
+		every:   10 * time.Second,
+		horizon: 24 * time.Hour,
+		samples: map[string][]spaceSample{},
+		growth:  map[string]float64{},
+		firing:  map[string]bool{},
+	}
+	start := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	const tb = 1 << 40
+
+	// 10 TiB free, a few bytes of noise per sample fit to a tiny positive growth
+	var events []*SpaceForecast
+	var stats map[string]SpaceStatFS
+	for i := 0; i <= 60; i++ {
+		stats = map[string]SpaceStatFS{"/srv": {Total: 20 * tb, Free: 10*tb - uint64(i%3)}}
+		events = append(events, st.apply(stats, start.Add(time.Duration(i)*10*time.Second))...)
+	}
+	r := stats["/srv"]
+	if r.GrowthBytesPerHour <= 0 || r.GrowthBytesPerHour > 1000 {
+		t.Fatalf("static: growth %v per hour", r.GrowthBytesPerHour)
+	}
+	if len(events) != 0 || r.TimeToFull != int64(spaceMaxTimeToFull) {
+		t.Fatalf("static: %d s to full, events %+v", r.TimeToFull, events)
+	}
+}
+
+func TestSpaceSlope(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	var samples []spaceSample
+	for i := 0; i < 5; i++ {
+		samples = append(samples, spaceSample{t: start.Add(time.Duration(i) * time.Minute), used: float64(i * 600)})
+	}
+	if s := spaceSlope(samples); s != 0 {
+		t.Fatalf("5 samples: %v", s)
+	}
+	samples = append(samples, spaceSample{t: start.Add(5 * time.Minute), used: 3000})
+	if s := spaceSlope(samples); s < 9.999 || s > 10.001 {
+		t.Fatalf("slope: %v, want 10 bytes/s", s)
+	}
+}
+
+func TestStatfsGuard(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	g := &statfsGuard{
+		timeout:  20 * time.Millisecond,
+		inflight: map[string]bool{},
+		statfs: func(path string) (SpaceStatFS, bool) {
+			calls.Add(1)
+			if path == "/mnt/nfs" {
+				<-release
+			}
+			return SpaceStatFS{Total: 100}, true
+		},
+	}
+
+	if _, ok := g.stat("/mnt/nfs"); ok {
+		t.Fatal("hung statfs returned")
+	}
+	// the previous call still hangs, no second goroutine piles up
+	start := time.Now()
+	if _, ok := g.stat("/mnt/nfs"); ok || time.Since(start) > 10*time.Millisecond || calls.Load() != 1 {
+		t.Fatalf("hung path not skipped, calls %d", calls.Load())
+	}
+	if r, ok := g.stat("/"); !ok || r.Total != 100 {
+		t.Fatalf("healthy path = %+v %v", r, ok)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(time.Second); ; {
+		if r, ok := g.stat("/mnt/nfs"); ok && r.Total == 100 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("path not retried after the server came back")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"bufio"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type SubprocessState struct {
+	Time     time.Time `json:"time"`
+	Name     string    `json:"name"`  // nvidia-smi, dbus-monitor
+	State    string    `json:"state"` // running, exited, stalled
+	Restarts int       `json:"restarts"`
+	Error    string    `json:"error,omitempty"`   // tail of stderr or exit status
+	RetryIn  int64     `json:"retryIn,omitempty"` // seconds until the next start
+}
+
+// supervisor keeps a line oriented subprocess alive: restarts it with backoff,
+// kills it when no frame completes within stall and reports state changes
+type supervisor struct {
+	name       string
+	args       []string
+	prefix     string        // log prefix
+	stall      time.Duration // 0 never stalls, dbus-monitor is silent without logins
+	backoffMin time.Duration
+	backoffMax time.Duration
+
+	line  func(line string) bool // true when the line completes a frame
+	down  func()                 // process gone, drop what it reported
+	state func(*SubprocessState)
+
+	last     string
+	restarts int
+}
+
+// run never returns unless the binary is missing on this host
+func (s *supervisor) run() {
+	if _, err := exec.LookPath(s.args[0]); err != nil {
+		return
+	}
+	backoff := s.backoffMin
+	for {
+		started := time.Now()
+		healthy, state, errMsg := s.once()
+		if s.down != nil {
+			s.down()
+		}
+		// a long healthy run was not a crash loop, come back fast
+		if healthy && time.Since(started) > s.backoffMax {
+			backoff = s.backoffMin
+		}
+		log.Println(s.prefix, s.name, state+", restarting in", backoff, "err:", errMsg)
+		s.report(state, errMsg, backoff)
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, s.backoffMax)
+		s.restarts++
+	}
+}
+
+// once runs the process until it exits or stalls, healthy when at least one frame completed
+func (s *supervisor) once() (healthy bool, state, errMsg string) {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	stderr := &tailWriter{max: 512}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, "exited", err.Error()
+	}
+	if err := cmd.Start(); err != nil {
+		return false, "exited", err.Error()
+	}
+
+	var lastFrame atomic.Int64
+	var stalled atomic.Bool
+	lastFrame.Store(time.Now().UnixNano())
+	done := make(chan struct{})
+	if s.stall > 0 {
+		go func() {
+			t := time.NewTicker(max(s.stall/4, 10*time.Millisecond))
+			defer t.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-t.C:
+					if time.Since(time.Unix(0, lastFrame.Load())) > s.stall {
+						stalled.Store(true)
+						_ = cmd.Process.Kill()
+						return
+					}
+				}
+			}
+		}()
+	} else {
+		healthy = true
+		s.report("running", "", 0)
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if s.line(strings.TrimRight(line, "\r\n")) {
+			lastFrame.Store(time.Now().UnixNano())
+			if !healthy {
+				healthy = true
+				s.report("running", "", 0)
+			}
+		}
+	}
+	close(done)
+
+	werr := cmd.Wait()
+	state = "exited"
+	if stalled.Load() {
+		state = "stalled"
+	}
+	errMsg = stderr.String()
+	if errMsg == "" && werr != nil {
+		errMsg = werr.Error()
+	}
+	return healthy, state, errMsg
+}
+
+// report passes state changes only, a process failing to come up repeatedly stays exited
+func (s *supervisor) report(state, errMsg string, retry time.Duration) {
+	if state == s.last {
+		return
+	}
+	s.last = state
+	if s.state == nil {
+		return
+	}
+	s.state(&SubprocessState{
+		Time:     time.Now().UTC(),
+		Name:     s.name,
+		State:    state,
+		Restarts: s.restarts,
+		Error:    errMsg,
+		RetryIn:  int64(retry.Seconds()),
+	})
+}
+
+// tailWriter keeps the last max bytes of stderr
+type tailWriter struct {
+	mu  sync.Mutex
+	max int
+	b   []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.b = append(w.b, p...)
+	if len(w.b) > w.max {
+		w.b = w.b[len(w.b)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(string(w.b))
+}
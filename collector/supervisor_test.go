@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervisorOnce(t *testing.T) {
+	t.Parallel()
+
+	var states []string
+	sv := &supervisor{
+		name:  "sh",
+		args:  []string{"sh", "-c", "echo part; echo frame; echo boom >&2; exit 3"},
+		stall: time.Second,
+		line:  func(line string) bool { return line == "frame" },
+		state: func(st *SubprocessState) { states = append(states, st.State) },
+	}
+	healthy, state, errMsg := sv.once()
+	if !healthy || state != "exited" || errMsg != "boom" {
+		t.Fatalf("healthy %v state %q err %q", healthy, state, errMsg)
+	}
+	if len(states) != 1 || states[0] != "running" {
+		t.Fatal("states:", states)
+	}
+
+	// same state twice is reported once
+	sv.report("exited", errMsg, time.Second)
+	sv.report("exited", errMsg, 2*time.Second)
+	if len(states) != 2 || states[1] != "exited" {
+		t.Fatal("states:", states)
+	}
+}
+
+func TestSupervisorStall(t *testing.T) {
+	t.Parallel()
+
+	sv := &supervisor{
+		name:  "sh",
+		args:  []string{"sh", "-c", "echo frame; exec sleep 10"},
+		stall: 200 * time.Millisecond,
+		line:  func(line string) bool { return line == "frame" },
+	}
+	start := time.Now()
+	healthy, state, errMsg := sv.once()
+	if !healthy || state != "stalled" {
+		t.Fatalf("healthy %v state %q err %q", healthy, state, errMsg)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("stalled process was not killed in time")
+	}
+	if !strings.Contains(errMsg, "killed") {
+		t.Fatal("expected kill status, got:", errMsg)
+	}
+}
@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-const pathHwm = "/sys/class/hwmon"
+const (
+	pathHwm     = "/sys/class/hwmon"
+	pathThermal = "/sys/class/thermal"
+)
 
 type TempStats struct {
 	Label    string  `json:"label,omitempty"`
@@ -19,117 +24,288 @@ type TempStats struct {
 	TempCrit float64 `json:"crit,omitempty"`
 }
 
-type prepareHWM struct {
-	TempPath string
-	Label    string
-	TempMax  float64
-	TempCrit float64
+type Sensor struct {
+	Source string  `json:"source"` // hwmon, nvme, thermal, ipmi
+	Device string  `json:"device,omitempty"`
+	Label  string  `json:"label"`
+	Kind   string  `json:"kind"` // temp, fan, voltage, power, current
+	Unit   string  `json:"unit"` // C, RPM, V, W, A
+	Value  float64 `json:"value"`
+	Min    float64 `json:"min,omitempty"`
+	Max    float64 `json:"max,omitempty"`
+	Crit   float64 `json:"crit,omitempty"`
+	Alarm  bool    `json:"alarm"`
+	Fault  bool    `json:"fault,omitempty"`
+}
+
+// hwmon attribute prefixes, their unit and scale of the sysfs integer
+var hwmKinds = []struct {
+	prefix, kind, unit string
+	scale              float64
+}{
+	{"temp", "temp", "C", 1000},
+	{"fan", "fan", "RPM", 1},
+	{"in", "voltage", "V", 1000},
+	{"power", "power", "W", 1000000},
+	{"curr", "current", "A", 1000},
+}
+
+// prepareSensor is a sensor found on rescan, limits are read once, value and alarms every second
+type prepareSensor struct {
+	Sensor
+	path   string
+	scale  float64
+	alarms []string
+	fault  string
 }
 
 func (c *Collector) collectTemperature() {
-	list, err := os.ReadDir(pathHwm)
-	if err != nil || len(list) == 0 {
-		return
+	go c.collectIpmi()
+
+	var prepared []prepareSensor
+	tick := 0
+	for range time.Tick(time.Second) {
+		// drivers load late and nvme drives come and go
+		if tick%60 == 0 {
+			prepared = append(scanHwmon(pathHwm), scanThermal(pathThermal)...)
+		}
+		tick++
+
+		sensors := make([]Sensor, 0, len(prepared))
+		temps := []TempStats{}
+		for _, p := range prepared {
+			s, ok := p.read()
+			if !ok {
+				continue
+			}
+			sensors = append(sensors, s)
+			if s.Kind == "temp" && s.Source != "thermal" {
+				temps = append(temps, TempStats{Label: s.Label, Temp: s.Value, TempMax: s.Max, TempCrit: s.Crit})
+			}
+		}
+
+		c.mu.Lock()
+		c.data.Sensors = append(sensors, c.ipmiSensors...)
+		c.data.TempStats = temps
+		c.mu.Unlock()
 	}
+}
+
+func (p *prepareSensor) read() (Sensor, bool) {
+	v, ok := readSysScaled(p.path, p.scale)
+	if !ok {
+		return Sensor{}, false
+	}
+	s := p.Sensor
+	s.Value = v
+	for _, a := range p.alarms {
+		if readSysString(a) == "1" {
+			s.Alarm = true
+		}
+	}
+	if p.fault != "" && readSysString(p.fault) == "1" {
+		s.Fault = true
+	}
+	if s.Kind == "temp" && s.Crit > 0 && s.Value >= s.Crit {
+		s.Alarm = true
+	}
+	return s, true
+}
 
-	var HWMs []prepareHWM
+// scanHwmon finds temp, fan, in, power and curr inputs of every chip
+func scanHwmon(root string) []prepareSensor {
+	list, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var res []prepareSensor
 	for _, entry := range list {
 		id := strings.TrimPrefix(entry.Name(), "hwmon")
 		if id == "" {
 			log.Println("[collector] got zero hwm id")
 			continue
 		}
+		hwDir := root + "/" + entry.Name()
+		chip := strings.TrimSpace(strings.ToLower(readSysString(hwDir + "/name")))
 
-		hwDir := pathHwm + "/" + entry.Name()
-
-		files, err := filepath.Glob(hwDir + "/temp*_input")
-		if err != nil || len(files) == 0 {
-			continue
+		source, device := "hwmon", ""
+		if real, err := filepath.EvalSymlinks(hwDir + "/device"); err == nil {
+			device = filepath.Base(real)
+		}
+		if chip == "nvme" {
+			source = "nvme"
 		}
 
-		for _, file := range files {
-			n := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "temp"), "_input")
-			if n == "" {
-				continue
-			}
-			hwm := prepareHWM{
-				TempPath: file,
-			}
-			tLabel, _ := os.ReadFile(hwDir + "/temp" + n + "_label")
-			tMax, _ := os.ReadFile(hwDir + "/temp" + n + "_max")
-			if len(tMax) > 0 {
-				tt := strings.TrimSpace(string(tMax))
-				if len(tt) > 3 {
-					tt = tt[:3]
-				}
-				f, err := strconv.ParseFloat(tt[:len(tt)-1]+"."+tt[len(tt)-1:], 64)
-				if err == nil {
-					hwm.TempMax = f
+		for _, k := range hwmKinds {
+			files, _ := filepath.Glob(hwDir + "/" + k.prefix + "*_input")
+			// power meters without _input report the average
+			if k.prefix == "power" {
+				avg, _ := filepath.Glob(hwDir + "/power*_average")
+				for _, a := range avg {
+					if !fileExists(strings.TrimSuffix(a, "_average") + "_input") {
+						files = append(files, a)
+					}
 				}
 			}
-			tCrit, _ := os.ReadFile(hwDir + "/temp" + n + "_crit")
-			if len(tCrit) > 0 {
-				tt := strings.TrimSpace(string(tCrit))
-				if len(tt) > 3 {
-					tt = tt[:3]
+			sort.Strings(files)
+			for _, file := range files {
+				base := filepath.Base(file)
+				n := strings.TrimPrefix(base[:strings.LastIndex(base, "_")], k.prefix)
+				if _, err := strconv.Atoi(n); err != nil {
+					continue
 				}
-				f, err := strconv.ParseFloat(tt[:len(tt)-1]+"."+tt[len(tt)-1:], 64)
-				if err == nil {
-					hwm.TempCrit = f
+				attr := hwDir + "/" + k.prefix + n
+				p := prepareSensor{
+					Sensor: Sensor{
+						Source: source,
+						Device: device,
+						Label:  hwmLabel(id, chip, readSysString(attr+"_label")),
+						Kind:   k.kind,
+						Unit:   k.unit,
+					},
+					path:  file,
+					scale: k.scale,
+					fault: attr + "_fault",
 				}
-			}
-
-			label := bytes.Buffer{}
-			label.WriteString("hwm")
-			label.WriteString(id)
-			label.WriteString(" ")
-			name, _ := os.ReadFile(hwDir + "/name")
-			if len(name) != 0 {
-				nm := strings.TrimSuffix(strings.TrimSpace(strings.ToLower(string(name))), "temp")
-				if nm == "" {
-					nm = strings.TrimSpace(strings.ToLower(string(name)))
+				p.Min, _ = readSysScaled(attr+"_min", k.scale)
+				p.Max, _ = readSysScaled(attr+"_max", k.scale)
+				p.Crit, _ = readSysScaled(attr+"_crit", k.scale)
+				for _, a := range []string{"_alarm", "_crit_alarm", "_max_alarm", "_min_alarm", "_lcrit_alarm"} {
+					if fileExists(attr + a) {
+						p.alarms = append(p.alarms, attr+a)
+					}
 				}
-				label.WriteString(nm)
-				label.WriteString(" ")
+				res = append(res, p)
 			}
-			if len(tLabel) > 0 {
-				label.WriteString(strings.TrimSpace(strings.ToLower(string(tLabel))))
-			}
-			hwm.Label = strings.TrimSpace(label.String())
-
-			HWMs = append(HWMs, hwm)
 		}
 	}
+	return res
+}
 
-	if len(HWMs) > 0 {
-		c.tempStatHandler(HWMs)
+// hwmLabel keeps the tempStats label format: "hwm<id> <chip> <label>"
+func hwmLabel(id, chip, label string) string {
+	b := bytes.Buffer{}
+	b.WriteString("hwm")
+	b.WriteString(id)
+	b.WriteString(" ")
+	if chip != "" {
+		nm := strings.TrimSuffix(chip, "temp")
+		if nm == "" {
+			nm = chip
+		}
+		b.WriteString(nm)
+		b.WriteString(" ")
 	}
+	b.WriteString(strings.TrimSpace(strings.ToLower(label)))
+	return strings.TrimSpace(b.String())
 }
 
-func (c *Collector) tempStatHandler(HWMs []prepareHWM) {
-	for range time.Tick(time.Second) {
-		stats := make([]TempStats, 0, len(HWMs))
-		for _, hwm := range HWMs {
-			tInp, err := os.ReadFile(hwm.TempPath)
-			if err != nil {
+// scanThermal reads acpi and soc thermal zones, hot and critical trip points become max and crit
+func scanThermal(root string) []prepareSensor {
+	zones, _ := filepath.Glob(root + "/thermal_zone*")
+	sort.Strings(zones)
+	var res []prepareSensor
+	for _, z := range zones {
+		p := prepareSensor{
+			Sensor: Sensor{
+				Source: "thermal",
+				Device: filepath.Base(z),
+				Label:  readSysString(z + "/type"),
+				Kind:   "temp",
+				Unit:   "C",
+			},
+			path:  z + "/temp",
+			scale: 1000,
+		}
+		trips, _ := filepath.Glob(z + "/trip_point_*_type")
+		for _, t := range trips {
+			v, ok := readSysScaled(strings.TrimSuffix(t, "_type")+"_temp", 1000)
+			if !ok || v <= 0 {
 				continue
 			}
-			tt := strings.TrimSpace(string(tInp))
-			if len(tt) > 3 {
-				tt = tt[:3]
-			}
-			temp, err := strconv.ParseFloat(tt[:len(tt)-1]+"."+tt[len(tt)-1:], 64)
-			if err == nil {
-				stats = append(stats, TempStats{
-					Label:    hwm.Label,
-					Temp:     temp,
-					TempMax:  hwm.TempMax,
-					TempCrit: hwm.TempCrit,
-				})
+			switch readSysString(t) {
+			case "critical":
+				p.Crit = v
+			case "hot":
+				p.Max = v
 			}
 		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// collectIpmi polls the bmc, IPMI_SDR_CMD may point to a local stand-in printing the same csv
+func (c *Collector) collectIpmi() {
+	cmd := os.Getenv("IPMI_SDR_CMD")
+	if cmd == "" {
+		if _, err := os.Stat("/dev/ipmi0"); err != nil {
+			return
+		}
+		if _, err := exec.LookPath("ipmitool"); err != nil {
+			log.Println("[collector] /dev/ipmi0 found but no ipmitool, ipmi sensors off")
+			return
+		}
+		cmd = "ipmitool -c sdr list full"
+	}
+	for {
+		out, err := exec.Command("sh", "-c", cmd).Output()
+		if err != nil {
+			log.Println("[collector] ipmi sdr err:", err)
+		}
+		sensors := parseIpmiSdr(string(out))
 		c.mu.Lock()
-		c.data.TempStats = stats
+		c.ipmiSensors = sensors
 		c.mu.Unlock()
+		time.Sleep(time.Minute)
+	}
+}
+
+var ipmiUnits = map[string]struct{ kind, unit string }{
+	"degrees C": {"temp", "C"},
+	"RPM":       {"fan", "RPM"},
+	"Volts":     {"voltage", "V"},
+	"Watts":     {"power", "W"},
+	"Amps":      {"current", "A"},
+}
+
+// parseIpmiSdr reads ipmitool -c sdr: "CPU1 Temp,45,degrees C,ok", status nc, cr and nr raise the alarm
+func parseIpmiSdr(data string) []Sensor {
+	var res []Sensor
+	for _, line := range strings.Split(data, "\n") {
+		f := strings.Split(strings.TrimSpace(line), ",")
+		if len(f) < 4 {
+			continue
+		}
+		u, ok := ipmiUnits[strings.TrimSpace(f[2])]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(f[1]), 64)
+		if err != nil {
+			continue
+		}
+		status := strings.TrimSpace(f[3])
+		if status == "ns" {
+			continue
+		}
+		res = append(res, Sensor{
+			Source: "ipmi",
+			Label:  strings.TrimSpace(f[0]),
+			Kind:   u.kind,
+			Unit:   u.unit,
+			Value:  v,
+			Alarm:  status == "nc" || status == "cr" || status == "nr",
+		})
+	}
+	return res
+}
+
+// readSysScaled parses a sysfs integer like millidegrees, negative readings included
+func readSysScaled(path string, scale float64) (float64, bool) {
+	v, err := strconv.ParseInt(readSysString(path), 10, 64)
+	if err != nil {
+		return 0, false
 	}
+	return float64(v) / scale, true
 }
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSysTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanHwmon(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeSysTree(t, root, map[string]string{
+		"hwmon0/name":           "coretemp",
+		"hwmon0/temp1_input":    "105000",
+		"hwmon0/temp1_label":    "Package id 0",
+		"hwmon0/temp1_max":      "95000",
+		"hwmon0/temp1_crit":     "100000",
+		"hwmon0/temp2_input":    "-5500",
+		"hwmon0/fan1_input":     "1200",
+		"hwmon0/fan1_min":       "1500",
+		"hwmon0/fan1_alarm":     "1",
+		"hwmon0/in0_input":      "12100",
+		"hwmon0/power1_average": "45500000",
+		"hwmon0/power2_input":   "30000000",
+		"hwmon0/power2_average": "29000000",
+		"hwmon1/name":           "nvme",
+		"hwmon1/temp1_input":    "38850",
+		"hwmon1/temp1_label":    "Composite",
+	})
+
+	got := map[string]Sensor{}
+	var power []Sensor
+	for _, p := range scanHwmon(root) {
+		s, ok := p.read()
+		if !ok {
+			t.Fatalf("read %s failed", p.path)
+		}
+		// unlabeled power channels share the label
+		if s.Kind == "power" {
+			power = append(power, s)
+			continue
+		}
+		got[s.Kind+" "+s.Label] = s
+	}
+	if len(got) != 5 {
+		t.Fatalf("sensors = %v", got)
+	}
+
+	pkg := got["temp hwm0 core package id 0"]
+	if pkg.Value != 105 || pkg.Max != 95 || pkg.Crit != 100 || !pkg.Alarm || pkg.Unit != "C" {
+		t.Fatalf("package = %+v", pkg)
+	}
+	if neg := got["temp hwm0 core"]; neg.Value != -5.5 || neg.Alarm {
+		t.Fatalf("negative = %+v", neg)
+	}
+	if fan := got["fan hwm0 core"]; fan.Value != 1200 || fan.Min != 1500 || !fan.Alarm || fan.Unit != "RPM" {
+		t.Fatalf("fan = %+v", fan)
+	}
+	if in := got["voltage hwm0 core"]; in.Value != 12.1 || in.Unit != "V" {
+		t.Fatalf("voltage = %+v", in)
+	}
+	// power2 has both _input and _average, only the input counts
+	if len(power) != 2 {
+		t.Fatalf("power = %+v", power)
+	}
+	for _, pw := range power {
+		if (pw.Value != 45.5 && pw.Value != 30) || pw.Unit != "W" {
+			t.Fatalf("power = %+v", pw)
+		}
+	}
+	if nv := got["temp hwm1 nvme composite"]; nv.Source != "nvme" || nv.Value != 38.85 {
+		t.Fatalf("nvme = %+v", nv)
+	}
+}
+
+func TestScanThermal(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeSysTree(t, root, map[string]string{
+		"thermal_zone0/type":              "x86_pkg_temp",
+		"thermal_zone0/temp":              "112000",
+		"thermal_zone0/trip_point_0_type": "hot",
+		"thermal_zone0/trip_point_0_temp": "100000",
+		"thermal_zone0/trip_point_1_type": "critical",
+		"thermal_zone0/trip_point_1_temp": "110000",
+		"thermal_zone1/type":              "acpitz",
+		"thermal_zone1/temp":              "27800",
+	})
+
+	prepared := scanThermal(root)
+	if len(prepared) != 2 {
+		t.Fatalf("zones = %d", len(prepared))
+	}
+	pkg, _ := prepared[0].read()
+	if pkg.Label != "x86_pkg_temp" || pkg.Value != 112 || pkg.Max != 100 || pkg.Crit != 110 || !pkg.Alarm {
+		t.Fatalf("zone0 = %+v", pkg)
+	}
+	acpi, _ := prepared[1].read()
+	if acpi.Device != "thermal_zone1" || acpi.Value != 27.8 || acpi.Alarm {
+		t.Fatalf("zone1 = %+v", acpi)
+	}
+}
+
+func TestParseIpmiSdr(t *testing.T) {
+	t.Parallel()
+
+	out := `Inlet Temp,24,degrees C,ok
+CPU1 Temp,91,degrees C,cr
+FAN1,5040,RPM,ok
+FAN2,0,RPM,ns
+12V,12.10,Volts,nc
+Pwr Consumption,168,Watts,ok
+Intrusion,0x00,discrete,ok
+`
+	sensors := parseIpmiSdr(out)
+	if len(sensors) != 5 {
+		t.Fatalf("sensors = %+v", sensors)
+	}
+	if s := sensors[1]; s.Label != "CPU1 Temp" || s.Kind != "temp" || s.Value != 91 || !s.Alarm {
+		t.Fatalf("cpu = %+v", s)
+	}
+	if s := sensors[3]; s.Kind != "voltage" || s.Value != 12.1 || !s.Alarm {
+		t.Fatalf("12v = %+v", s)
+	}
+	if s := sensors[4]; s.Source != "ipmi" || s.Kind != "power" || s.Alarm {
+		t.Fatalf("power = %+v", s)
+	}
+}
@@ -18,46 +18,27 @@ func (c *Collector) collectWho() {
 	c.whoSessionMu = sync.Mutex{}
 	c.whoSessions = make(map[string]*WhoLogged)
 
-	for {
-		cmd := exec.Command("dbus-monitor", "--system", "type='signal',sender='org.freedesktop.login1'")
-		stderr, _ := cmd.StderrPipe()
-		go func() {
-			buf := make([]byte, 1024)
-			n, _ := stderr.Read(buf)
-			if n > 0 {
-				log.Printf("[who] dbus-monitor stderr: %s", string(buf[:n]))
-			}
-		}()
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			log.Println("[who] dbus-monitor stdout err:", err)
-			return
-		}
-		if err := cmd.Start(); err != nil {
-			log.Println("[who] failed to start dbus-monitor:", err)
-			return
-		}
-
-		log.Println("[who] dbus-monitor started")
-
-		scanner := bufio.NewScanner(stdout)
-
-		session := "" // new or removed
-		for scanner.Scan() {
-			line := scanner.Text()
+	session := "" // new or removed
+	sv := &supervisor{
+		name:       "dbus-monitor",
+		args:       []string{"dbus-monitor", "--system", "type='signal',sender='org.freedesktop.login1'"},
+		prefix:     "[who]",
+		backoffMin: 8 * time.Second,
+		backoffMax: 2 * time.Minute,
+		line: func(line string) bool {
 			l := strings.ToLower(line)
 			if strings.Contains(l, "member=sessionnew") {
 				session = "new"
-				continue
+				return false
 			}
 			if strings.Contains(l, "member=sessionremoved") {
 				session = "removed"
-				continue
+				return false
 			}
 
 			// skip if not allowed session
 			if session == "" {
-				continue
+				return false
 			}
 
 			// find first line with ID
@@ -68,20 +49,19 @@ func (c *Collector) collectWho() {
 					// flush after
 					session = ""
 				}
-				continue
 			}
-		}
-
-		// if scanner fell or dbus-monitor ended
-		if err := scanner.Err(); err != nil {
-			log.Println("[who] scanner err:", err, "trying to restarting dbus-monitor")
-		}
-		_ = cmd.Wait()
-
-		log.Println("[who] end")
-
-		time.Sleep(8 * time.Second)
+			return false
+		},
+		down: func() {
+			session = ""
+		},
+		state: func(st *SubprocessState) {
+			if st.State == "running" {
+				log.Println("[who] dbus-monitor started")
+			}
+		},
 	}
+	sv.run()
 }
 
 func (c *Collector) handleWhoEvent(session, sessionID string) {
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+type commandMessage struct {
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args"`
+}
+
+type commandReply struct {
+	Event    string `json:"event"`
+	ID       string `json:"id"`
+	Command  string `json:"command"`
+	Progress int    `json:"progress,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Result   any    `json:"result,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type commandHandler func(call *CommandCall, args json.RawMessage) (any, error)
+
+// Commands is a registry of handlers for commands coming over the live channel,
+// every call with an id is answered with ack, progress and result or error events
+type Commands struct {
+	mu       sync.RWMutex
+	handlers map[string]commandHandler
+	send     chan<- any
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// CommandCall is a single running command
+type CommandCall struct {
+	ID      string
+	Command string
+	send    chan<- any
+}
+
+func NewCommands(send chan<- any) *Commands {
+	return &Commands{
+		handlers: map[string]commandHandler{},
+		send:     send,
+		done:     make(chan struct{}),
+	}
+}
+
+// Close stops dispatching, calls after it are dropped, true only for the call which closed it
+func (cs *Commands) Close() (closed bool) {
+	cs.closeOnce.Do(func() {
+		close(cs.done)
+		closed = true
+	})
+	return closed
+}
+
+// Done is closed once the registry stops dispatching
+func (cs *Commands) Done() <-chan struct{} {
+	return cs.done
+}
+
+// Register adds a handler, the args of the incoming message are decoded into A
+func Register[A any](cs *Commands, name string, fn func(call *CommandCall, args A) (any, error)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.handlers[name] = func(call *CommandCall, raw json.RawMessage) (any, error) {
+		var args A
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, fmt.Errorf("decode args: %w", err)
+			}
+		}
+		return fn(call, args)
+	}
+}
+
+// Names returns the commands supported by this build
+func (cs *Commands) Names() []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	names := make([]string, 0, len(cs.handlers)+1)
+	for n := range cs.handlers {
+		names = append(names, n)
+	}
+	names = append(names, "capabilities")
+	sort.Strings(names)
+	return names
+}
+
+// Dispatch decodes a live message and runs its handler in background
+func (cs *Commands) Dispatch(p []byte) {
+	select {
+	case <-cs.done:
+		log.Println("[command] closed, live message dropped")
+		return
+	default:
+	}
+
+	var msg commandMessage
+	err := json.Unmarshal(p, &msg)
+	if err != nil {
+		log.Println("[command] decode live err:", err)
+		return
+	}
+	// legacy messages carry args flat next to command
+	if len(msg.Args) == 0 {
+		msg.Args = p
+	}
+
+	call := &CommandCall{
+		ID:      msg.ID,
+		Command: msg.Command,
+		send:    cs.send,
+	}
+
+	if msg.Command == "capabilities" {
+		cs.send <- struct {
+			Event    string   `json:"event"`
+			ID       string   `json:"id"`
+			Version  string   `json:"version"`
+			Commands []string `json:"commands"`
+		}{
+			Event:    "capabilities",
+			ID:       msg.ID,
+			Version:  os.Getenv("VERSION"),
+			Commands: cs.Names(),
+		}
+		return
+	}
+
+	cs.mu.RLock()
+	handler, ok := cs.handlers[msg.Command]
+	cs.mu.RUnlock()
+	if !ok {
+		log.Printf("[command] unknown command: %q id: %q", msg.Command, msg.ID)
+		call.reply(commandReply{Event: "command-error", Error: "unknown command"})
+		return
+	}
+
+	logger.Debugf("[command] run %q id: %q", msg.Command, msg.ID)
+	call.reply(commandReply{Event: "command-ack"})
+
+	go func() {
+		res, err := call.run(handler, msg.Args)
+		if err != nil {
+			log.Printf("[command] %q id: %q err: %s", msg.Command, msg.ID, err)
+			call.reply(commandReply{Event: "command-error", Error: err.Error()})
+			return
+		}
+		call.reply(commandReply{Event: "command-result", Result: res})
+	}()
+}
+
+func (call *CommandCall) run(handler commandHandler, args json.RawMessage) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint("panic: ", r))
+		}
+	}()
+	return handler(call, args)
+}
+
+// Progress reports how far the command is, percent in 0..100
+func (call *CommandCall) Progress(percent int, message string) {
+	call.reply(commandReply{Event: "command-progress", Progress: percent, Message: message})
+}
+
+func (call *CommandCall) reply(r commandReply) {
+	// calls without id come from older api, nobody waits for replies
+	if call.ID == "" {
+		return
+	}
+	r.ID = call.ID
+	r.Command = call.Command
+	call.send <- r
+}
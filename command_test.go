@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandsClose(t *testing.T) {
+	t.Parallel()
+
+	send := make(chan any, 16)
+	cs := NewCommands(send)
+	ran := make(chan struct{}, 4)
+	Register(cs, "ping", func(call *CommandCall, args struct{}) (any, error) {
+		ran <- struct{}{}
+		return "pong", nil
+	})
+
+	cs.Dispatch([]byte(`{"id":"1","command":"ping"}`))
+	for done := false; !done; {
+		select {
+		case m := <-send:
+			done = m.(commandReply).Event == "command-result"
+		case <-time.After(time.Second):
+			t.Fatal("ping not answered")
+		}
+	}
+	<-ran
+
+	if !cs.Close() {
+		t.Fatal("first close reported no-op")
+	}
+	if cs.Close() {
+		t.Fatal("second close reported closing")
+	}
+	cs.Dispatch([]byte(`{"id":"2","command":"ping"}`))
+	select {
+	case <-ran:
+		t.Fatal("dispatched after close")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(send) != 0 {
+		t.Fatalf("replied after close: %+v", <-send)
+	}
+}
+
+func TestCommandsDispatch(t *testing.T) {
+	t.Parallel()
+
+	send := make(chan any, 16)
+	cs := NewCommands(send)
+	legacy := make(chan string, 1)
+	Register(cs, "double", func(call *CommandCall, args struct {
+		N int `json:"n"`
+	}) (any, error) {
+		call.Progress(50, "half way")
+		return args.N * 2, nil
+	})
+	Register(cs, "fail", func(call *CommandCall, args struct{}) (any, error) {
+		return nil, errors.New("disk busy")
+	})
+	Register(cs, "crash", func(call *CommandCall, args struct{}) (any, error) {
+		panic("nil map")
+	})
+	Register(cs, "mount", func(call *CommandCall, args struct {
+		Path string `json:"path"`
+	}) (any, error) {
+		legacy <- args.Path
+		return nil, nil
+	})
+
+	tests := []struct {
+		name    string
+		msg     string
+		events  []string
+		check   func(last map[string]any) bool
+		handled string // path the legacy handler saw
+	}{
+		{"ack progress result", `{"id":"1","command":"double","args":{"n":21}}`,
+			[]string{"command-ack", "command-progress", "command-result"},
+			func(last map[string]any) bool { return last["result"] == float64(42) && last["command"] == "double" }, ""},
+		{"handler error", `{"id":"2","command":"fail"}`,
+			[]string{"command-ack", "command-error"},
+			func(last map[string]any) bool { return last["error"] == "disk busy" }, ""},
+		{"handler panic", `{"id":"3","command":"crash"}`,
+			[]string{"command-ack", "command-error"},
+			func(last map[string]any) bool { return last["error"] == "panic: nil map" }, ""},
+		{"unknown command", `{"id":"4","command":"format"}`,
+			[]string{"command-error"},
+			func(last map[string]any) bool { return last["error"] == "unknown command" }, ""},
+		{"args decode error", `{"id":"5","command":"double","args":{"n":"x"}}`,
+			[]string{"command-ack", "command-error"},
+			func(last map[string]any) bool { return strings.HasPrefix(last["error"].(string), "decode args:") }, ""},
+		{"capabilities", `{"id":"6","command":"capabilities"}`,
+			[]string{"capabilities"},
+			func(last map[string]any) bool {
+				return fmt.Sprint(last["commands"]) == "[capabilities crash double fail mount]"
+			}, ""},
+		{"legacy flat args", `{"command":"mount","path":"/mnt/backup"}`,
+			nil, nil, "/mnt/backup"},
+		{"legacy unknown", `{"command":"format"}`,
+			nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		cs.Dispatch([]byte(tt.msg))
+		var got []string
+		var last map[string]any
+		for len(got) < len(tt.events) {
+			select {
+			case m := <-send:
+				b, _ := json.Marshal(m)
+				last = map[string]any{}
+				_ = json.Unmarshal(b, &last)
+				got = append(got, last["event"].(string))
+			case <-time.After(time.Second):
+				t.Fatalf("%s: got %v, want %v", tt.name, got, tt.events)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.events) {
+			t.Fatalf("%s: got %v, want %v", tt.name, got, tt.events)
+		}
+		if tt.check != nil && !tt.check(last) {
+			t.Fatalf("%s: last reply %v", tt.name, last)
+		}
+		if tt.handled != "" {
+			select {
+			case path := <-legacy:
+				if path != tt.handled {
+					t.Fatalf("%s: handler got %q", tt.name, path)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: handler not run", tt.name)
+			}
+		}
+		// nothing more, calls without id get no replies at all
+		select {
+		case m := <-send:
+			t.Fatalf("%s: unexpected reply %+v", tt.name, m)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 	"net/http"
 	"net/http/httptrace"
 	"os"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -44,6 +44,12 @@ type Connection struct {
 	response  *ConnectResponse
 	chanSend  chan any
 	chanLive  chan []byte
+	policy    *ReconnectPolicy
+
+	spool     *Spool
+	spoolMu   sync.Mutex
+	spoolStop chan struct{}
+	spoolDone chan struct{}
 }
 
 func NewConnection(cp *ConnectPayload) *Connection {
@@ -68,6 +74,8 @@ func NewConnection(cp *ConnectPayload) *Connection {
 		payload:  cp,
 		chanSend: make(chan any, 16),
 		chanLive: make(chan []byte, 16),
+		policy:   NewReconnectPolicy(),
+		spool:    NewSpool(),
 	}
 	log.Println("[connect] started")
 	for {
@@ -157,25 +165,30 @@ func (c *Connection) connect() error {
 		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 	}
 
+	c.policy.Attempt()
+
 	res, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return netHandshakeError(err)
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(res.Body)
 
 	if res.StatusCode >= 500 {
-		return fmt.Errorf("response status: %s", res.Status)
+		return newHandshakeError(ErrKindServer, fmt.Errorf("response status: %s", res.Status))
 	}
 
 	c.response = new(ConnectResponse)
 	err = json.NewDecoder(res.Body).Decode(c.response)
 	if err != nil {
-		return fmt.Errorf("decode json: %w", err)
+		if res.StatusCode >= 400 {
+			return apiHandshakeError(res.StatusCode, res.Status)
+		}
+		return newHandshakeError(ErrKindProtocol, fmt.Errorf("decode json: %w", err))
 	}
 	if !c.response.Ok {
-		return errors.New("api message: " + c.response.Message)
+		return apiHandshakeError(res.StatusCode, c.response.Message)
 	}
 
 	logger.Debugf("[connect] try connect to ws... endpoint ip: %q endpoint path: %q",
@@ -187,7 +200,7 @@ func (c *Connection) connect() error {
 		// usual connect
 		c.ws, _, err = websocket.DefaultDialer.DialContext(ctx, c.response.EndpointPath, nil)
 		if err != nil {
-			return fmt.Errorf("dial native: %w", err)
+			return netHandshakeError(fmt.Errorf("dial native: %w", err))
 		}
 	} else {
 		// connect with replace ip
@@ -210,7 +223,7 @@ func (c *Connection) connect() error {
 
 		c.ws, _, err = dialer.DialContext(ctx, c.response.EndpointPath, nil)
 		if err != nil {
-			return fmt.Errorf("dial modified: %w", err)
+			return netHandshakeError(fmt.Errorf("dial modified: %w", err))
 		}
 	}
 
@@ -230,7 +243,7 @@ func (c *Connection) connect() error {
 	})
 	if err != nil {
 		_ = c.ws.Close()
-		return fmt.Errorf("handshake: %w", err)
+		return newHandshakeError(ErrKindNetwork, fmt.Errorf("handshake: %w", err))
 	}
 
 	logger.Debugf("[connect] read handshake...")
@@ -239,16 +252,37 @@ func (c *Connection) connect() error {
 		_, _, err = c.ws.ReadMessage()
 		if err != nil {
 			_ = c.ws.Close()
-			return fmt.Errorf("handshake: %w", err)
+			return newHandshakeError(ErrKindNetwork, fmt.Errorf("handshake: %w", err))
 		}
 		log.Println("[connect] handshake successful")
 		break
 	}
 
+	// events buffered while offline go first, in order
+	c.stopSpooling()
+	err = c.spool.Replay(c.writeRaw)
+	if err != nil {
+		c.startSpooling()
+		_ = c.ws.Close()
+		return fmt.Errorf("replay spool: %w", err)
+	}
+
 	ctx, cancel = context.WithCancel(context.Background())
 	go c.writer(ctx)
 	go c.reader(cancel)
 
+	if st := c.policy.Success(); st.Attempts > 0 {
+		log.Printf("[connect] reconnected after %d attempts, down since %s, last err: %s",
+			st.Attempts, st.Since.Format(time.RFC3339), st.LastError)
+		c.chanSend <- struct {
+			Event     string         `json:"event"`
+			Reconnect ReconnectState `json:"reconnect"`
+		}{
+			Event:     "reconnected",
+			Reconnect: st,
+		}
+	}
+
 	return nil
 }
 
@@ -295,6 +329,7 @@ func (c *Connection) writer(ctx context.Context) {
 
 			wErr := c.ws.WriteJSON(message)
 			if wErr != nil {
+				c.spool.Push(message)
 				go c.degrade(fmt.Errorf("write pump err: %w", wErr), true)
 				return
 			}
@@ -323,6 +358,49 @@ func (c *Connection) writer(ctx context.Context) {
 	}
 }
 
+func (c *Connection) writeRaw(data []byte) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// startSpooling drains chanSend into the spool until the next successful handshake
+func (c *Connection) startSpooling() {
+	c.spoolMu.Lock()
+	defer c.spoolMu.Unlock()
+	if c.spoolStop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.spoolStop, c.spoolDone = stop, done
+	logger.Debug("[connect] spooling started")
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case message, ok := <-c.chanSend:
+				if !ok {
+					return
+				}
+				c.spool.Push(message)
+			}
+		}
+	}()
+}
+
+func (c *Connection) stopSpooling() {
+	c.spoolMu.Lock()
+	defer c.spoolMu.Unlock()
+	if c.spoolStop == nil {
+		return
+	}
+	close(c.spoolStop)
+	<-c.spoolDone
+	c.spoolStop, c.spoolDone = nil, nil
+	logger.Debug("[connect] spooling stopped")
+}
+
 func (c *Connection) close() {
 	c.destroy <- struct{}{}
 }
@@ -335,14 +413,11 @@ func (c *Connection) fatal(err error) {
 
 func (c *Connection) degrade(err error, reconnect bool) {
 	log.Println("[connect] failure, err:", err)
+	c.startSpooling()
 	if err != nil {
-		if strings.Contains(err.Error(), "number of nodes has been reached") {
-			log.Println("[connect] trying to reconnect after waiting 5 minutes")
-			time.Sleep(5 * time.Minute)
-		} else {
-			log.Println("[connect] trying to reconnect after waiting 3 seconds")
-			time.Sleep(3 * time.Second)
-		}
+		wait := c.policy.Failure(err)
+		log.Printf("[connect] trying to reconnect after waiting %s, %s", wait.Round(time.Millisecond), c.policy.State())
+		time.Sleep(wait)
 	}
 	if reconnect {
 		select {
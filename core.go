@@ -1,14 +1,16 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
+	"netip-core/alert"
 	tests "netip-core/benchmark"
 	"netip-core/collector"
 	"netip-core/info"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 type ConnectResponse struct {
@@ -35,27 +37,62 @@ func main() {
 	})
 
 	col := collector.New()
+	alerts := alert.New()
 	chGeneralTests := make(chan *tests.Result, 1)
 
-	// live from nodes-handler
-	go func() {
-		for p := range conn.chanLive {
-			var res struct {
-				Command string `json:"command"`
-				Runtime int    `json:"runtime"`
-			}
-			err := json.Unmarshal(p, &res)
-			if err != nil {
-				log.Println("[component] decode live err:", err)
-				continue
+	cmds := NewCommands(conn.chanSend)
+	Register(cmds, "general-tests", func(call *CommandCall, args struct {
+		Runtime int `json:"runtime"`
+	}) (any, error) {
+		ch := make(chan *tests.Result, 1)
+		tests.NewGeneralTests(false, args.Runtime, ch)
+		select {
+		case res := <-ch:
+			// older api sends no id and waits for the bms-general-tests event instead of a result
+			if call.ID == "" {
+				chGeneralTests <- res
+				return nil, nil
 			}
+			return res, nil
+		default:
+			return nil, errors.New("general tests not completed, locked by other test or timeout")
+		}
+	})
+	Register(cmds, "alert-rules", func(call *CommandCall, args struct {
+		Rules []alert.Rule `json:"rules"`
+	}) (any, error) {
+		if err := alerts.SetRules(args.Rules); err != nil {
+			return nil, err
+		}
+		if err := alerts.Save(args.Rules); err != nil {
+			log.Println("[component] save alert rules err:", err)
+		}
+		return struct {
+			Rules int `json:"rules"`
+		}{len(args.Rules)}, nil
+	})
+	Register(cmds, "smart-selftest", func(call *CommandCall, args struct {
+		Device string `json:"device"`
+		Type   string `json:"type"`
+	}) (any, error) {
+		return col.RunSelfTest(args.Device, args.Type, call.Progress)
+	})
+	Register(cmds, "services-destroy", func(call *CommandCall, args struct{}) (any, error) {
+		// repeated destroys are no-op
+		if cmds.Close() {
+			destroy <- struct{}{}
+		}
+		return nil, nil
+	})
 
-			switch res.Command {
-			case "general-tests":
-				go tests.NewGeneralTests(false, res.Runtime, chGeneralTests)
-			case "services-destroy":
-				destroy <- struct{}{}
+	// live from nodes-handler, nothing runs after destroy
+	go func() {
+		for {
+			select {
+			case <-cmds.Done():
 				return
+			case p := <-conn.chanLive:
+				cmds.Dispatch(p)
 			}
 		}
 	}()
@@ -77,19 +114,23 @@ func main() {
 				Event:       "collect-core",
 				CollectCore: cc,
 			}
+			for _, ev := range alerts.Observe("core", cc, time.Now()) {
+				conn.chanSend <- persistent{ev}
+			}
 
 		// chan-sender who logged terminals
 		case wl, ok := <-col.ChanWhoLogged:
 			if !ok {
 				continue
 			}
-			conn.chanSend <- struct {
+			// logins must survive outages, never evicted from spool
+			conn.chanSend <- persistent{struct {
 				Event     string               `json:"event"`
 				WhoLogged *collector.WhoLogged `json:"whoLogged"`
 			}{
 				Event:     "who-logged",
 				WhoLogged: wl,
-			}
+			}}
 
 		// chan-sender processes
 		case ps, ok := <-col.ChanProcesses:
@@ -98,12 +139,29 @@ func main() {
 			}
 			conn.chanSend <- struct {
 				Event     string               `json:"event"`
+				Time      time.Time            `json:"time"`
 				Processes *collector.Processes `json:"processes"`
 			}{
 				Event:     "processes",
+				Time:      time.Now().UTC(),
 				Processes: ps,
 			}
 
+		// chan-sender containers
+		case cs, ok := <-col.ChanContainers:
+			if !ok {
+				continue
+			}
+			conn.chanSend <- struct {
+				Event      string                `json:"event"`
+				Time       time.Time             `json:"time"`
+				Containers *collector.Containers `json:"containers"`
+			}{
+				Event:      "containers",
+				Time:       time.Now().UTC(),
+				Containers: cs,
+			}
+
 		// chan-sender general-test
 		case gt, ok := <-chGeneralTests:
 			if !ok {
@@ -129,6 +187,61 @@ func main() {
 				Event:     "disks-info",
 				DisksInfo: cdi,
 			}
+			for _, ev := range alerts.Observe("disks", cdi, cdi.Time) {
+				conn.chanSend <- persistent{ev}
+			}
+
+		// chan-sender smart counters moved
+		case dhc, ok := <-col.ChanDiskHealth:
+			if !ok {
+				continue
+			}
+			conn.chanSend <- persistent{struct {
+				Event  string                      `json:"event"`
+				Health *collector.DiskHealthChange `json:"health"`
+			}{
+				Event:  "disk-health-change",
+				Health: dhc,
+			}}
+
+		// chan-sender md array degraded or sync started, finished
+		case rsc, ok := <-col.ChanRaidState:
+			if !ok {
+				continue
+			}
+			conn.chanSend <- persistent{struct {
+				Event string                     `json:"event"`
+				Raid  *collector.RaidStateChange `json:"raid"`
+			}{
+				Event: "raid-state-change",
+				Raid:  rsc,
+			}}
+
+		// chan-sender filesystem predicted full within the horizon
+		case sf, ok := <-col.ChanSpaceForecast:
+			if !ok {
+				continue
+			}
+			conn.chanSend <- persistent{struct {
+				Event    string                   `json:"event"`
+				Forecast *collector.SpaceForecast `json:"forecast"`
+			}{
+				Event:    "space-forecast",
+				Forecast: sf,
+			}}
+
+		// chan-sender nvidia-smi restarted, stalled or back running
+		case gs, ok := <-col.ChanGpuState:
+			if !ok {
+				continue
+			}
+			conn.chanSend <- persistent{struct {
+				Event string                     `json:"event"`
+				State *collector.SubprocessState `json:"state"`
+			}{
+				Event: "gpu-collector-state",
+				State: gs,
+			}}
 
 		// handler destroy
 		case <-destroy:
@@ -0,0 +1,159 @@
+package info
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+type Dimm struct {
+	Locator      string `json:"locator"` // DIMM_A1
+	Bank         string `json:"bank,omitempty"`
+	Installed    bool   `json:"installed"`
+	Size         uint64 `json:"size"` // MB
+	Type         string `json:"type,omitempty"`
+	FormFactor   string `json:"formFactor,omitempty"`
+	Speed        uint   `json:"speed,omitempty"`           // MT/s rated
+	Configured   uint   `json:"configuredSpeed,omitempty"` // MT/s running
+	Ecc          bool   `json:"ecc"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	PartNumber   string `json:"partNumber,omitempty"`
+}
+
+func (i *Info) fillSystemInfo() {
+	const dmi = "/sys/class/dmi/id/"
+	s := &i.Data.System
+	s.Vendor = dmiString(dmi + "sys_vendor")
+	s.Product = dmiString(dmi + "product_name")
+	s.Serial = dmiString(dmi + "product_serial")
+	s.UUID = dmiString(dmi + "product_uuid")
+	s.BoardSerial = dmiString(dmi + "board_serial")
+	s.ChassisVendor = dmiString(dmi + "chassis_vendor")
+	s.ChassisSerial = dmiString(dmi + "chassis_serial")
+	s.AssetTag = dmiString(dmi + "chassis_asset_tag")
+}
+
+// dmiString drops the placeholders vendors leave in unset fields
+func dmiString(path string) string {
+	v := readString(path)
+	switch strings.ToLower(v) {
+	case "", "default string", "to be filled by o.e.m.", "not specified", "none", "0123456789", "system serial number", "chassis serial number":
+		return ""
+	}
+	return v
+}
+
+// fillDimmInfo runs dmidecode, DMIDECODE_CMD may point to a local stand-in printing the same output
+func (i *Info) fillDimmInfo() {
+	cmd := os.Getenv("DMIDECODE_CMD")
+	if cmd == "" {
+		if _, err := exec.LookPath("dmidecode"); err != nil {
+			return
+		}
+		cmd = "dmidecode -t 17"
+	}
+	out, err := exec.Command("sh", "-c", cmd).Output()
+	if err != nil {
+		log.Println("[info] dmidecode err:", err)
+		return
+	}
+	i.Data.Dimms = parseDmidecodeMemory(string(out))
+}
+
+// parseDmidecodeMemory reads the Memory Device (type 17) records, empty slots included
+func parseDmidecodeMemory(out string) []Dimm {
+	var dimms []Dimm
+	var d *Dimm
+	var total, data uint64
+	flush := func() {
+		if d != nil {
+			d.Ecc = total > data && data > 0
+			dimms = append(dimms, *d)
+		}
+		d = nil
+	}
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if line == "Memory Device" {
+			flush()
+			d = &Dimm{}
+			total, data = 0, 0
+			continue
+		}
+		if d == nil {
+			continue
+		}
+		if line == "" || !strings.HasPrefix(line, "\t") {
+			flush()
+			continue
+		}
+		key, val, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(val, "Unknown") || strings.EqualFold(val, "Not Specified") || val == "NO DIMM" {
+			continue
+		}
+		switch key {
+		case "Locator":
+			d.Locator = val
+		case "Bank Locator":
+			d.Bank = val
+		case "Size":
+			d.Size = dmiSize(val)
+			d.Installed = d.Size > 0
+		case "Type":
+			d.Type = val
+		case "Form Factor":
+			d.FormFactor = val
+		case "Speed":
+			d.Speed = dmiSpeed(val)
+		case "Configured Memory Speed", "Configured Clock Speed":
+			d.Configured = dmiSpeed(val)
+		case "Total Width":
+			total = dmiSize(val)
+		case "Data Width":
+			data = dmiSize(val)
+		case "Manufacturer":
+			d.Manufacturer = val
+		case "Serial Number":
+			d.Serial = val
+		case "Part Number":
+			d.PartNumber = val
+		}
+	}
+	flush()
+	return dimms
+}
+
+// dmiSize is MB for "32 GB" and "16384 MB", the bare number otherwise ("72 bits"), 0 for "No Module Installed"
+func dmiSize(val string) uint64 {
+	f := strings.Fields(val)
+	if len(f) < 2 {
+		return 0
+	}
+	n, err := strconv.ParseUint(f[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch f[1] {
+	case "kB":
+		return n >> 10
+	case "GB":
+		return n << 10
+	case "TB":
+		return n << 20
+	}
+	return n
+}
+
+// dmiSpeed takes "3200 MT/s" and the older "2666 MHz"
+func dmiSpeed(val string) uint {
+	n, _ := strconv.ParseUint(strings.Fields(val + " 0")[0], 10, 32)
+	return uint(n)
+}
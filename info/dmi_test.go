@@ -0,0 +1,68 @@
+package info
+
+import "testing"
+
+func TestParseDmidecodeMemory(t *testing.T) {
+	t.Parallel()
+
+	out := `# dmidecode 3.5
+Getting SMBIOS data from sysfs.
+SMBIOS 3.3.0 present.
+
+Handle 0x1100, DMI type 17, 92 bytes
+Memory Device
+	Array Handle: 0x1000
+	Error Information Handle: Not Provided
+	Total Width: 72 bits
+	Data Width: 64 bits
+	Size: 32 GB
+	Form Factor: DIMM
+	Set: 1
+	Locator: A1
+	Bank Locator: Not Specified
+	Type: DDR4
+	Type Detail: Synchronous Registered (Buffered)
+	Speed: 3200 MT/s
+	Manufacturer: 00CE00B300CE
+	Serial Number: 03A1B2C3
+	Part Number: M393A4K40DB3-CWE    
+	Configured Memory Speed: 2933 MT/s
+
+Handle 0x1101, DMI type 17, 92 bytes
+Memory Device
+	Total Width: Unknown
+	Data Width: Unknown
+	Size: No Module Installed
+	Form Factor: DIMM
+	Locator: A2
+	Type: Unknown
+	Speed: Unknown
+	Manufacturer: NO DIMM
+
+Handle 0x0042, DMI type 17, 40 bytes
+Memory Device
+	Total Width: 64 bits
+	Data Width: 64 bits
+	Size: 8192 MB
+	Form Factor: SODIMM
+	Locator: ChannelA-DIMM0
+	Bank Locator: BANK 0
+	Type: DDR3
+	Speed: 1600 MHz
+	Configured Clock Speed: 1333 MHz
+`
+	dimms := parseDmidecodeMemory(out)
+	if len(dimms) != 3 {
+		t.Fatalf("dimms = %+v", dimms)
+	}
+	if d := dimms[0]; d.Locator != "A1" || d.Bank != "" || !d.Installed || d.Size != 32768 || d.Type != "DDR4" ||
+		d.Speed != 3200 || d.Configured != 2933 || !d.Ecc || d.Serial != "03A1B2C3" || d.PartNumber != "M393A4K40DB3-CWE" {
+		t.Fatalf("A1 = %+v", d)
+	}
+	if d := dimms[1]; d.Locator != "A2" || d.Installed || d.Size != 0 || d.Type != "" || d.Manufacturer != "" {
+		t.Fatalf("A2 = %+v", d)
+	}
+	if d := dimms[2]; d.Size != 8192 || d.Ecc || d.FormFactor != "SODIMM" || d.Speed != 1600 || d.Configured != 1333 {
+		t.Fatalf("sodimm = %+v", d)
+	}
+}
@@ -0,0 +1,193 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Nic struct {
+	Name    string `json:"name"`
+	MAC     string `json:"mac"`
+	PermMAC string `json:"permMac,omitempty"` // factory address when bonding or the user changed it
+	Driver  string `json:"driver,omitempty"`
+	PciBus  string `json:"pciBus,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Speed   int    `json:"speed,omitempty"` // Mb/s, 0 while down
+}
+
+type Disk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	Vendor     string `json:"vendor,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Firmware   string `json:"firmware,omitempty"`
+	Size       uint64 `json:"size"` // bytes
+	Rotational bool   `json:"rotational"`
+}
+
+type Gpu struct {
+	PciBus string `json:"pciBus"`
+	Vendor string `json:"vendor"`
+	Model  string `json:"model"`
+	Driver string `json:"driver,omitempty"`
+	Vram   uint64 `json:"vram,omitempty"` // bytes, amdgpu only
+}
+
+// virtual block devices are not hardware
+var diskSkipPrefix = []string{"loop", "ram", "zram", "dm-", "md", "nbd", "sr", "fd", "zd"}
+
+func (i *Info) fillHardwareInfo() {
+	i.Data.PCI = readPCIDevices("/sys/bus/pci/devices", loadPciIDs())
+	i.Data.USB = readUSBDevices("/sys/bus/usb/devices")
+	i.Data.Nics = readNics("/sys/class/net", i.Data.PCI)
+	i.Data.Disks = readDisks("/sys/block")
+	i.Data.Gpus = readGpus("/sys/bus/pci/devices", i.Data.PCI)
+}
+
+func pciByAddress(pci []PCIDevice, address string) *PCIDevice {
+	for n := range pci {
+		if pci[n].Address == address {
+			return &pci[n]
+		}
+	}
+	return nil
+}
+
+// readNics lists interfaces backed by a device, bridges, bonds and veths have none
+func readNics(root string, pci []PCIDevice) []Nic {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var nics []Nic
+	for _, e := range entries {
+		dir := root + "/" + e.Name()
+		dev, err := filepath.EvalSymlinks(dir + "/device")
+		if err != nil {
+			continue
+		}
+		n := Nic{
+			Name: e.Name(),
+			MAC:  readString(dir + "/address"),
+		}
+		if perm := readString(dir + "/perm_addr"); perm != n.MAC {
+			n.PermMAC = perm
+		}
+		if driver, err := os.Readlink(dir + "/device/driver"); err == nil {
+			n.Driver = filepath.Base(driver)
+		}
+		if speed, err := strconv.Atoi(readString(dir + "/speed")); err == nil && speed > 0 {
+			n.Speed = speed
+		}
+		// virtio nics hang below their pci function
+		p := pciByAddress(pci, filepath.Base(dev))
+		if p == nil {
+			p = pciByAddress(pci, filepath.Base(filepath.Dir(dev)))
+		}
+		if p != nil {
+			n.PciBus = p.Address
+			n.Model = strings.TrimSpace(p.VendorName + " " + p.DeviceName)
+		}
+		nics = append(nics, n)
+	}
+	return nics
+}
+
+func readDisks(root string) []Disk {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var disks []Disk
+	for _, e := range entries {
+		if hasPrefix(e.Name(), diskSkipPrefix) {
+			continue
+		}
+		dir := root + "/" + e.Name()
+		sectors, _ := strconv.ParseUint(readString(dir+"/size"), 10, 64)
+		if sectors == 0 {
+			continue
+		}
+		d := Disk{
+			Name:       e.Name(),
+			Model:      readString(dir + "/device/model"),
+			Vendor:     readString(dir + "/device/vendor"),
+			Serial:     readString(dir + "/device/serial"),
+			Firmware:   readString(dir + "/device/firmware_rev"),
+			Size:       sectors * 512,
+			Rotational: readString(dir+"/queue/rotational") == "1",
+		}
+		if d.Firmware == "" {
+			d.Firmware = readString(dir + "/device/rev")
+		}
+		if d.Serial == "" {
+			d.Serial = vpdSerial(dir + "/device/vpd_pg80")
+		}
+		disks = append(disks, d)
+	}
+	return disks
+}
+
+// vpdSerial reads the unit serial number page of scsi and sata disks, a 4 byte header and ascii
+func vpdSerial(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) < 4 {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(string(b[4:]), "\x00"))
+}
+
+// readGpus picks display controllers out of the pci list
+func readGpus(root string, pci []PCIDevice) []Gpu {
+	var gpus []Gpu
+	for _, p := range pci {
+		if !strings.HasPrefix(p.Class, "0x03") {
+			continue
+		}
+		g := Gpu{
+			PciBus: p.Address,
+			Vendor: p.VendorName,
+			Model:  p.DeviceName,
+			Driver: p.Driver,
+		}
+		if g.Vendor == "" {
+			g.Vendor = p.Vendor
+		}
+		if g.Model == "" {
+			g.Model = nvidiaModel(p.Address)
+		}
+		if g.Model == "" {
+			g.Model = p.Device
+		}
+		g.Vram, _ = strconv.ParseUint(readString(root+"/"+p.Address+"/mem_info_vram_total"), 10, 64)
+		gpus = append(gpus, g)
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].PciBus < gpus[j].PciBus })
+	return gpus
+}
+
+// nvidiaModel asks the proprietary driver when pci.ids is not installed
+func nvidiaModel(address string) string {
+	b, err := os.ReadFile("/proc/driver/nvidia/gpus/" + address + "/information")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if model, ok := strings.CutPrefix(line, "Model:"); ok {
+			return strings.TrimSpace(model)
+		}
+	}
+	return ""
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
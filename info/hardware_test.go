@@ -0,0 +1,150 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadNics(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"pci/0000:00:03.0/virtio0/uevent": "",
+		"pci/0000:00:1f.6/uevent":         "",
+		"drivers/virtio_net/bind":         "",
+		"drivers/e1000e/bind":             "",
+		"net/eth0/address":                "52:54:00:12:34:56",
+		"net/eth0/perm_addr":              "52:54:00:12:34:56",
+		"net/eth0/speed":                  "-1",
+		"net/eno1/address":                "02:00:00:00:00:01",
+		"net/eno1/perm_addr":              "3c:ec:ef:00:00:02",
+		"net/eno1/speed":                  "10000",
+		"net/br0/address":                 "02:00:00:00:00:01",
+	})
+	links := map[string]string{
+		"net/eth0/device":                 "pci/0000:00:03.0/virtio0",
+		"pci/0000:00:03.0/virtio0/driver": "drivers/virtio_net",
+		"net/eno1/device":                 "pci/0000:00:1f.6",
+		"pci/0000:00:1f.6/driver":         "drivers/e1000e",
+	}
+	for link, target := range links {
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pci := []PCIDevice{
+		{Address: "0000:00:03.0", VendorName: "Red Hat, Inc.", DeviceName: "Virtio network device"},
+		{Address: "0000:00:1f.6", VendorName: "Intel Corporation", DeviceName: "Ethernet Connection I219-LM"},
+	}
+
+	nics := readNics(filepath.Join(root, "net"), pci)
+	if len(nics) != 2 {
+		t.Fatalf("nics = %+v", nics)
+	}
+	// the virtio nic hangs below its pci function, an unknown speed stays 0
+	eno1, eth0 := nics[0], nics[1]
+	if eth0.Name != "eth0" || eth0.PciBus != "0000:00:03.0" || eth0.Model != "Red Hat, Inc. Virtio network device" ||
+		eth0.Driver != "virtio_net" || eth0.Speed != 0 || eth0.PermMAC != "" {
+		t.Fatalf("eth0 = %+v", eth0)
+	}
+	// a changed address keeps the factory one
+	if eno1.Name != "eno1" || eno1.PciBus != "0000:00:1f.6" || eno1.Driver != "e1000e" || eno1.Speed != 10000 ||
+		eno1.MAC != "02:00:00:00:00:01" || eno1.PermMAC != "3c:ec:ef:00:00:02" {
+		t.Fatalf("eno1 = %+v", eno1)
+	}
+}
+
+func TestReadDisks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"nvme0n1/size":                "1953525168",
+		"nvme0n1/device/model":        "Samsung SSD 980 PRO 1TB",
+		"nvme0n1/device/serial":       "S5GXNF0R123456",
+		"nvme0n1/device/firmware_rev": "5B2QGXA7",
+		"nvme0n1/queue/rotational":    "0",
+		"sda/size":                    "7814037168",
+		"sda/device/model":            "ST4000NM0035-1V4",
+		"sda/device/vendor":           "ATA",
+		"sda/device/rev":              "TN04",
+		"sda/queue/rotational":        "1",
+		"sdb/size":                    "0",
+		"loop0/size":                  "2048",
+		"dm-0/size":                   "2048",
+	})
+	// unit serial number page: qualifier, page code, length, then the ascii serial
+	vpd := append([]byte{0x00, 0x80, 0x00, 0x14}, []byte("        ZC1234AB\x00\x00\x00\x00")...)
+	if err := os.WriteFile(filepath.Join(root, "sda/device/vpd_pg80"), vpd, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	disks := readDisks(root)
+	if len(disks) != 2 {
+		t.Fatalf("disks = %+v", disks)
+	}
+	nvme, sda := disks[0], disks[1]
+	if nvme.Name != "nvme0n1" || nvme.Serial != "S5GXNF0R123456" || nvme.Firmware != "5B2QGXA7" ||
+		nvme.Size != 1953525168*512 || nvme.Rotational {
+		t.Fatalf("nvme0n1 = %+v", nvme)
+	}
+	if sda.Name != "sda" || sda.Serial != "ZC1234AB" || sda.Firmware != "TN04" || sda.Vendor != "ATA" || !sda.Rotational {
+		t.Fatalf("sda = %+v", sda)
+	}
+}
+
+func TestVpdSerial(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tests := map[string]string{
+		"\x00\x80\x00\x08WD-12345":         "WD-12345",
+		"\x00\x80\x00\x0a  S3Z9NB0K  ":     "S3Z9NB0K",
+		"\x00\x80":                         "",
+		"\x00\x80\x00\x04\x00\x00\x00\x00": "",
+	}
+	n := 0
+	for data, want := range tests {
+		path := filepath.Join(dir, "vpd_pg80."+string(rune('a'+n)))
+		n++
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := vpdSerial(path); got != want {
+			t.Fatalf("vpd %q: got %q, want %q", data, got, want)
+		}
+	}
+	if got := vpdSerial(filepath.Join(dir, "missing")); got != "" {
+		t.Fatalf("missing page: got %q", got)
+	}
+}
+
+func TestReadGpus(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"0000:c3:00.0/mem_info_vram_total": "17163091968",
+	})
+	pci := []PCIDevice{
+		{Address: "0000:c3:00.0", Class: "0x030000", Vendor: "0x1002", Device: "0x744c",
+			VendorName: "Advanced Micro Devices, Inc. [AMD/ATI]", DeviceName: "Navi 31 [Radeon RX 7900 XT/7900 XTX]", Driver: "amdgpu"},
+		{Address: "0000:00:02.0", Class: "0x030000", Vendor: "0x8086", Device: "0x4680", Driver: "i915"},
+		{Address: "0000:00:1f.3", Class: "0x040300", Vendor: "0x8086", Device: "0x7ad0"},
+	}
+
+	gpus := readGpus(root, pci)
+	if len(gpus) != 2 {
+		t.Fatalf("gpus = %+v", gpus)
+	}
+	// without pci.ids the raw ids stand in for the names
+	igpu, amd := gpus[0], gpus[1]
+	if igpu.PciBus != "0000:00:02.0" || igpu.Vendor != "0x8086" || igpu.Model != "0x4680" || igpu.Driver != "i915" || igpu.Vram != 0 {
+		t.Fatalf("igpu = %+v", igpu)
+	}
+	if amd.Model != "Navi 31 [Radeon RX 7900 XT/7900 XTX]" || amd.Driver != "amdgpu" || amd.Vram != 17163091968 {
+		t.Fatalf("amd = %+v", amd)
+	}
+}
@@ -28,12 +28,28 @@ type Info struct {
 			Vendor      string `json:"vendor"`
 			BiosVersion string `json:"biosVersion"`
 		} `json:"board"`
+		System struct {
+			Vendor        string `json:"vendor"`
+			Product       string `json:"product"`
+			Serial        string `json:"serial"`
+			UUID          string `json:"uuid"`
+			BoardSerial   string `json:"boardSerial"`
+			ChassisVendor string `json:"chassisVendor"`
+			ChassisSerial string `json:"chassisSerial"`
+			AssetTag      string `json:"assetTag"`
+		} `json:"system"`
 		Kernel struct {
 			Architecture string `json:"architecture"`
 			OSType       string `json:"osType"`
 			OSRelease    string `json:"osRelease"`
 			OSVersion    string `json:"osVersion"`
 		} `json:"kernel"`
+		PCI   []PCIDevice `json:"pci"`
+		USB   []USBDevice `json:"usb"`
+		Dimms []Dimm      `json:"dimms"`
+		Nics  []Nic       `json:"nics"`
+		Disks []Disk      `json:"disks"`
+		Gpus  []Gpu       `json:"gpus"`
 	} `json:"data"`
 }
 
@@ -45,6 +61,9 @@ func Get() (i *Info) {
 	i.fillMemInfo()
 	i.fillBoardInfo()
 	i.fillKernelInfo()
+	i.fillSystemInfo()
+	i.fillDimmInfo()
+	i.fillHardwareInfo()
 
 	return
 }
@@ -0,0 +1,169 @@
+package info
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type PCIDevice struct {
+	Address    string `json:"address"`
+	Class      string `json:"class"` // 0x020000
+	ClassName  string `json:"className,omitempty"`
+	Vendor     string `json:"vendor"` // 0x8086
+	Device     string `json:"device"`
+	VendorName string `json:"vendorName,omitempty"`
+	DeviceName string `json:"deviceName,omitempty"`
+	SubVendor  string `json:"subVendor,omitempty"`
+	SubDevice  string `json:"subDevice,omitempty"`
+	Revision   string `json:"revision,omitempty"`
+	Driver     string `json:"driver,omitempty"`
+}
+
+type USBDevice struct {
+	Bus          string `json:"bus"` // 1-1.2
+	Vendor       string `json:"vendor"`
+	Product      string `json:"product"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	Speed        string `json:"speed,omitempty"` // Mb/s
+}
+
+// pciIDs is the part of the pci.ids database naming vendors, devices and classes
+type pciIDs struct {
+	vendors map[string]string // 8086
+	devices map[string]string // 8086:1521
+	classes map[string]string // 02 and 0200
+}
+
+var pciIDsPaths = []string{"/usr/share/hwdata/pci.ids", "/usr/share/misc/pci.ids", "/usr/share/pci.ids"}
+
+func loadPciIDs() *pciIDs {
+	for _, p := range pciIDsPaths {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		ids := parsePciIDs(f)
+		_ = f.Close()
+		return ids
+	}
+	return parsePciIDs(strings.NewReader(""))
+}
+
+func parsePciIDs(r io.Reader) *pciIDs {
+	ids := &pciIDs{
+		vendors: map[string]string{},
+		devices: map[string]string{},
+		classes: map[string]string{},
+	}
+	var vendor, class string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		tabs := len(line) - len(strings.TrimLeft(line, "\t"))
+		id, name, ok := strings.Cut(strings.TrimLeft(line, "\t"), "  ")
+		if !ok {
+			continue
+		}
+		switch {
+		case tabs == 0 && strings.HasPrefix(id, "C "):
+			vendor, class = "", strings.TrimPrefix(id, "C ")
+			ids.classes[class] = name
+		case tabs == 0 && len(id) == 4:
+			vendor, class = id, ""
+			ids.vendors[id] = name
+		case tabs == 1 && vendor != "":
+			ids.devices[vendor+":"+id] = name
+		case tabs == 1 && class != "":
+			ids.classes[class+id] = name
+		case tabs == 0:
+			// device lists for usb and friends follow, none of them pci
+			vendor, class = "", ""
+		}
+	}
+	return ids
+}
+
+func (ids *pciIDs) name(d *PCIDevice) {
+	vendor := strings.TrimPrefix(d.Vendor, "0x")
+	d.VendorName = ids.vendors[vendor]
+	d.DeviceName = ids.devices[vendor+":"+strings.TrimPrefix(d.Device, "0x")]
+	class := strings.TrimPrefix(d.Class, "0x")
+	if len(class) >= 4 {
+		d.ClassName = ids.classes[class[:4]]
+		if d.ClassName == "" {
+			d.ClassName = ids.classes[class[:2]]
+		}
+	}
+}
+
+// readPCIDevices lists root, normally /sys/bus/pci/devices
+func readPCIDevices(root string, ids *pciIDs) []PCIDevice {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	devices := make([]PCIDevice, 0, len(entries))
+	for _, e := range entries {
+		dir := root + "/" + e.Name()
+		d := PCIDevice{
+			Address:   e.Name(),
+			Class:     readString(dir + "/class"),
+			Vendor:    readString(dir + "/vendor"),
+			Device:    readString(dir + "/device"),
+			SubVendor: readString(dir + "/subsystem_vendor"),
+			SubDevice: readString(dir + "/subsystem_device"),
+			Revision:  readString(dir + "/revision"),
+		}
+		if driver, err := os.Readlink(dir + "/driver"); err == nil {
+			d.Driver = filepath.Base(driver)
+		}
+		ids.name(&d)
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices
+}
+
+// readUSBDevices lists devices below root, normally /sys/bus/usb/devices, interfaces are skipped
+func readUSBDevices(root string) []USBDevice {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var devices []USBDevice
+	for _, e := range entries {
+		dir := root + "/" + e.Name()
+		vendor := readString(dir + "/idVendor")
+		if vendor == "" || strings.Contains(e.Name(), ":") {
+			continue
+		}
+		devices = append(devices, USBDevice{
+			Bus:          e.Name(),
+			Vendor:       vendor,
+			Product:      readString(dir + "/idProduct"),
+			Manufacturer: readString(dir + "/manufacturer"),
+			Name:         readString(dir + "/product"),
+			Serial:       readString(dir + "/serial"),
+			Speed:        readString(dir + "/speed"),
+		})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Bus < devices[j].Bus })
+	return devices
+}
+
+func readString(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
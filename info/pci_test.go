@@ -0,0 +1,105 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPciIDs = `# pci.ids excerpt
+8086  Intel Corporation
+	1521  I350 Gigabit Network Connection
+		8086 0001  Ethernet Server Adapter I350-T4
+	56a0  DG2 [Arc A770]
+10de  NVIDIA Corporation
+C 02  Network controller
+	00  Ethernet controller
+C 03  Display controller
+	00  VGA compatible controller
+		00  VGA controller
+`
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParsePciIDs(t *testing.T) {
+	t.Parallel()
+
+	ids := parsePciIDs(strings.NewReader(testPciIDs))
+	if ids.vendors["8086"] != "Intel Corporation" || ids.devices["8086:1521"] != "I350 Gigabit Network Connection" {
+		t.Fatalf("ids = %+v", ids)
+	}
+	if _, ok := ids.devices["8086:8086"]; ok {
+		t.Fatal("subsystem parsed as device")
+	}
+	if ids.classes["02"] != "Network controller" || ids.classes["0300"] != "VGA compatible controller" {
+		t.Fatalf("classes = %+v", ids.classes)
+	}
+}
+
+func TestReadPCIDevices(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"pci/0000:03:00.0/class":               "0x030000",
+		"pci/0000:03:00.0/vendor":              "0x8086",
+		"pci/0000:03:00.0/device":              "0x56a0",
+		"pci/0000:01:00.0/class":               "0x020000",
+		"pci/0000:01:00.0/vendor":              "0x8086",
+		"pci/0000:01:00.0/device":              "0x1521",
+		"pci/0000:05:00.0/class":               "0x038000",
+		"pci/0000:05:00.0/vendor":              "0x10de",
+		"pci/0000:05:00.0/device":              "0x20b5",
+		"pci/0000:05:00.0/mem_info_vram_total": "25769803776",
+		"drivers/igb/bind":                     "",
+		"net/eno1/address":                     "3c:ec:ef:00:00:01",
+		"net/eno1/perm_addr":                   "3c:ec:ef:00:00:01",
+		"net/eno1/speed":                       "1000",
+		"net/bond0/address":                    "3c:ec:ef:00:00:01",
+	})
+	if err := os.Symlink(filepath.Join(root, "drivers/igb"), filepath.Join(root, "pci/0000:01:00.0/driver")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "pci/0000:01:00.0"), filepath.Join(root, "net/eno1/device")); err != nil {
+		t.Fatal(err)
+	}
+
+	pci := readPCIDevices(filepath.Join(root, "pci"), parsePciIDs(strings.NewReader(testPciIDs)))
+	if len(pci) != 3 || pci[0].Address != "0000:01:00.0" {
+		t.Fatalf("pci = %+v", pci)
+	}
+	if d := pci[0]; d.Driver != "igb" || d.ClassName != "Ethernet controller" || d.VendorName != "Intel Corporation" {
+		t.Fatalf("nic = %+v", d)
+	}
+	// unknown subclass falls back to the class
+	if d := pci[2]; d.ClassName != "Display controller" || d.VendorName != "NVIDIA Corporation" || d.DeviceName != "" {
+		t.Fatalf("nvidia = %+v", d)
+	}
+
+	nics := readNics(filepath.Join(root, "net"), pci)
+	if len(nics) != 1 {
+		t.Fatalf("nics = %+v", nics)
+	}
+	if n := nics[0]; n.Name != "eno1" || n.PermMAC != "" || n.Driver != "igb" || n.Speed != 1000 ||
+		n.Model != "Intel Corporation I350 Gigabit Network Connection" {
+		t.Fatalf("eno1 = %+v", n)
+	}
+
+	gpus := readGpus(filepath.Join(root, "pci"), pci)
+	if len(gpus) != 2 || gpus[0].Model != "DG2 [Arc A770]" || gpus[1].Model != "0x20b5" ||
+		gpus[0].Vram != 0 || gpus[1].Vram != 24<<30 {
+		t.Fatalf("gpus = %+v", gpus)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type HandshakeErrorKind string
+
+const (
+	ErrKindAuth     HandshakeErrorKind = "auth-rejected"
+	ErrKindQuota    HandshakeErrorKind = "quota-reached"
+	ErrKindServer   HandshakeErrorKind = "server-error"
+	ErrKindDNS      HandshakeErrorKind = "dns"
+	ErrKindTLS      HandshakeErrorKind = "tls"
+	ErrKindNetwork  HandshakeErrorKind = "network"
+	ErrKindProtocol HandshakeErrorKind = "protocol"
+	ErrKindRejected HandshakeErrorKind = "rejected"
+)
+
+// HandshakeError classifies why the connection to api could not be established or was lost
+type HandshakeError struct {
+	Kind HandshakeErrorKind
+	Err  error
+}
+
+func (e *HandshakeError) Error() string {
+	return string(e.Kind) + ": " + e.Err.Error()
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+func newHandshakeError(kind HandshakeErrorKind, err error) error {
+	return &HandshakeError{Kind: kind, Err: err}
+}
+
+// netHandshakeError picks the kind for an error returned by the http client or ws dialer
+func netHandshakeError(err error) error {
+	var (
+		dnsErr     *net.DNSError
+		recordErr  tls.RecordHeaderError
+		verifyErr  *tls.CertificateVerificationError
+		authErr    x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+		alertErr   tls.AlertError
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return newHandshakeError(ErrKindDNS, err)
+	case errors.As(err, &recordErr), errors.As(err, &verifyErr), errors.As(err, &authErr),
+		errors.As(err, &hostErr), errors.As(err, &invalidErr), errors.As(err, &alertErr):
+		return newHandshakeError(ErrKindTLS, err)
+	}
+	return newHandshakeError(ErrKindNetwork, err)
+}
+
+// ErrNodesLimit is the business reject of a full subscription. The handshake response carries
+// only ok and message, api answers it with 200 and ok false, so the message is all there is to match.
+var ErrNodesLimit = errors.New("number of nodes has been reached")
+
+// apiHandshakeError picks the kind for a handshake response rejected by api
+func apiHandshakeError(status int, message string) error {
+	err := errors.New("api message: " + message)
+	switch {
+	case status == 401 || status == 403:
+		return newHandshakeError(ErrKindAuth, err)
+	case status == 402 || status == 429:
+		return newHandshakeError(ErrKindQuota, err)
+	case status >= 500:
+		return newHandshakeError(ErrKindServer, err)
+	case strings.Contains(message, ErrNodesLimit.Error()):
+		return newHandshakeError(ErrKindQuota, fmt.Errorf("api message: %s: %w", message, ErrNodesLimit))
+	}
+	return newHandshakeError(ErrKindRejected, err)
+}
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+type ReconnectState struct {
+	Circuit   string             `json:"circuit"`
+	Attempts  int                `json:"attempts"`
+	Since     time.Time          `json:"since,omitzero"`
+	NextRetry time.Time          `json:"nextRetry,omitzero"`
+	LastKind  HandshakeErrorKind `json:"lastKind,omitempty"`
+	LastError string             `json:"lastError,omitempty"`
+}
+
+func (s ReconnectState) String() string {
+	return fmt.Sprintf("circuit: %s attempts: %d next retry: %s last kind: %q",
+		s.Circuit, s.Attempts, s.NextRetry.Format(time.RFC3339), s.LastKind)
+}
+
+// ReconnectPolicy is exponential backoff with full jitter, some error kinds keep a floor,
+// a quota or auth reject will not go away within seconds
+type ReconnectPolicy struct {
+	mu    sync.Mutex
+	base  time.Duration
+	cap   time.Duration
+	floor map[HandshakeErrorKind]time.Duration
+	state ReconnectState
+}
+
+func NewReconnectPolicy() *ReconnectPolicy {
+	p := &ReconnectPolicy{
+		base: 2 * time.Second,
+		cap:  5 * time.Minute,
+		floor: map[HandshakeErrorKind]time.Duration{
+			ErrKindQuota: 5 * time.Minute,
+			ErrKindAuth:  time.Minute,
+		},
+		state: ReconnectState{Circuit: CircuitClosed},
+	}
+	if d, err := time.ParseDuration(os.Getenv("RECONNECT_MAX")); err == nil && d > 0 {
+		p.cap = d
+	}
+	return p
+}
+
+// Failure records the error and returns how long to wait before the next attempt
+func (p *ReconnectPolicy) Failure(err error) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kind := ErrKindNetwork
+	var he *HandshakeError
+	if errors.As(err, &he) {
+		kind = he.Kind
+	}
+
+	if p.state.Attempts == 0 {
+		p.state.Since = time.Now().UTC()
+	}
+	p.state.Attempts++
+	p.state.LastKind = kind
+	p.state.LastError = err.Error()
+	p.state.Circuit = CircuitOpen
+
+	wait := p.nextWait(kind, p.state.Attempts)
+	p.state.NextRetry = time.Now().UTC().Add(wait)
+	return wait
+}
+
+// nextWait is the floor of the kind plus full jitter over the exponential step, never above cap
+func (p *ReconnectPolicy) nextWait(kind HandshakeErrorKind, attempts int) time.Duration {
+	ceil := p.cap
+	if shift := attempts - 1; shift < 30 {
+		ceil = min(p.cap, p.base<<shift)
+	}
+	return min(p.cap, p.floor[kind]+rand.N(ceil))
+}
+
+// Attempt marks the circuit as probing
+func (p *ReconnectPolicy) Attempt() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state.Circuit == CircuitOpen {
+		p.state.Circuit = CircuitHalfOpen
+	}
+}
+
+// Success closes the circuit and returns the state of the outage which just ended
+func (p *ReconnectPolicy) Success() ReconnectState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.state
+	p.state = ReconnectState{Circuit: CircuitClosed}
+	return st
+}
+
+func (p *ReconnectPolicy) State() ReconnectState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
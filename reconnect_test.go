@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestPolicy(maxWait time.Duration) *ReconnectPolicy {
+	return &ReconnectPolicy{
+		base: 2 * time.Second,
+		cap:  maxWait,
+		floor: map[HandshakeErrorKind]time.Duration{
+			ErrKindQuota: 5 * time.Minute,
+			ErrKindAuth:  time.Minute,
+		},
+		state: ReconnectState{Circuit: CircuitClosed},
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPolicy(5 * time.Minute)
+	for attempts := 1; attempts <= 40; attempts++ {
+		ceil := min(p.cap, p.base<<min(attempts-1, 30))
+		for range 50 {
+			if wait := p.nextWait(ErrKindNetwork, attempts); wait < 0 || wait >= ceil {
+				t.Fatalf("attempt %d: wait %s outside [0, %s)", attempts, wait, ceil)
+			}
+		}
+	}
+
+	// the step grows until the cap, jitter spreads it over the whole range
+	var longest time.Duration
+	for range 200 {
+		longest = max(longest, p.nextWait(ErrKindNetwork, 20))
+	}
+	if longest < p.cap/2 {
+		t.Fatalf("longest wait %s after 20 attempts, want close to cap %s", longest, p.cap)
+	}
+}
+
+func TestReconnectFloor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		kind     HandshakeErrorKind
+		cap      time.Duration
+		min, max time.Duration
+	}{
+		{ErrKindNetwork, 5 * time.Minute, 0, 2 * time.Second},
+		{ErrKindAuth, 5 * time.Minute, time.Minute, time.Minute + 2*time.Second},
+		{ErrKindQuota, 5 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+		// RECONNECT_MAX below the floor wins
+		{ErrKindQuota, 30 * time.Second, 30 * time.Second, 30 * time.Second},
+		{ErrKindAuth, 30 * time.Second, 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		p := newTestPolicy(tt.cap)
+		for range 50 {
+			if wait := p.nextWait(tt.kind, 1); wait < tt.min || wait > tt.max {
+				t.Fatalf("%s cap %s: wait %s outside [%s, %s]", tt.kind, tt.cap, wait, tt.min, tt.max)
+			}
+		}
+	}
+
+	// a quota wait never exceeds cap however many attempts failed
+	p := newTestPolicy(5 * time.Minute)
+	for attempts := 1; attempts <= 20; attempts++ {
+		if wait := p.nextWait(ErrKindQuota, attempts); wait > p.cap {
+			t.Fatalf("attempt %d: quota wait %s over cap", attempts, wait)
+		}
+	}
+}
+
+func TestReconnectCircuit(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPolicy(time.Minute)
+	if st := p.State(); st.Circuit != CircuitClosed || st.Attempts != 0 {
+		t.Fatalf("initial state %+v", st)
+	}
+
+	// attempt on a closed circuit is the first connect, it stays closed
+	p.Attempt()
+	if st := p.State(); st.Circuit != CircuitClosed {
+		t.Fatalf("attempt on closed circuit: %+v", st)
+	}
+
+	p.Failure(newHandshakeError(ErrKindDNS, errors.New("no such host")))
+	st := p.State()
+	if st.Circuit != CircuitOpen || st.Attempts != 1 || st.LastKind != ErrKindDNS || st.Since.IsZero() {
+		t.Fatalf("after failure %+v", st)
+	}
+	since := st.Since
+
+	p.Attempt()
+	if st := p.State(); st.Circuit != CircuitHalfOpen {
+		t.Fatalf("after attempt %+v", st)
+	}
+
+	// a bare error counts as network
+	p.Failure(errors.New("replay spool: broken pipe"))
+	st = p.State()
+	if st.Circuit != CircuitOpen || st.Attempts != 2 || st.LastKind != ErrKindNetwork || !st.Since.Equal(since) {
+		t.Fatalf("after second failure %+v", st)
+	}
+	if st.NextRetry.Before(since) {
+		t.Fatalf("next retry %s before outage start %s", st.NextRetry, since)
+	}
+
+	p.Attempt()
+	outage := p.Success()
+	if outage.Attempts != 2 || outage.Circuit != CircuitHalfOpen || outage.LastKind != ErrKindNetwork {
+		t.Fatalf("outage %+v", outage)
+	}
+	if st := p.State(); st.Circuit != CircuitClosed || st.Attempts != 0 || !st.Since.IsZero() {
+		t.Fatalf("after success %+v", st)
+	}
+}
+
+func TestHandshakeErrorKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status  int
+		message string
+		want    HandshakeErrorKind
+	}{
+		{401, "invalid key", ErrKindAuth},
+		{403, "forbidden", ErrKindAuth},
+		{402, "payment required", ErrKindQuota},
+		{429, "too many requests", ErrKindQuota},
+		{500, "internal", ErrKindServer},
+		{503, "unavailable", ErrKindServer},
+		{200, "the maximum number of nodes has been reached", ErrKindQuota},
+		{200, "node is disabled", ErrKindRejected},
+		{400, "bad request", ErrKindRejected},
+	}
+	for _, tt := range tests {
+		err := apiHandshakeError(tt.status, tt.message)
+		var he *HandshakeError
+		if !errors.As(err, &he) || he.Kind != tt.want {
+			t.Fatalf("%d %q: got %v, want %s", tt.status, tt.message, err, tt.want)
+		}
+	}
+
+	err := apiHandshakeError(200, "the maximum number of nodes has been reached")
+	if !errors.Is(err, ErrNodesLimit) {
+		t.Fatalf("nodes limit not matched by sentinel: %v", err)
+	}
+	if errors.Is(apiHandshakeError(429, "too many requests"), ErrNodesLimit) {
+		t.Fatal("rate limit matched as nodes limit")
+	}
+
+	netTests := []struct {
+		err  error
+		want HandshakeErrorKind
+	}{
+		{&net.DNSError{Err: "no such host", Name: "cloudnetip.com"}, ErrKindDNS},
+		{fmt.Errorf("dial native: %w", &net.DNSError{Err: "timeout"}), ErrKindDNS},
+		{errors.New("connection refused"), ErrKindNetwork},
+	}
+	for _, tt := range netTests {
+		var he *HandshakeError
+		if err := netHandshakeError(tt.err); !errors.As(err, &he) || he.Kind != tt.want {
+			t.Fatalf("%v: got %v, want %s", tt.err, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolSegmentExt = ".seg"
+	spoolBadExt     = ".bad"
+)
+
+// persistent marks an outgoing event which the spool never evicts by size or age caps
+type persistent struct {
+	event any
+}
+
+func (p persistent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.event)
+}
+
+type spoolRecord struct {
+	Time int64           `json:"t"`
+	Keep bool            `json:"k,omitempty"`
+	Data json.RawMessage `json:"d"`
+}
+
+type spoolSegment struct {
+	name    string
+	size    int64
+	updated time.Time
+	trimmed bool // only persistent records are left
+}
+
+// Spool buffers outgoing events in append-only segment files while the websocket is down
+type Spool struct {
+	mu          sync.Mutex
+	dir         string
+	maxSize     int64
+	maxAge      time.Duration
+	segmentSize int64
+	segments    []*spoolSegment
+	size        int64
+	seq         uint64
+	tail        *os.File
+}
+
+func NewSpool() *Spool {
+	s := &Spool{
+		dir:     os.Getenv("SPOOL_DIR"),
+		maxSize: 128 << 20,
+		maxAge:  24 * time.Hour,
+	}
+	if s.dir == "" {
+		s.dir = "/var/lib/netip/spool"
+	}
+	if mb, err := strconv.Atoi(os.Getenv("SPOOL_MAX_MB")); err == nil && mb > 0 {
+		s.maxSize = int64(mb) << 20
+	}
+	if age, err := time.ParseDuration(os.Getenv("SPOOL_MAX_AGE")); err == nil && age > 0 {
+		s.maxAge = age
+	}
+	s.segmentSize = min(s.maxSize/8, 4<<20)
+
+	if err := s.load(); err != nil {
+		log.Println("[spool] disabled, err:", err)
+		s.dir = ""
+		return s
+	}
+	if len(s.segments) > 0 {
+		log.Printf("[spool] found %d segments, %d bytes waiting to replay", len(s.segments), s.size)
+	}
+	return s
+}
+
+func (s *Spool) load() error {
+	err := os.MkdirAll(s.dir, 0o700)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			_ = os.Remove(filepath.Join(s.dir, e.Name()))
+			continue
+		}
+		if !strings.HasSuffix(e.Name(), spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, &spoolSegment{
+			name:    e.Name(),
+			size:    fi.Size(),
+			updated: fi.ModTime(),
+		})
+		s.size += fi.Size()
+		s.seq = max(s.seq, seq)
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].name < s.segments[j].name
+	})
+	return nil
+}
+
+// Empty reports whether nothing waits for replay
+func (s *Spool) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments) == 0
+}
+
+// Push appends the event to the tail segment and syncs it to disk
+func (s *Spool) Push(event any) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("[spool] marshal event err:", err)
+		return
+	}
+	_, keep := event.(persistent)
+	rec, err := json.Marshal(spoolRecord{
+		Time: time.Now().UnixNano(),
+		Keep: keep,
+		Data: data,
+	})
+	if err != nil {
+		log.Println("[spool] marshal record err:", err)
+		return
+	}
+	rec = append(rec, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		log.Printf("[spool] disabled, event dropped: %.128s", data)
+		return
+	}
+	if err = s.append(rec); err != nil {
+		log.Printf("[spool] append err: %s, event dropped: %.128s", err, data)
+		return
+	}
+	s.enforce()
+}
+
+func (s *Spool) append(rec []byte) error {
+	last := len(s.segments) - 1
+	if s.tail == nil || s.segments[last].size+int64(len(rec)) > s.segmentSize {
+		if s.tail != nil {
+			_ = s.tail.Close()
+		}
+		s.seq++
+		name := fmt.Sprintf("%020d%s", s.seq, spoolSegmentExt)
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			s.tail = nil
+			return err
+		}
+		s.tail = f
+		s.segments = append(s.segments, &spoolSegment{name: name})
+		last = len(s.segments) - 1
+	}
+
+	n, err := s.tail.Write(rec)
+	seg := s.segments[last]
+	seg.size += int64(n)
+	seg.updated = time.Now()
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.tail.Sync()
+}
+
+// enforce trims the oldest segments down to persistent records while the spool is over its caps,
+// the tail segment being appended is never touched
+func (s *Spool) enforce() {
+	now := time.Now()
+	for i := 0; i < len(s.segments)-1; i++ {
+		seg := s.segments[i]
+		if seg.trimmed {
+			continue
+		}
+		if s.size <= s.maxSize && now.Sub(seg.updated) < s.maxAge {
+			break
+		}
+		records, err := s.read(seg.name)
+		if err != nil {
+			log.Println("[spool] read segment err:", err)
+			continue
+		}
+		kept := records[:0]
+		for _, r := range records {
+			if r.Keep {
+				kept = append(kept, r)
+			}
+		}
+		log.Printf("[spool] over limits, evicted %d events from %s", len(records)-len(kept), seg.name)
+		if len(kept) == 0 {
+			s.remove(i)
+			i--
+			continue
+		}
+		if err = s.rewrite(seg, kept); err != nil {
+			log.Println("[spool] rewrite segment err:", err)
+			continue
+		}
+		seg.trimmed = true
+	}
+	if s.size > s.maxSize {
+		log.Printf("[spool] warn: %d bytes of persistent events exceed limit", s.size)
+	}
+}
+
+// Replay sends spooled events oldest first, a segment is removed once all its events are sent,
+// on failure the unsent remainder stays for the next attempt
+func (s *Spool) Replay(send func(data []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tail != nil {
+		_ = s.tail.Close()
+		s.tail = nil
+	}
+
+	sent, expired := 0, 0
+	defer func() {
+		if sent > 0 || expired > 0 {
+			log.Printf("[spool] replayed %d events, expired %d", sent, expired)
+		}
+	}()
+
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		records, err := s.read(seg.name)
+		if err != nil {
+			log.Printf("[spool] read segment err: %s, moved aside as %s%s", err, seg.name, spoolBadExt)
+			s.quarantine(0)
+			continue
+		}
+		for i, r := range records {
+			if !r.Keep && time.Since(time.Unix(0, r.Time)) > s.maxAge {
+				expired++
+				continue
+			}
+			if err = send(r.Data); err != nil {
+				if wErr := s.rewrite(seg, records[i:]); wErr != nil {
+					log.Println("[spool] rewrite segment err:", wErr)
+				}
+				return err
+			}
+			sent++
+		}
+		s.remove(0)
+	}
+	return nil
+}
+
+func (s *Spool) read(name string) ([]spoolRecord, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []spoolRecord
+	scanner := bufio.NewScanner(f)
+	// a record larger than segmentSize gets a segment of its own, no line is longer than the file
+	scanner.Buffer(make([]byte, 64*1024), max(int(fi.Size())+1, 64*1024))
+	for scanner.Scan() {
+		var r spoolRecord
+		// torn write after crash, skip it
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+func (s *Spool) rewrite(seg *spoolSegment, records []spoolRecord) error {
+	path := filepath.Join(s.dir, seg.name)
+	f, err := os.CreateTemp(s.dir, seg.name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		rec, _ := json.Marshal(r)
+		_, _ = w.Write(rec)
+		_ = w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	_ = f.Close()
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	s.size += fi.Size() - seg.size
+	seg.size = fi.Size()
+	return nil
+}
+
+// quarantine keeps an unreadable segment on disk for inspection, out of replay and size accounting
+func (s *Spool) quarantine(i int) {
+	seg := s.segments[i]
+	path := filepath.Join(s.dir, seg.name)
+	if err := os.Rename(path, path+spoolBadExt); err != nil && !os.IsNotExist(err) {
+		log.Println("[spool] quarantine segment err:", err)
+	}
+	s.size -= seg.size
+	s.segments = append(s.segments[:i], s.segments[i+1:]...)
+}
+
+func (s *Spool) remove(i int) {
+	seg := s.segments[i]
+	err := os.Remove(filepath.Join(s.dir, seg.name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("[spool] remove segment err:", err)
+	}
+	s.size -= seg.size
+	s.segments = append(s.segments[:i], s.segments[i+1:]...)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSpool(t *testing.T, dir string, maxSize int64) *Spool {
+	t.Helper()
+	s := &Spool{
+		dir:         dir,
+		maxSize:     maxSize,
+		maxAge:      time.Hour,
+		segmentSize: 512,
+	}
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+type spoolEvent struct {
+	Event string `json:"event"`
+	N     int    `json:"n"`
+}
+
+func replayAll(t *testing.T, s *Spool) []spoolEvent {
+	t.Helper()
+	var got []spoolEvent
+	err := s.Replay(func(data []byte) error {
+		var e spoolEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestSpoolReplayOrder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, 1<<20)
+	for n := range 40 {
+		s.Push(spoolEvent{Event: "metric", N: n})
+	}
+	if len(s.segments) < 3 {
+		t.Fatalf("segments = %d, want the events spread over several", len(s.segments))
+	}
+
+	// connection drops during replay, the rest waits for the next handshake
+	s = newTestSpool(t, dir, 1<<20)
+	sent := 0
+	err := s.Replay(func(data []byte) error {
+		if sent == 15 {
+			return errors.New("broken pipe")
+		}
+		sent++
+		return nil
+	})
+	if err == nil {
+		t.Fatal("replay error lost")
+	}
+	s.Push(spoolEvent{Event: "metric", N: 40})
+
+	s = newTestSpool(t, dir, 1<<20)
+	got := replayAll(t, s)
+	if len(got) != 26 {
+		t.Fatalf("replayed %d events, want 26", len(got))
+	}
+	for i, e := range got {
+		if e.N != 15+i {
+			t.Fatalf("event %d = %d, want %d", i, e.N, 15+i)
+		}
+	}
+	if !s.Empty() {
+		t.Fatal("segments left after full replay")
+	}
+}
+
+func TestSpoolEviction(t *testing.T) {
+	t.Parallel()
+
+	s := newTestSpool(t, t.TempDir(), 2048)
+	for n := range 100 {
+		if n%25 == 0 {
+			s.Push(persistent{spoolEvent{Event: "login", N: n}})
+			continue
+		}
+		s.Push(spoolEvent{Event: "metric", N: n})
+	}
+	if s.size > s.maxSize {
+		t.Fatalf("size %d over cap %d", s.size, s.maxSize)
+	}
+
+	got := replayAll(t, s)
+	logins, last := 0, -1
+	for _, e := range got {
+		if e.N <= last {
+			t.Fatalf("order broken at %d after %d", e.N, last)
+		}
+		last = e.N
+		if e.Event == "login" {
+			logins++
+		}
+	}
+	if logins != 4 {
+		t.Fatalf("persistent events kept = %d, want 4", logins)
+	}
+	if len(got) >= 100 || got[len(got)-1].N != 99 {
+		t.Fatalf("replayed %d events ending at %d, want the oldest metrics evicted", len(got), got[len(got)-1].N)
+	}
+}
+
+func TestSpoolTornRecord(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, 1<<20)
+	for n := range 3 {
+		s.Push(spoolEvent{Event: "metric", N: n})
+	}
+	_ = s.tail.Close()
+
+	// crash in the middle of a write, plus a temp file from an unfinished rewrite
+	last := filepath.Join(dir, s.segments[len(s.segments)-1].name)
+	f, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(`{"t":1,"d":{"event":"met`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	tmp := last + ".123.tmp"
+	if err = os.WriteFile(tmp, []byte("junk"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestSpool(t, dir, 1<<20)
+	if _, err = os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatal("temp file left after load")
+	}
+	s.Push(spoolEvent{Event: "metric", N: 3})
+	got := replayAll(t, s)
+	if len(got) != 4 {
+		t.Fatalf("replayed %+v", got)
+	}
+	for i, e := range got {
+		if e.N != i {
+			t.Fatalf("event %d = %+v", i, e)
+		}
+	}
+}
+
+func TestSpoolLargeRecord(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, 1<<20)
+	big := strings.Repeat("x", 100<<10)
+	s.Push(spoolEvent{Event: "metric", N: 0})
+	s.Push(persistent{spoolEvent{Event: big, N: 1}})
+	s.Push(spoolEvent{Event: "metric", N: 2})
+
+	s = newTestSpool(t, dir, 1<<20)
+	got := replayAll(t, s)
+	if len(got) != 3 || got[1].Event != big {
+		t.Fatalf("replayed %d events, want 3 with the large one intact", len(got))
+	}
+}
+
+func TestSpoolQuarantine(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, 1<<20)
+	s.Push(spoolEvent{Event: "metric", N: 1})
+	_ = s.tail.Close()
+
+	// a segment which cannot be read is moved aside, not deleted
+	broken := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, spoolSegmentExt))
+	if err := os.Mkdir(broken, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s = newTestSpool(t, dir, 1<<20)
+	got := replayAll(t, s)
+	if len(got) != 1 || got[0].N != 1 {
+		t.Fatalf("replayed %+v", got)
+	}
+	if _, err := os.Stat(broken + spoolBadExt); err != nil {
+		t.Fatal("unreadable segment not kept:", err)
+	}
+	if s = newTestSpool(t, dir, 1<<20); !s.Empty() {
+		t.Fatal("quarantined segment loaded again")
+	}
+}